@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/llm"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const usageLatency = "Measure how long your therapist takes to pick up the phone"
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "🩺 Check on your therapist's health",
+	Long: `🩺 Kommit Doctor - Because even therapists need a check-up!
+
+This command looks over your treatment plan and tells you which therapist
+you'll be seeing and where they practice.
+
+With --latency it also calls ahead and times the round trip, broken down
+into DNS, connect, TLS and first-byte phases, so you know whether a slow
+session is your network's fault or your therapist's.`,
+	Run: runDoctor,
+}
+
+var Latency bool
+
+func runDoctor(cmd *cobra.Command, args []string) {
+	config, err := utils.LoadConfig()
+	if err != nil {
+		HandleUnsupportedModelError(DoctorCmd, err)
+		HandleInvalidConfigError(DoctorCmd, err)
+		fmt.Printf("%s: No treatment plan found!\n", getErrorPrefix(DoctorCmd))
+		fmt.Println("(Run 'git kommit init' to get on the calendar.)")
+		if Verbose {
+			log.Printf("Error loading config: %v", err)
+		}
+		os.Exit(1)
+	}
+
+	provider, model, baseURL, err := llm.ResolveLLM(config)
+	if err != nil {
+		fmt.Printf("%s: Couldn't find your therapist's practice!\n", getErrorPrefix(DoctorCmd))
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("🩺 Therapist: %s via %s\n", model, provider)
+	if baseURL != "" {
+		fmt.Printf("🩺 Practice:  %s\n", baseURL)
+	}
+
+	if files, err := utils.ExecGit("ls-files"); err == nil {
+		staleScopes := utils.ValidateScopesAgainstPaths(config.Commit.Scopes, strings.Split(strings.TrimSpace(files), "\n"))
+		staleScopes = slices.DeleteFunc(staleScopes, func(scope string) bool {
+			return slices.ContainsFunc(config.Commit.PathScopeRules, func(rule utils.PathScopeRule) bool { return rule.Scope == scope })
+		})
+		if len(staleScopes) > 0 {
+			fmt.Printf("⚠️  Stale scopes: %s\n", strings.Join(staleScopes, ", "))
+			fmt.Println("(They no longer match anything in the repo. Consider removing them from commit.scopes.)")
+		}
+	}
+
+	if !Latency {
+		return
+	}
+
+	latency, err := llm.PingPhases(context.Background(), config)
+	if err != nil {
+		fmt.Printf("%s: Your therapist isn't picking up!\n", getErrorPrefix(DoctorCmd))
+		if errors.Is(err, &llm.APIKeyMissingError{}) {
+			fmt.Println("(Have you set OPENAI_API_KEY or KOMMIT_OPENAI_API_KEY?)")
+		}
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println("🩺 Round trip:")
+	fmt.Printf("  DNS:        %s\n", latency.DNS)
+	fmt.Printf("  Connect:    %s\n", latency.Connect)
+	fmt.Printf("  TLS:        %s\n", latency.TLS)
+	fmt.Printf("  First byte: %s\n", latency.FirstByte)
+	fmt.Printf("  Total:      %s\n", latency.Total)
+}
+
+func init() {
+	doctorCmd.Flags().BoolVar(&Latency, "latency", false, usageLatency)
+	rootCmd.AddCommand(doctorCmd)
+}
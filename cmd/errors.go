@@ -14,12 +14,14 @@ const (
 	InitCmd CmdType = iota
 	RootCmd
 	VersionCmd
+	DoctorCmd
 )
 
 var cmdErrorPrefix = map[CmdType]string{
 	InitCmd:    "😰 Therapy session interrupted",
 	RootCmd:    "😰 Commitment issues detected",
 	VersionCmd: "No errors are returned from this command.",
+	DoctorCmd:  "😰 Check-up interrupted",
 }
 
 func getErrorPrefix(cmd CmdType) string {
@@ -33,3 +35,12 @@ func HandleUnsupportedModelError(cmd CmdType, err error) {
 		os.Exit(1)
 	}
 }
+
+func HandleInvalidConfigError(cmd CmdType, err error) {
+	if errors.Is(err, utils.ConfigFieldError{}) {
+		fmt.Printf("%s: Your treatment plan has some contradictions!\n", getErrorPrefix(cmd))
+		fmt.Println(err)
+		fmt.Println("(Fix your .kommitrc.yaml and try again)")
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
@@ -11,6 +12,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+const usageMaxScopes = "Keep only the scopes most of your files turn to (0 keeps them all)"
+
+var MaxScopes int
+
 var initCommand = &cobra.Command{
 	Use:   "init",
 	Short: "😌 Your repo's therapy session begins here",
@@ -27,6 +32,10 @@ thank you for the therapy.`,
 }
 
 func runInit(cmd *cobra.Command, args []string) {
+	if Verbose {
+		llm.SetMetricsCallback(logMetrics)
+	}
+
 	// Load config, return if it exists
 	config, err := utils.LoadConfig()
 	if config != nil {
@@ -38,6 +47,7 @@ func runInit(cmd *cobra.Command, args []string) {
 	// Get default config, if it doesn't exist
 	if err != nil {
 		HandleUnsupportedModelError(InitCmd, err)
+		HandleInvalidConfigError(InitCmd, err)
 		config, err = utils.GetDefaultConfig()
 		if err != nil {
 			fmt.Println("😰 Therapy session interrupted: Failed to retrieve your treatment plan.")
@@ -79,14 +89,6 @@ func runInit(cmd *cobra.Command, args []string) {
 	s := ui.Spinner("🤔 Analyzing your repo's commitment issues...")
 	s.Start()
 
-	// Get scopes from commit history
-	existingScopes, err := utils.GetScopesFromHistory()
-	if err != nil {
-		if Verbose {
-			log.Printf("Error getting scopes from git history: %v", err)
-		}
-	}
-
 	// Get files from directory
 	filenames, err := utils.GetFilesFromDirectory(5)
 	if err != nil {
@@ -98,7 +100,13 @@ func runInit(cmd *cobra.Command, args []string) {
 	}
 
 	// Generate scopes from directory
-	result, err := llm.GenerateScopesFromFilenames(model, filenames, existingScopes)
+	result, err := inferScopes(context.Background(), config.LLM, filenames, maxInferredScopes(cmd), func(done, total int) {
+		if total > 1 {
+			s.Lock()
+			s.Suffix = fmt.Sprintf(" 🤔 Analyzing your repo's commitment issues... (%d/%d)", done, total)
+			s.Unlock()
+		}
+	})
 	if err != nil {
 		fmt.Println("😰 Therapy session interrupted: Failed to establish your treatment plan.")
 		if Verbose {
@@ -126,6 +134,37 @@ func runInit(cmd *cobra.Command, args []string) {
 	os.Exit(0)
 }
 
+// inferScopes suggests scopes for filenames, leaving out the ones already used
+// in the commit history, and keeps the maxScopes best-supported ones when
+// maxScopes is positive.
+func inferScopes(ctx context.Context, llmConfig utils.LLMConfig, filenames []string, maxScopes int, progress func(done, total int)) (llm.ChatResult[llm.Scopes], error) {
+	existingScopes, err := utils.GetScopesFromHistory()
+	if err != nil {
+		if Verbose {
+			log.Printf("Error getting scopes from git history: %v", err)
+		}
+	}
+	return llm.GenerateScopesFromFilenamesWithProgress(ctx, llmConfig, filenames, existingScopes, maxScopes, progress)
+}
+
+// maxInferredScopes returns --max-scopes when given, or else
+// commit.maxInferredScopes from the global config, since the repo has no
+// config yet.
+func maxInferredScopes(cmd *cobra.Command) int {
+	if cmd.Flags().Changed("max-scopes") {
+		return MaxScopes
+	}
+	global, err := utils.LoadGlobalConfig()
+	if err != nil || global == nil {
+		if err != nil && Verbose {
+			log.Printf("Error loading the global config: %v", err)
+		}
+		return 0
+	}
+	return global.Commit.MaxInferredScopes
+}
+
 func init() {
+	initCommand.Flags().IntVar(&MaxScopes, "max-scopes", 0, usageMaxScopes)
 	rootCmd.AddCommand(initCommand)
 }
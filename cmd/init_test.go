@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/llm"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// newScopesServer answers every scope inference request with scopes.
+func newScopesServer(t *testing.T, scopes []string) utils.LLMConfig {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, _ := json.Marshal(llm.Scopes{Scopes: scopes})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":    "chatcmpl-test",
+			"model": "gpt-4o-mini",
+			"choices": []map[string]any{{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]any{"role": "assistant", "content": string(content)},
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("KOMMIT_OPENAI_API_KEY", "test-key")
+	for _, key := range []string{"KOMMIT_LLM_PROVIDER", "KOMMIT_LLM_MODEL", "KOMMIT_OPENAI_BASE_URL", "OPENAI_BASE_URL"} {
+		t.Setenv(key, "")
+	}
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	return utils.LLMConfig{Model: "gpt-4o-mini", BaseURL: server.URL + "/"}
+}
+
+// newTestRepo creates a repository whose history uses the given subjects.
+func newTestRepo(t *testing.T, subjects ...string) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, key := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(key+"_NAME", "Test")
+		t.Setenv(key+"_EMAIL", "test@example.com")
+	}
+
+	runGit := func(args ...string) {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+	runGit("init", "-q", "-b", "main")
+	for _, subject := range subjects {
+		runGit("commit", "-q", "--allow-empty", "-m", subject)
+	}
+}
+
+func TestInferScopes(t *testing.T) {
+	filenames := []string{
+		"api/handler.go", "api/routes.go", "api/middleware.go",
+		"db/schema.sql", "db/migrate.go",
+		"ui/app.tsx",
+		"auth/login.go",
+	}
+
+	tests := []struct {
+		name      string
+		maxScopes int
+		want      []string
+	}{
+		{"uncapped", 0, []string{"api", "db", "docs", "ui"}},
+		{"capped to the most-supported", 2, []string{"api", "db"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llmConfig := newScopesServer(t, []string{"ui", "auth", "api", "docs", "db"})
+			// Scopes from the history are left out, not merged back in
+			newTestRepo(t, "feat(auth): add login", "fix(legacy): drop it")
+
+			result, err := inferScopes(context.Background(), llmConfig, filenames, tt.maxScopes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Message.Scopes, tt.want) {
+				t.Errorf("scopes = %q, want %q", result.Message.Scopes, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxInferredScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		global string
+		flag   string
+		want   int
+	}{
+		{"no global config", "", "", 0},
+		{"global config", "commit:\n  maxInferredScopes: 5\n", "", 5},
+		{"flag overrides the global config", "commit:\n  maxInferredScopes: 5\n", "3", 3},
+		{"flag turns the cap off", "commit:\n  maxInferredScopes: 5\n", "0", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", dir)
+			if tt.global != "" {
+				path := filepath.Join(dir, "kommit", "config.yaml")
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(tt.global), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cmd := &cobra.Command{}
+			cmd.Flags().IntVar(&MaxScopes, "max-scopes", 0, usageMaxScopes)
+			if tt.flag != "" {
+				if err := cmd.Flags().Set("max-scopes", tt.flag); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := maxInferredScopes(cmd); got != tt.want {
+				t.Errorf("maxInferredScopes() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"strings"
 
+	"github.com/cowboy-bebug/kommit/internal/git"
 	"github.com/cowboy-bebug/kommit/internal/llm"
 	"github.com/cowboy-bebug/kommit/internal/ui"
 	"github.com/cowboy-bebug/kommit/internal/utils"
@@ -20,6 +22,8 @@ const (
 	usageMessage = "Provide your side of the story before the AI therapist diagnoses your code changes"
 	usageApprove = "Skip the therapy session to approve the suggested message"
 	usageEdit    = "Skip the therapy session to edit the suggested message"
+	usageType    = "Tell your therapist what kind of change this is (e.g. fix)"
+	usageIntent  = "Share what you were trying to achieve, so your therapist sees the changes your way"
 	usageHelp    = "Schedule an emergency therapy session (show help)"
 	usageVerbose = "Hear all the relationship details your repo normally keeps private"
 )
@@ -46,18 +50,15 @@ expressive documentation of your development journey.`,
 }
 
 func runCommit(cmd *cobra.Command, args []string) {
-	// Check if there are staged changes
-	diff, err := utils.ExecGit("diff", "--cached")
-	if err != nil || diff == "" {
-		fmt.Println("😰 Commitment issues detected: You're not ready to commit... anything.")
-		fmt.Println("(Stage some changes first!)")
-		os.Exit(1)
+	if Verbose {
+		llm.SetMetricsCallback(logMetrics)
 	}
 
 	// Load config to get available scopes
 	config, err := utils.LoadConfig()
 	if err != nil {
 		HandleUnsupportedModelError(RootCmd, err)
+		HandleInvalidConfigError(RootCmd, err)
 		fmt.Println("😰 Commitment issues detected: You haven't booked your first therapy session!")
 		fmt.Println("(Run 'git kommit init' to get on the calendar.)")
 		if Verbose {
@@ -71,17 +72,99 @@ func runCommit(cmd *cobra.Command, args []string) {
 	context += "Optionally use the following scopes only if the changes are related to the scopes:\n"
 	context += fmt.Sprintf("- scopes: %s\n", config.Commit.Scopes)
 
-	s := ui.Spinner("🧐 Helping your code express its feelings to future developers...")
-	s.Start()
-	result, err := llm.GenerateCommitMessage(config, diff, Message)
-	utils.UpdateCost(float64(result.Cost))
-	s.Stop()
+	// Check if there are staged changes, with the configured amount of context
+	diff, err := git.StagedDiff(".", config.Commit.DiffContextLines())
+	if err != nil || diff == "" {
+		fmt.Println("😰 Commitment issues detected: You're not ready to commit... anything.")
+		fmt.Println("(Stage some changes first!)")
+		if err != nil && Verbose {
+			log.Printf("Error reading staged changes: %v", err)
+		}
+		os.Exit(1)
+	}
+
+	if Verbose {
+		if provider, model, baseURL, err := llm.ResolveLLM(config); err == nil {
+			log.Printf("Using %s via %s (%s)", model, provider, baseURL)
+		}
+	}
+
+	var result llm.ChatResult[string]
+	if config.Commit.Fixup != "" {
+		var previousSubject string
+		previousSubject, err = utils.ExecGit("log", "-1", "--format=%s")
+		if err != nil {
+			fmt.Println("😰 Commitment issues detected: There's no previous commit to make amends with!")
+			if Verbose {
+				log.Printf("Error reading the previous commit: %v", err)
+			}
+			os.Exit(1)
+		}
+		result.Message, err = llm.GenerateFixupMessage(config, previousSubject)
+		if err != nil {
+			fmt.Println("😰 Commitment issues detected: There's no previous commit to make amends with!")
+			if Verbose {
+				log.Printf("Error generating fixup message: %v", err)
+			}
+			os.Exit(1)
+		}
+	} else {
+		s := ui.Spinner("🧐 Helping your code express its feelings to future developers...")
+		s.Start()
+		if Type != "" {
+			config.Commit.ForcedType = Type
+		}
+		// Without a HEAD nothing can be partially staged
+		worktreeDiff, _ := git.WorkingTreeDiff(".")
+		result, err = llm.GenerateCommitMessageForIndex(config, diff, worktreeDiff, Message, Intent)
+		utils.UpdateCost(float64(result.Cost))
+		s.Stop()
+		if Verbose {
+			log.Printf("Made %d API calls for %s", result.APICallCount, result.DiffStats)
+		}
+	}
+	if errors.Is(err, llm.EmptyMessageError{}) {
+		fmt.Println("😶 Your therapist is speechless. Time to put your feelings into words yourself.")
+		runManualCommit()
+		return
+	}
+	if errors.Is(err, llm.EmptyDiffError{}) {
+		fmt.Println("😰 Commitment issues detected: You're not ready to commit... anything.")
+		fmt.Println("(Your staged changes don't change anything!)")
+		os.Exit(1)
+	}
+	if errors.Is(err, llm.ConflictMarkersError{}) {
+		fmt.Println("😰 Commitment issues detected: Your code is still in the middle of a fight!")
+		fmt.Println(err)
+		fmt.Println("(Resolve the merge conflicts first, or set commit.allowConflictMarkers.)")
+		os.Exit(1)
+	}
+	if errors.Is(err, llm.SecretsDetectedError{}) {
+		fmt.Println("😰 Commitment issues detected: Your code is oversharing! Secrets found in the staged changes.")
+		fmt.Println(err)
+		fmt.Println("(Nothing was sent to your therapist. Remove the secrets and try again.)")
+		os.Exit(1)
+	}
 	if err != nil {
+		HandleInvalidConfigError(RootCmd, err)
 		fmt.Println("😰 Commitment issues detected: Your code is experiencing emotional resistance!")
 		if errors.Is(err, &llm.APIKeyMissingError{}) {
 			fmt.Println("\nHave you set up your OpenAI API key? Try one of these:")
 			fmt.Println("  export OPENAI_API_KEY=\"sk-...\"")
 			fmt.Println("  export KOMMIT_OPENAI_API_KEY=\"sk-...\"    # For a dedicated key")
+			fmt.Println("  Or set llm.useKeyring and store it in your OS keychain (service \"kommit\", account \"openai\")")
+		}
+		if errors.Is(err, llm.ErrRateLimited) {
+			fmt.Println("(Your therapist is overbooked. Wait a moment and try again.)")
+		}
+		if errors.Is(err, llm.CACertError{}) {
+			fmt.Printf("(%v. Check the path in your therapy notes.)\n", err)
+		}
+		if errors.Is(err, llm.RequestTooLargeError{}) {
+			fmt.Println("(Your changes are too much to unpack in one session. Try staging fewer files at a time.)")
+		}
+		if errors.Is(err, llm.DeniedTypeError{}) || errors.Is(err, llm.UnknownTypeError{}) {
+			fmt.Printf("(%v. Try another therapy session.)\n", err)
 		}
 		if Verbose {
 			log.Printf("Error generating commit message: %v", err)
@@ -89,7 +172,24 @@ func runCommit(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	commitMessage := result.Message
+	for _, warning := range result.Warnings {
+		fmt.Printf("⚠️  Therapist's note: %s\n", warning)
+	}
+	if result.Rationale != "" {
+		fmt.Printf("🧠 Therapist's reasoning: %s\n", result.Rationale)
+	}
+
+	message := result.Message
+	if len(result.Alternatives) > 0 && !Approve && !Edit {
+		message, err = ui.SelectMessage(append([]string{result.Message}, result.Alternatives...))
+		ui.HandleQuitError(err)
+		if err != nil {
+			log.Printf("Error selecting commit message: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	commitMessage := strings.TrimRight(message, "\n")
 	commitMessage += fmt.Sprintf("\n\n%s", commitMessageSignature)
 
 	var option ui.CommitOption
@@ -201,9 +301,40 @@ func runCommit(cmd *cobra.Command, args []string) {
 	}
 }
 
+func logMetrics(m llm.Metrics) {
+	log.Printf("API call: model=%s structured=%t prompt_tokens=%d completion_tokens=%d cost=$%.5f latency=%s",
+		m.Model, m.Structured, m.PromptTokens, m.CompletionTokens, m.Cost, m.Latency)
+	if rl := m.RateLimit; rl != nil {
+		log.Printf("Rate limits: requests=%d/%d (reset in %s) tokens=%d/%d (reset in %s)",
+			rl.RemainingRequests, rl.LimitRequests, rl.ResetRequests, rl.RemainingTokens, rl.LimitTokens, rl.ResetTokens)
+	}
+}
+
+// runManualCommit opens the editor for a commit message written from scratch.
+func runManualCommit() {
+	cmd := exec.Command("git", "commit")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	fmt.Println("📝 Opening your personal therapy journal (editor)...")
+
+	if err := cmd.Run(); err != nil {
+		fmt.Println("😰 Commitment issues detected: Your self-therapy session was interrupted!")
+		if Verbose {
+			log.Printf("Error during commit: %v", err)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println("🎓 Self-therapy complete! You've committed to your own path of growth.")
+}
+
 var Message string
 var Approve bool
 var Edit bool
+var Type string
+var Intent string
 var Verbose bool
 var Debug bool
 
@@ -223,12 +354,15 @@ func init() {
 	rootCmd.PersistentFlags().StringVarP(&Message, "message", "m", "", usageMessage)
 	rootCmd.PersistentFlags().BoolVarP(&Approve, "approve", "a", false, usageApprove)
 	rootCmd.PersistentFlags().BoolVarP(&Edit, "edit", "e", false, usageEdit)
+	rootCmd.PersistentFlags().StringVarP(&Type, "type", "t", "", usageType)
+	rootCmd.PersistentFlags().StringVarP(&Intent, "intent", "i", "", usageIntent)
 	rootCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, usageVerbose)
 
 	rootCmd.PersistentFlags().BoolP("help", "h", false, usageHelp) // TODO: add a man page
 }
 
 func Execute() {
+	llm.Version = Version
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -0,0 +1,45 @@
+// Package git wraps the git commands kommit needs to read a repository, so
+// callers only have to pass the repository path.
+package git
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// StagedDiff returns `git diff --cached` for the repository at repoPath.
+// contextLines is passed as `-U`, so zero gives a diff without context; a
+// negative value keeps git's default.
+func StagedDiff(repoPath string, contextLines int) (string, error) {
+	args := []string{"-C", repoPath, "diff", "--cached"}
+	if contextLines >= 0 {
+		args = append(args, fmt.Sprintf("-U%d", contextLines))
+	}
+	return utils.ExecGit(args...)
+}
+
+// WorkingTreeDiff returns `git diff HEAD` for the repository at repoPath: the
+// staged and unstaged changes together.
+func WorkingTreeDiff(repoPath string) (string, error) {
+	return utils.ExecGit("-C", repoPath, "diff", "HEAD")
+}
+
+// ChangedFiles returns the paths of the staged files in the repository at
+// repoPath, relative to its root.
+func ChangedFiles(repoPath string) ([]string, error) {
+	output, err := utils.ExecGit("-C", repoPath, "diff", "--cached", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	// -z keeps unusual paths unquoted
+	for _, path := range strings.Split(output, "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
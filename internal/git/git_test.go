@@ -0,0 +1,174 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates an empty repository with a committed ten-line file.
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	// Keep the user's git config out of the tests
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	for _, key := range []string{"GIT_AUTHOR", "GIT_COMMITTER"} {
+		t.Setenv(key+"_NAME", "Test")
+		t.Setenv(key+"_EMAIL", "test@example.com")
+	}
+
+	runGit(t, dir, "init", "-q", "-b", "main")
+	writeFile(t, filepath.Join(dir, "lines.txt"), "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStagedDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		contextLines int
+		want         []string
+		wantMissing  []string
+	}{
+		{"git's default context", -1, []string{"+five", " 2\n", " 8\n"}, []string{" 1\n", " 9\n"}},
+		{"no context", 0, []string{"+five"}, []string{" 4\n", " 6\n"}},
+		{"one line of context", 1, []string{"+five", " 4\n", " 6\n"}, []string{" 3\n", " 7\n"}},
+		{"whole file", 10, []string{"+five", " 1\n", " 10\n"}, nil},
+	}
+
+	dir := newTestRepo(t)
+	writeFile(t, filepath.Join(dir, "lines.txt"), "1\n2\n3\n4\nfive\n6\n7\n8\n9\n10\n")
+	runGit(t, dir, "add", "lines.txt")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := StagedDiff(dir, tt.contextLines)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(diff, s) {
+					t.Errorf("diff is missing %q:\n%s", s, diff)
+				}
+			}
+			for _, s := range tt.wantMissing {
+				if strings.Contains(diff, s) {
+					t.Errorf("diff has %q:\n%s", s, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestStagedDiffNothingStaged(t *testing.T) {
+	dir := newTestRepo(t)
+	// Unstaged changes don't count
+	writeFile(t, filepath.Join(dir, "lines.txt"), "changed\n")
+
+	diff, err := StagedDiff(dir, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != "" {
+		t.Errorf("StagedDiff() = %q, want an empty diff", diff)
+	}
+}
+
+func TestChangedFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+	}{
+		{"plain", []string{"main.go"}},
+		{"nested", []string{"internal/git/git.go"}},
+		{"spaces", []string{"docs/release notes.md"}},
+		{"unicode", []string{"docs/café.md"}},
+		{"quotes and tabs", []string{"a \"quoted\"\tname.txt"}},
+		{"several", []string{"a.go", "b/c.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestRepo(t)
+			for _, path := range tt.paths {
+				writeFile(t, filepath.Join(dir, path), "content\n")
+			}
+			runGit(t, dir, "add", ".")
+
+			files, err := ChangedFiles(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tt.paths) {
+				t.Errorf("ChangedFiles() = %q, want %q", files, tt.paths)
+			}
+		})
+	}
+}
+
+func TestChangedFilesNothingStaged(t *testing.T) {
+	dir := newTestRepo(t)
+	files, err := ChangedFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files != nil {
+		t.Errorf("ChangedFiles() = %q, want none", files)
+	}
+}
+
+func TestNotARepository(t *testing.T) {
+	newTestRepo(t)
+	dir := t.TempDir()
+	// Keep git from finding a repository above the temporary directory
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+
+	if _, err := StagedDiff(dir, -1); err == nil {
+		t.Error("StagedDiff() error = nil, want an error outside a repository")
+	}
+	if _, err := ChangedFiles(dir); err == nil {
+		t.Error("ChangedFiles() error = nil, want an error outside a repository")
+	}
+}
+
+func TestWorkingTreeDiff(t *testing.T) {
+	dir := newTestRepo(t)
+	writeFile(t, filepath.Join(dir, "lines.txt"), "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+	runGit(t, dir, "add", "lines.txt")
+	writeFile(t, filepath.Join(dir, "lines.txt"), "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n")
+
+	diff, err := WorkingTreeDiff(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both the staged and the unstaged change
+	for _, s := range []string{"+one", "+ten"} {
+		if !strings.Contains(diff, s) {
+			t.Errorf("diff is missing %q:\n%s", s, diff)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAmendCommitMessage(t *testing.T) {
+	const existing = "feat(api): add order export\n\n- Add the export endpoint\n"
+
+	tests := []struct {
+		name  string
+		reply string
+		want  string
+	}{
+		{
+			name:  "bullets merged",
+			reply: "feat(api): add order export\n\n- Add the export endpoint\n- Stream rows to CSV",
+			want:  "feat(api): add order export\n\n- Add the export endpoint\n- Stream rows to CSV\n",
+		},
+		{
+			name:  "reworded subject restored",
+			reply: "feat(api): add CSV order export\n\n- Add the export endpoint\n- Stream rows to CSV",
+			want:  "feat(api): add order export\n\n- Add the export endpoint\n- Stream rows to CSV\n",
+		},
+		{
+			name:  "new scope keeps the new subject",
+			reply: "feat(orders): add order export and import\n\n- Add the export endpoint\n- Add the import endpoint",
+			want:  "feat(orders): add order export and import\n\n- Add the export endpoint\n- Add the import endpoint\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+
+			got, err := AmendCommitMessage(config, existing, testDiff("api/export_csv.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("AmendCommitMessage() = %q, want %q", got, tt.want)
+			}
+
+			prompt := server.Requests()[0].LastUser()
+			if !strings.Contains(prompt, "## Existing Commit Message:") || !strings.Contains(prompt, "```text\n"+strings.TrimSpace(existing)+"\n```") {
+				t.Errorf("prompt doesn't include the existing message:\n%s", prompt)
+			}
+			if !strings.Contains(prompt, "+++ b/api/export_csv.go") {
+				t.Error("prompt doesn't include the additional diff")
+			}
+		})
+	}
+}
+
+func TestAmendCommitMessageValidation(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("perf(db): index orders"))
+	config := server.Config()
+	config.Commit.DeniedTypes = []string{"perf"}
+
+	if _, err := AmendCommitMessage(config, "feat(api): add order export", testDiff("db/index.sql")); !errors.Is(err, ErrDeniedType) {
+		t.Errorf("error = %v, want ErrDeniedType", err)
+	}
+}
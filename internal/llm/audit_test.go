@@ -0,0 +1,113 @@
+package llm
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestAuditRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+	}{
+		{"enabled", true},
+		{"disabled", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add the client"))
+			config := server.Config()
+			config.Audit = utils.AuditConfig{Enabled: tt.enabled, Dir: t.TempDir()}
+			config.Privacy.RedactPatterns = []string{`sk-[a-z0-9]+`}
+
+			diff := testDiff("client.go") + "+apiKey := \"sk-abc123\"\n"
+			if _, err := GenerateCommitMessage(config, diff, ""); err != nil {
+				t.Fatal(err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(config.Audit.Dir, "*.json"))
+			if !tt.enabled {
+				if len(files) != 0 {
+					t.Errorf("wrote %d audit files, want none", len(files))
+				}
+				return
+			}
+			if len(files) != 1 {
+				t.Fatalf("wrote %d audit files, want 1", len(files))
+			}
+
+			data, err := os.ReadFile(files[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record utils.AuditRecord
+			if err := json.Unmarshal(data, &record); err != nil {
+				t.Fatal(err)
+			}
+
+			if record.Provider != utils.ProviderOpenAI || record.Model != "gpt-4o-mini" {
+				t.Errorf("provider, model = %q, %q", record.Provider, record.Model)
+			}
+			if record.Response != "feat: add the client\n" {
+				t.Errorf("response = %q", record.Response)
+			}
+			if record.PromptTokens != 10 || record.CompletionTokens != 5 {
+				t.Errorf("tokens = %d, %d, want 10, 5", record.PromptTokens, record.CompletionTokens)
+			}
+			if record.SystemPrompt == "" || record.DiffFingerprint == "" || record.Time.IsZero() {
+				t.Errorf("record is missing fields: %+v", record)
+			}
+			if strings.Contains(record.Prompt, "sk-abc123") || !strings.Contains(record.Prompt, `+apiKey := "[REDACTED]"`) {
+				t.Errorf("prompt isn't redacted:\n%s", record.Prompt)
+			}
+		})
+	}
+}
+
+func TestAuditRecordExchanges(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("fix(api): handel nil ordres.", "fix(api): handle nil orders"))
+	config := server.Config()
+	config.Audit = utils.AuditConfig{Enabled: true, Dir: t.TempDir()}
+	config.Commit.ProofreadPass = true
+
+	if _, err := GenerateCommitMessage(config, testDiff("api.go"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(config.Audit.Dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("wrote %d audit files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record utils.AuditRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Response != "fix(api): handle nil orders\n" {
+		t.Errorf("response = %q", record.Response)
+	}
+	// The model's own answer, before the subject period was stripped
+	if record.RawResponse != "fix(api): handel nil ordres." {
+		t.Errorf("raw response = %q", record.RawResponse)
+	}
+	if len(record.Exchanges) != 2 {
+		t.Fatalf("recorded %d exchanges, want the generation and the proofreading pass: %+v", len(record.Exchanges), record.Exchanges)
+	}
+	proofreading := record.Exchanges[1]
+	if len(proofreading.Messages) != 1 || !strings.Contains(proofreading.Messages[0].Content, "fix(api): handel nil ordres\n") {
+		t.Errorf("proofreading request = %+v", proofreading.Messages)
+	}
+	if proofreading.Response != "fix(api): handle nil orders" {
+		t.Errorf("proofreading response = %q", proofreading.Response)
+	}
+}
@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const maxConcurrentGenerations = 4
+
+// GenerateCommitMessages generates a commit message for every diff
+// concurrently, keyed the same way as diffs. Failed diffs are left out of the
+// result and reported together in the returned error.
+func GenerateCommitMessages(ctx context.Context, config *utils.Config, diffs map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(diffs))
+	for key := range diffs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jobs := make(chan string)
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		messages = make(map[string]string, len(diffs))
+		errs     []error
+	)
+
+	for range min(maxConcurrentGenerations, len(keys)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range jobs {
+				result, err := generateCommitMessage(ctx, config, diffs[key], "")
+
+				mu.Lock()
+				utils.UpdateCost(float64(result.Cost))
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				} else {
+					messages[key] = result.Message
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, key := range keys {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- key:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return messages, errors.Join(errs...)
+}
+
+// GeneratePerFileMessages splits diff by file and generates a commit message
+// for each one, keyed by path, for teams that prefer atomic per-file commits.
+func GeneratePerFileMessages(config *utils.Config, diff string) (map[string]string, error) {
+	diffs := make(map[string]string)
+	for _, f := range utils.ParseDiff(diff) {
+		diffs[f.Path()] += f.String()
+	}
+	return GenerateCommitMessages(context.Background(), config, diffs)
+}
+
+// CompareGenerations generates a commit message for the same diff with two
+// prompt templates concurrently, for A/B testing prompt changes. An empty
+// template uses the built-in prompt.
+func CompareGenerations(config *utils.Config, diff string, templateA, templateB string) (string, string, error) {
+	templates := [2]string{templateA, templateB}
+	var (
+		wg      sync.WaitGroup
+		results [2]ChatResult[string]
+		errs    [2]error
+	)
+	for i, tmpl := range templates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			variant := *config
+			variant.Commit.PromptTemplate = tmpl
+			result, err := generateCommitMessage(context.Background(), &variant, diff, "")
+			results[i] = result
+			if err != nil {
+				errs[i] = fmt.Errorf("template %c: %w", 'A'+i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	utils.UpdateCost(float64(results[0].Cost + results[1].Cost))
+	return results[0].Message, results[1].Message, errors.Join(errs[:]...)
+}
@@ -0,0 +1,165 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var diffPathRegex = regexp.MustCompile(`diff --git a/(\S+)`)
+
+// replyByPath answers with a message naming the first file in the diff.
+func replyByPath(w http.ResponseWriter, n int, req chatRequest) {
+	path := diffPathRegex.FindStringSubmatch(req.LastUser())[1]
+	writeCompletion(w, "feat: update "+path)
+}
+
+func TestGenerateCommitMessages(t *testing.T) {
+	diffs := map[string]string{
+		"api":  testDiff("api.go"),
+		"ui":   testDiff("ui.go"),
+		"db":   testDiff("db.go"),
+		"docs": testDiff("docs.go"),
+	}
+
+	t.Run("concurrent success", func(t *testing.T) {
+		// Every request waits until all of them arrived, which only works if
+		// they are sent concurrently
+		var arrived sync.WaitGroup
+		arrived.Add(len(diffs))
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			arrived.Done()
+			waitTimeout(&arrived, 5*time.Second)
+			replyByPath(w, n, req)
+		})
+
+		messages, err := GenerateCommitMessages(context.Background(), server.Config(), diffs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for key := range diffs {
+			if want := "feat: update " + key + ".go\n"; messages[key] != want {
+				t.Errorf("messages[%q] = %q, want %q", key, messages[key], want)
+			}
+		}
+	})
+
+	t.Run("partial failure", func(t *testing.T) {
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			if strings.Contains(req.LastUser(), "a/ui.go") {
+				writeError(w, http.StatusBadRequest, "bad request")
+				return
+			}
+			replyByPath(w, n, req)
+		})
+
+		messages, err := GenerateCommitMessages(context.Background(), server.Config(), diffs)
+		if err == nil || !strings.Contains(err.Error(), "ui: ") {
+			t.Errorf("error = %v, want one for ui", err)
+		}
+		if _, ok := messages["ui"]; ok || len(messages) != len(diffs)-1 {
+			t.Errorf("messages = %q, want all but ui", messages)
+		}
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		server := newMockOpenAI(t, replyByPath)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		messages, err := GenerateCommitMessages(ctx, server.Config(), diffs)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+		if len(messages) != 0 {
+			t.Errorf("messages = %q, want none", messages)
+		}
+	})
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+	}
+}
+
+func TestGeneratePerFileMessages(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		// Each request only carries its own file
+		if strings.Count(req.LastUser(), "diff --git") != 1 {
+			writeError(w, http.StatusBadRequest, "expected a single-file diff")
+			return
+		}
+		replyByPath(w, n, req)
+	})
+
+	messages, err := GeneratePerFileMessages(server.Config(), testDiff("api/handler.go", "web/app.tsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"api/handler.go": "feat: update api/handler.go\n",
+		"web/app.tsx":    "feat: update web/app.tsx\n",
+	}
+	if !reflect.DeepEqual(messages, want) {
+		t.Errorf("messages = %q, want %q", messages, want)
+	}
+	if got := len(server.Requests()); got != 2 {
+		t.Errorf("sent %d requests, want 2", got)
+	}
+}
+
+func TestCompareGenerations(t *testing.T) {
+	t.Run("distinct outputs", func(t *testing.T) {
+		// Both requests wait for each other, which only works if they are sent
+		// concurrently
+		var arrived sync.WaitGroup
+		arrived.Add(2)
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			arrived.Done()
+			waitTimeout(&arrived, 5*time.Second)
+			switch prompt := req.LastUser(); {
+			case strings.HasPrefix(prompt, "template A"):
+				writeCompletion(w, "feat: add a")
+			case strings.HasPrefix(prompt, "template B"):
+				writeCompletion(w, "fix: add b")
+			default:
+				writeError(w, http.StatusBadRequest, "unexpected prompt")
+			}
+		})
+
+		a, b, err := CompareGenerations(server.Config(), testDiff("main.go"),
+			"template A {{.FileCount}}", "template B {{.FileCount}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a != "feat: add a\n" || b != "fix: add b\n" {
+			t.Errorf("CompareGenerations() = %q, %q", a, b)
+		}
+	})
+
+	t.Run("one template fails", func(t *testing.T) {
+		server := newMockOpenAI(t, replyWith("feat: add x"))
+
+		a, _, err := CompareGenerations(server.Config(), testDiff("main.go"), "", "{{.NoSuchField}}")
+		if !errors.Is(err, ErrInvalidPromptTemplate) || !strings.Contains(err.Error(), "template B") {
+			t.Errorf("error = %v, want an invalid template B", err)
+		}
+		if a != "feat: add x\n" {
+			t.Errorf("A = %q, want the built-in prompt's message", a)
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go"
+)
+
+const (
+	bedrockService          = "bedrock"
+	bedrockMaxTokens        = 1024
+	bedrockAnthropicVersion = "bedrock-2023-05-31"
+)
+
+// BedrockProvider talks to models hosted on AWS Bedrock through the
+// bedrock-runtime InvokeModel API.
+type BedrockProvider struct {
+	Region      string
+	Model       string
+	HTTPClient  *http.Client
+	Credentials aws.CredentialsProvider
+	Signer      *v4.Signer
+	// MaxRequestBytes rejects larger request bodies before they are signed;
+	// zero disables the check
+	MaxRequestBytes int
+}
+
+// NewBedrockProvider resolves credentials through the standard AWS credential
+// chain (env vars, shared config, SSO, instance roles, ...).
+func NewBedrockProvider(ctx context.Context, llmConfig utils.LLMConfig) (*BedrockProvider, error) {
+	var opts []func(*awsconfig.LoadOptions) error
+	if llmConfig.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(llmConfig.Region))
+	}
+
+	awsConfig, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, &BedrockRequestError{Err: err}
+	}
+	if awsConfig.Region == "" {
+		return nil, &BedrockRequestError{Err: fmt.Errorf("no AWS region configured (set llm.region or AWS_REGION)")}
+	}
+
+	httpClient, err := newHTTPClient(llmConfig)
+	if err != nil {
+		return nil, err
+	}
+	httpClient.Timeout = responseTimeout(llmConfig)
+
+	return &BedrockProvider{
+		Region:          awsConfig.Region,
+		Model:           llmConfig.Model,
+		HTTPClient:      httpClient,
+		Credentials:     awsConfig.Credentials,
+		Signer:          v4.NewSigner(),
+		MaxRequestBytes: llmConfig.MaxRequestBytes,
+	}, nil
+}
+
+// Endpoint returns the InvokeModel URL for the configured model and region.
+func (p *BedrockProvider) Endpoint() string {
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/invoke", p.Region, url.PathEscape(p.Model))
+}
+
+// Chat sends a single system + user prompt to the model and returns the text
+// of its reply.
+func (p *BedrockProvider) Chat(ctx context.Context, system, prompt string, temperature float64) (string, error) {
+	return p.ChatTurns(ctx, system, []ChatTurn{{Role: RoleUser, Content: prompt}}, temperature)
+}
+
+// ChatTurns sends a whole conversation to the model and returns the text of
+// its reply to the last turn.
+func (p *BedrockProvider) ChatTurns(ctx context.Context, system string, turns []ChatTurn, temperature float64) (string, error) {
+	body, err := p.requestBody(system, turns, temperature)
+	if err != nil {
+		return "", err
+	}
+	if err := checkBodySize(body, p.MaxRequestBytes); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return "", &BedrockRequestError{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	creds, err := p.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", &BedrockRequestError{Err: err}
+	}
+	payloadHash := sha256.Sum256(body)
+	err = p.Signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), bedrockService, p.Region, time.Now())
+	if err != nil {
+		return "", &BedrockRequestError{Err: err}
+	}
+
+	release, err := acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	start := time.Now()
+	resp, err := p.HTTPClient.Do(req)
+	if err != nil {
+		return "", &BedrockRequestError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", &BedrockRequestError{Err: err}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &BedrockRequestError{
+			Err:        fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody))),
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	text, usage, err := p.parseResponse(respBody)
+	if err != nil {
+		return "", err
+	}
+	reportMetrics(ctx, Metrics{
+		Model:            p.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Latency:          time.Since(start),
+	})
+	return text, nil
+}
+
+func (p *BedrockProvider) isAnthropic() bool {
+	return strings.Contains(p.Model, "anthropic.")
+}
+
+func (p *BedrockProvider) isLlama() bool {
+	return strings.Contains(p.Model, "meta.llama")
+}
+
+// requestBody maps the conversation into the model family's native request
+// format.
+func (p *BedrockProvider) requestBody(system string, turns []ChatTurn, temperature float64) ([]byte, error) {
+	switch {
+	case p.isAnthropic():
+		messages := make([]map[string]string, len(turns))
+		for i, turn := range turns {
+			messages[i] = map[string]string{"role": turn.Role, "content": turn.Content}
+		}
+		return json.Marshal(map[string]any{
+			"anthropic_version": bedrockAnthropicVersion,
+			"max_tokens":        bedrockMaxTokens,
+			"temperature":       temperature,
+			"system":            system,
+			"messages":          messages,
+		})
+	case p.isLlama():
+		prompt := "<|begin_of_text|>" + llamaTurn("system", system)
+		prime := primeOf(turns)
+		if prime != "" {
+			turns = turns[:len(turns)-1]
+		}
+		for _, turn := range turns {
+			prompt += llamaTurn(turn.Role, turn.Content)
+		}
+		// A prime stays open so the model continues right after it
+		prompt += "<|start_header_id|>assistant<|end_header_id|>\n\n" + prime
+		return json.Marshal(map[string]any{
+			"prompt":      prompt,
+			"max_gen_len": bedrockMaxTokens,
+			"temperature": temperature,
+		})
+	default:
+		return nil, UnsupportedBedrockModelError{Model: p.Model}
+	}
+}
+
+func llamaTurn(role, content string) string {
+	return "<|start_header_id|>" + role + "<|end_header_id|>\n\n" + content + "<|eot_id|>"
+}
+
+func (p *BedrockProvider) parseResponse(body []byte) (string, openai.CompletionUsage, error) {
+	switch {
+	case p.isAnthropic():
+		var resp struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+			Usage struct {
+				InputTokens  int64 `json:"input_tokens"`
+				OutputTokens int64 `json:"output_tokens"`
+			} `json:"usage"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", openai.CompletionUsage{}, &JSONParseError{Err: err}
+		}
+		var text strings.Builder
+		for _, block := range resp.Content {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+		usage := openai.CompletionUsage{PromptTokens: resp.Usage.InputTokens, CompletionTokens: resp.Usage.OutputTokens}
+		return text.String(), usage, nil
+	case p.isLlama():
+		var resp struct {
+			Generation           string `json:"generation"`
+			PromptTokenCount     int64  `json:"prompt_token_count"`
+			GenerationTokenCount int64  `json:"generation_token_count"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return "", openai.CompletionUsage{}, &JSONParseError{Err: err}
+		}
+		usage := openai.CompletionUsage{PromptTokens: resp.PromptTokenCount, CompletionTokens: resp.GenerationTokenCount}
+		return resp.Generation, usage, nil
+	default:
+		return "", openai.CompletionUsage{}, UnsupportedBedrockModelError{Model: p.Model}
+	}
+}
+
+// bedrockProviderKey holds the settings a BedrockProvider is built from.
+type bedrockProviderKey struct {
+	region, model, caCertFile, userAgent string
+	connectTimeout, responseTimeout      time.Duration
+	maxRequestBytes                      int
+}
+
+type bedrockProviderEntry struct {
+	ready    chan struct{}
+	provider *BedrockProvider
+	err      error
+}
+
+var (
+	bedrockProvidersMu sync.Mutex
+	bedrockProviders   = make(map[bedrockProviderKey]*bedrockProviderEntry)
+)
+
+// sharedBedrockProvider returns the provider for llmConfig, creating it on
+// first use. Providers are kept for the rest of the process, so retries,
+// re-prompts and batches don't resolve the AWS credential chain again.
+// Concurrent first callers wait for a single creation, and failures aren't
+// kept.
+func sharedBedrockProvider(ctx context.Context, llmConfig utils.LLMConfig) (*BedrockProvider, error) {
+	key := bedrockProviderKey{
+		region:          llmConfig.Region,
+		model:           llmConfig.Model,
+		caCertFile:      llmConfig.CACertFile,
+		userAgent:       llmConfig.UserAgent,
+		connectTimeout:  llmConfig.ConnectTimeout,
+		responseTimeout: llmConfig.ResponseTimeout,
+		maxRequestBytes: llmConfig.MaxRequestBytes,
+	}
+
+	bedrockProvidersMu.Lock()
+	entry, ok := bedrockProviders[key]
+	if !ok {
+		entry = &bedrockProviderEntry{ready: make(chan struct{})}
+		bedrockProviders[key] = entry
+	}
+	bedrockProvidersMu.Unlock()
+
+	if !ok {
+		entry.provider, entry.err = NewBedrockProvider(ctx, llmConfig)
+		if entry.err != nil {
+			bedrockProvidersMu.Lock()
+			delete(bedrockProviders, key)
+			bedrockProvidersMu.Unlock()
+		}
+		close(entry.ready)
+	}
+
+	select {
+	case <-entry.ready:
+		return entry.provider, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func bedrockChat(ctx context.Context, llmConfig utils.LLMConfig, system string, turns []ChatTurn) (ChatResult[string], error) {
+	provider, err := sharedBedrockProvider(ctx, llmConfig)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+
+	message, err := provider.ChatTurns(ctx, system, turns, effectiveTemperature(llmConfig))
+	recordExchange(ctx, turns, message, err)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+	return ChatResult[string]{Message: message}, nil
+}
+
+// bedrockChatStructured embeds the schema in the prompt, since Bedrock has no
+// native JSON schema enforcement, and parses the JSON out of the reply.
+func bedrockChatStructured[T any](ctx context.Context, llmConfig utils.LLMConfig, prompt string, schema any) (ChatResult[T], error) {
+	embedded, err := schemaPrompt(schema)
+	if err != nil {
+		return ChatResult[T]{}, err
+	}
+	prompt += embedded
+
+	raw, err := bedrockChat(ctx, llmConfig, kommitSystemPrompt+jsonResponsePrompt, []ChatTurn{{Role: RoleUser, Content: prompt}})
+	if err != nil {
+		return ChatResult[T]{}, err
+	}
+
+	result, err := parseStructuredWithSchema[T](raw.Message, schema)
+	if errors.Is(err, ErrSchemaViolation) {
+		return ChatResult[T]{}, err
+	}
+	if err != nil {
+		return ChatResult[T]{}, &JSONParseError{Err: err}
+	}
+	return ChatResult[T]{Message: result}, nil
+}
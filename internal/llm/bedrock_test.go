@@ -0,0 +1,243 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestBedrockProviderRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		model        string
+		response     string
+		wantPath     string
+		wantBodyKeys []string
+	}{
+		{
+			name:         "claude",
+			model:        "anthropic.claude-3-haiku-20240307-v1:0",
+			response:     `{"content":[{"type":"text","text":"feat: add x"}],"usage":{"input_tokens":12,"output_tokens":4}}`,
+			wantPath:     "/model/anthropic.claude-3-haiku-20240307-v1:0/invoke",
+			wantBodyKeys: []string{"anthropic_version", "max_tokens", "messages", "system", "temperature"},
+		},
+		{
+			name:         "llama",
+			model:        "meta.llama3-8b-instruct-v1:0",
+			response:     `{"generation":"feat: add x","prompt_token_count":12,"generation_token_count":4}`,
+			wantPath:     "/model/meta.llama3-8b-instruct-v1:0/invoke",
+			wantBodyKeys: []string{"max_gen_len", "prompt", "temperature"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent *http.Request
+			var body map[string]any
+			provider := testBedrockProvider(func(req *http.Request) (*http.Response, error) {
+				sent = req
+				raw, _ := io.ReadAll(req.Body)
+				json.Unmarshal(raw, &body)
+				return bedrockResponse(tt.response), nil
+			})
+			provider.Region = "eu-central-1"
+			provider.Model = tt.model
+
+			got, err := provider.Chat(context.Background(), kommitSystemPrompt, "prompt", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "feat: add x" {
+				t.Errorf("Chat() = %q, want %q", got, "feat: add x")
+			}
+
+			if sent.URL.Host != "bedrock-runtime.eu-central-1.amazonaws.com" || sent.URL.Path != tt.wantPath {
+				t.Errorf("request sent to %s, want bedrock-runtime.eu-central-1.amazonaws.com%s", sent.URL, tt.wantPath)
+			}
+			auth := sent.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") || !strings.Contains(auth, "/eu-central-1/bedrock/aws4_request") {
+				t.Errorf("Authorization = %q, want a SigV4 signature for eu-central-1/bedrock", auth)
+			}
+			if sent.Header.Get("X-Amz-Date") == "" {
+				t.Error("X-Amz-Date header missing")
+			}
+			for _, key := range tt.wantBodyKeys {
+				if _, ok := body[key]; !ok {
+					t.Errorf("request body %v has no %q", body, key)
+				}
+			}
+		})
+	}
+}
+
+func TestBedrockLlamaPrompt(t *testing.T) {
+	tests := []struct {
+		name       string
+		turns      []ChatTurn
+		wantSuffix string
+	}{
+		{
+			name:       "unprimed",
+			turns:      []ChatTurn{{Role: RoleUser, Content: "prompt"}},
+			wantSuffix: "prompt<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name:       "primed",
+			turns:      []ChatTurn{{Role: RoleUser, Content: "prompt"}, {Role: RoleAssistant, Content: "fix("}},
+			wantSuffix: "prompt<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\nfix(",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]any
+			provider := testBedrockProvider(func(req *http.Request) (*http.Response, error) {
+				raw, _ := io.ReadAll(req.Body)
+				json.Unmarshal(raw, &body)
+				return bedrockResponse(`{"generation":"api): add x","prompt_token_count":12,"generation_token_count":4}`), nil
+			})
+			provider.Model = "meta.llama3-8b-instruct-v1:0"
+
+			if _, err := provider.ChatTurns(context.Background(), kommitSystemPrompt, tt.turns, 0); err != nil {
+				t.Fatal(err)
+			}
+			prompt, _ := body["prompt"].(string)
+			if !strings.HasSuffix(prompt, tt.wantSuffix) {
+				t.Errorf("prompt = %q, want it to end with %q", prompt, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestBedrockProviderErrors(t *testing.T) {
+	t.Run("unsupported model", func(t *testing.T) {
+		provider := testBedrockProvider(func(req *http.Request) (*http.Response, error) {
+			t.Fatal("request sent for an unsupported model")
+			return nil, nil
+		})
+		provider.Model = "cohere.command-r-v1:0"
+
+		_, err := provider.Chat(context.Background(), kommitSystemPrompt, "prompt", 0)
+		if !errors.Is(err, ErrUnsupportedModel) {
+			t.Errorf("error = %v, want ErrUnsupportedModel", err)
+		}
+	})
+
+	t.Run("throttled", func(t *testing.T) {
+		provider := testBedrockProvider(func(req *http.Request) (*http.Response, error) {
+			resp := bedrockResponse(`{"message":"Too many requests"}`)
+			resp.StatusCode, resp.Status = http.StatusTooManyRequests, "429 Too Many Requests"
+			return resp, nil
+		})
+
+		_, err := provider.Chat(context.Background(), kommitSystemPrompt, "prompt", 0)
+		var bedrockErr *BedrockRequestError
+		if !errors.As(err, &bedrockErr) || bedrockErr.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("error = %v, want a BedrockRequestError with status 429", err)
+		}
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("error = %v, want it to match ErrRateLimited", err)
+		}
+	})
+}
+
+func TestResolveBedrockRegion(t *testing.T) {
+	tests := []struct {
+		name          string
+		region        string
+		awsRegion     string
+		defaultRegion string
+		wantRegion    string
+		wantBaseURL   string
+	}{
+		{"config", "us-east-1", "eu-west-1", "ap-south-1", "us-east-1", "https://bedrock-runtime.us-east-1.amazonaws.com/"},
+		{"AWS_REGION", "", "eu-west-1", "ap-south-1", "eu-west-1", "https://bedrock-runtime.eu-west-1.amazonaws.com/"},
+		{"AWS_DEFAULT_REGION", "", "", "ap-south-1", "ap-south-1", "https://bedrock-runtime.ap-south-1.amazonaws.com/"},
+		{"unknown", "", "", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("KOMMIT_LLM_PROVIDER", "")
+			t.Setenv("KOMMIT_LLM_MODEL", "")
+			t.Setenv("AWS_REGION", tt.awsRegion)
+			t.Setenv("AWS_DEFAULT_REGION", tt.defaultRegion)
+
+			resolved, err := resolveLLMConfig(utils.LLMConfig{
+				Provider: utils.ProviderBedrock,
+				Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+				Region:   tt.region,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resolved.Region != tt.wantRegion || resolved.BaseURL != tt.wantBaseURL {
+				t.Errorf("resolved region %q and base URL %q, want %q and %q", resolved.Region, resolved.BaseURL, tt.wantRegion, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+func TestSharedBedrockProvider(t *testing.T) {
+	// Static credentials, so the default config loads without touching AWS
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+
+	ctx := context.Background()
+	llmConfig := utils.LLMConfig{Provider: utils.ProviderBedrock, Model: "anthropic.claude-3-haiku-20240307-v1:0", Region: "us-east-1"}
+
+	first, err := sharedBedrockProvider(ctx, llmConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := sharedBedrockProvider(ctx, llmConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("provider was created again for the same config")
+	}
+
+	llmConfig.Region = "us-west-2"
+	other, err := sharedBedrockProvider(ctx, llmConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first || other.Region != "us-west-2" {
+		t.Errorf("provider for another region = %+v, want a new one in us-west-2", other)
+	}
+}
+
+// testBedrockProvider returns a Claude provider in us-west-2 with static
+// credentials whose requests are answered by transport.
+func testBedrockProvider(transport roundTripFunc) *BedrockProvider {
+	return &BedrockProvider{
+		Region:     "us-west-2",
+		Model:      "anthropic.claude-3-haiku-20240307-v1:0",
+		HTTPClient: &http.Client{Transport: transport},
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+		}),
+		Signer: v4.NewSigner(),
+	}
+}
+
+func bedrockResponse(body string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+type budgetKey struct{}
+
+// attemptBudget counts the HTTP requests sent to the provider for one
+// generation, including the SDK's own retries, and optionally caps them.
+type attemptBudget struct {
+	mu     sync.Mutex
+	limit  int
+	used   int
+	errs   []error
+	err    error
+	cancel context.CancelCauseFunc
+}
+
+// withAttemptBudget returns a context whose API calls share a budget of limit
+// attempts, or are only counted if limit is zero. Once the budget is spent the
+// context is cancelled, so that no further retries are started. The returned
+// function releases the context.
+func withAttemptBudget(ctx context.Context, limit int) (context.Context, *attemptBudget, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	budget := &attemptBudget{limit: limit, cancel: cancel}
+	return context.WithValue(ctx, budgetKey{}, budget), budget, func() { cancel(nil) }
+}
+
+// take reserves one attempt. Once the budget is spent it cancels the context
+// and returns the aggregated error instead.
+func (b *attemptBudget) take() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.limit <= 0 || b.used < b.limit {
+		b.used++
+		return nil
+	}
+	if b.err == nil {
+		b.err = AttemptBudgetError{Budget: b.limit, Errs: slices.Clone(b.errs)}
+		b.cancel(b.err)
+	}
+	return b.err
+}
+
+// attempts returns the number of requests sent so far.
+func (b *attemptBudget) attempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+func (b *attemptBudget) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errs = append(b.errs, err)
+}
+
+// exhausted returns an AttemptBudgetError when an attempt was refused because
+// the budget was spent, and nil otherwise.
+func (b *attemptBudget) exhausted() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// budgetTransport enforces the attempt budget carried by a request's context,
+// if any, and records failed attempts for the aggregated error.
+type budgetTransport struct {
+	base http.RoundTripper
+}
+
+func (t budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	budget, ok := req.Context().Value(budgetKey{}).(*attemptBudget)
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+
+	if err := budget.take(); err != nil {
+		return nil, err
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		budget.fail(err)
+	} else if res.StatusCode >= 400 {
+		budget.fail(fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, res.Status))
+	}
+	return res, err
+}
@@ -0,0 +1,208 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeRetryable answers with an error the SDK retries, asking it not to
+// wait in between.
+func writeRetryable(w http.ResponseWriter) {
+	w.Header().Set("Retry-After-Ms", "0")
+	writeError(w, http.StatusServiceUnavailable, "overloaded")
+}
+
+func TestTotalAttemptBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		budget    int
+		handler   func(w http.ResponseWriter, n int, req chatRequest)
+		wantErr   error
+		wantCalls int
+		// wantFailures is the number of failed attempts in the budget error
+		wantFailures int
+	}{
+		{
+			name:      "unlimited with SDK retries",
+			budget:    0,
+			handler:   func(w http.ResponseWriter, n int, req chatRequest) { writeRetryable(w) },
+			wantErr:   ErrRequestFailed,
+			wantCalls: 3,
+		},
+		{
+			name:         "SDK retries capped",
+			budget:       2,
+			handler:      func(w http.ResponseWriter, n int, req chatRequest) { writeRetryable(w) },
+			wantErr:      ErrAttemptBudget,
+			wantCalls:    2,
+			wantFailures: 2,
+		},
+		{
+			name:   "empty-message retry capped",
+			budget: 1,
+			handler: func(w http.ResponseWriter, n int, req chatRequest) {
+				writeCompletion(w, "")
+			},
+			wantErr:   ErrAttemptBudget,
+			wantCalls: 1,
+		},
+		{
+			name:   "failing request then empty-message retry",
+			budget: 2,
+			handler: func(w http.ResponseWriter, n int, req chatRequest) {
+				if n == 0 {
+					writeRetryable(w)
+					return
+				}
+				writeCompletion(w, "")
+			},
+			wantErr:      ErrAttemptBudget,
+			wantCalls:    2,
+			wantFailures: 1,
+		},
+		{
+			name:   "within the budget",
+			budget: 3,
+			handler: func(w http.ResponseWriter, n int, req chatRequest) {
+				if n == 0 {
+					writeRetryable(w)
+					return
+				}
+				writeCompletion(w, "feat: add x")
+			},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, tt.handler)
+			config := server.Config()
+			config.LLM.TotalAttemptBudget = tt.budget
+
+			result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := len(server.Requests()); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+			if tt.budget > 0 && len(server.Requests()) > tt.budget {
+				t.Errorf("sent %d requests, more than the budget of %d", len(server.Requests()), tt.budget)
+			}
+			if result.APICallCount != tt.wantCalls {
+				t.Errorf("APICallCount = %d, want %d", result.APICallCount, tt.wantCalls)
+			}
+
+			var budgetErr AttemptBudgetError
+			if errors.As(err, &budgetErr) {
+				if budgetErr.Budget != tt.budget || len(budgetErr.Errs) != tt.wantFailures {
+					t.Errorf("budget error = %+v, want budget %d with %d failures", budgetErr, tt.budget, tt.wantFailures)
+				}
+			}
+		})
+	}
+}
+
+func TestAPICallCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		requireScope bool
+		maxLength    int
+		handler      func(w http.ResponseWriter, n int, req chatRequest)
+		want         int
+	}{
+		{
+			name:    "single call",
+			handler: replyWith("feat(api): add x"),
+			want:    1,
+		},
+		{
+			name: "SDK retry",
+			handler: func(w http.ResponseWriter, n int, req chatRequest) {
+				if n == 0 {
+					writeRetryable(w)
+					return
+				}
+				writeCompletion(w, "feat(api): add x")
+			},
+			want: 2,
+		},
+		{
+			name:         "missing scope re-prompt",
+			requireScope: true,
+			handler:      replyWith("feat: add x", "feat(api): add x"),
+			want:         2,
+		},
+		{
+			name:      "too long re-prompt",
+			maxLength: 30,
+			handler:   replyWith("feat(api): add x\n\n- Add a long explanation of x", "feat(api): add x"),
+			want:      2,
+		},
+		{
+			name:         "retries and re-prompts",
+			requireScope: true,
+			handler: func(w http.ResponseWriter, n int, req chatRequest) {
+				switch n {
+				case 0:
+					writeRetryable(w)
+				case 1:
+					writeCompletion(w, "")
+				case 2:
+					writeCompletion(w, "feat: add x")
+				default:
+					writeCompletion(w, "feat(api): add x")
+				}
+			},
+			want: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, tt.handler)
+			config := server.Config()
+			config.Commit.RequireScope = tt.requireScope
+			config.Commit.Scopes = []string{"api"}
+			config.Commit.MaxTotalLength = tt.maxLength
+
+			result, err := GenerateCommitMessage(config, testDiff("api/x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.APICallCount != tt.want {
+				t.Errorf("APICallCount = %d, want %d", result.APICallCount, tt.want)
+			}
+			if got := len(server.Requests()); got != result.APICallCount {
+				t.Errorf("server got %d requests, but APICallCount is %d", got, result.APICallCount)
+			}
+		})
+	}
+}
+
+func TestAttemptBudgetOptionalReprompt(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("feat(api): add x\n\n- Add a long explanation of x", "feat(api): add x"))
+	config := server.Config()
+	config.LLM.TotalAttemptBudget = 1
+	config.Commit.MaxTotalLength = 30
+
+	// The shorter-message re-prompt is refused, so the message is truncated
+	result, err := GenerateCommitMessage(config, testDiff("api/x.go"), "")
+	if err != nil {
+		t.Fatalf("error = %v, want the best message so far", err)
+	}
+	if result.Message != "feat(api): add x\n" {
+		t.Errorf("message = %q", result.Message)
+	}
+	if got := len(server.Requests()); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+	if !slices.ContainsFunc(result.Warnings, func(w string) bool { return strings.Contains(w, "totalAttemptBudget") }) {
+		t.Errorf("warnings = %q, want one about the spent budget", result.Warnings)
+	}
+}
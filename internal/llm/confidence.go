@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go"
+)
+
+const (
+	alternativeCount       = 2
+	alternativeTemperature = 0.8
+)
+
+// Confidence scores a generation from its token log probabilities as the
+// geometric mean of the token probabilities, in [0, 1]. It returns -1 when no
+// log probabilities are available, e.g. for Bedrock models.
+func Confidence(logprobs []openai.ChatCompletionTokenLogprob) float64 {
+	if len(logprobs) == 0 {
+		return -1
+	}
+	var sum float64
+	for _, lp := range logprobs {
+		sum += lp.Logprob
+	}
+	return math.Exp(sum / float64(len(logprobs)))
+}
+
+// withAlternatives generates a few more candidates at a higher temperature
+// when the result's confidence is below commit.minConfidence. Candidates that
+// fail validation or repeat an earlier message are dropped.
+func withAlternatives(ctx context.Context, config *utils.Config, prompt string, result ChatResult[string]) ChatResult[string] {
+	result.Confidence = Confidence(result.Logprobs)
+	if result.Confidence < 0 || result.Confidence >= config.Commit.MinConfidence {
+		return result
+	}
+
+	llmConfig := config.LLM
+	llmConfig.IncludeLogprobs = false
+	t := alternativeTemperature
+	llmConfig.Temperature = &t
+
+	seen := map[string]bool{strings.TrimSpace(result.Message): true}
+	for range alternativeCount {
+		alternative, err := chatNonEmpty(ctx, llmConfig, prompt)
+		result.Cost += alternative.Cost
+		if err != nil {
+			continue
+		}
+		message := formatCommitMessage(config.Commit, alternative.Message)
+		if seen[strings.TrimSpace(message)] || validateCommitMessage(config.Commit, message) != nil {
+			continue
+		}
+		seen[strings.TrimSpace(message)] = true
+		result.Alternatives = append(result.Alternatives, message)
+	}
+	return result
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"math"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+// writeWithLogprobs answers with content whose tokens each have logprob.
+func writeWithLogprobs(w http.ResponseWriter, content string, logprob float64) {
+	body := completionBody(content)
+	body["choices"].([]map[string]any)[0]["logprobs"] = map[string]any{
+		"content": []map[string]any{
+			{"token": "feat", "logprob": logprob, "bytes": nil, "top_logprobs": []any{}},
+			{"token": ":", "logprob": logprob, "bytes": nil, "top_logprobs": []any{}},
+		},
+		"refusal": nil,
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func TestMinConfidence(t *testing.T) {
+	alternatives := []string{"fix: patch x", "refactor: rework x"}
+
+	tests := []struct {
+		name             string
+		logprob          float64
+		wantAlternatives []string
+	}{
+		{"low confidence", -2, []string{"fix: patch x\n", "refactor: rework x\n"}},
+		{"high confidence", -0.01, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				if n == 0 {
+					writeWithLogprobs(w, "feat: add x", tt.logprob)
+					return
+				}
+				writeCompletion(w, alternatives[min(n-1, len(alternatives)-1)])
+			})
+			config := server.Config()
+			config.Commit.MinConfidence = 0.5
+
+			result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != "feat: add x\n" {
+				t.Errorf("message = %q", result.Message)
+			}
+			if want := math.Exp(tt.logprob); math.Abs(result.Confidence-want) > 1e-9 {
+				t.Errorf("confidence = %v, want %v", result.Confidence, want)
+			}
+			if !reflect.DeepEqual(result.Alternatives, tt.wantAlternatives) {
+				t.Errorf("alternatives = %q, want %q", result.Alternatives, tt.wantAlternatives)
+			}
+
+			requests := server.Requests()
+			if len(requests) != 1+len(tt.wantAlternatives) {
+				t.Fatalf("sent %d requests, want %d", len(requests), 1+len(tt.wantAlternatives))
+			}
+			if requests[0].Raw["logprobs"] != true {
+				t.Error("first request doesn't ask for logprobs")
+			}
+			for _, req := range requests[1:] {
+				if req.Raw["temperature"] != alternativeTemperature {
+					t.Errorf("alternative temperature = %v, want %v", req.Raw["temperature"], alternativeTemperature)
+				}
+			}
+		})
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		logprobs []openai.ChatCompletionTokenLogprob
+		want     float64
+	}{
+		{"no logprobs", nil, -1},
+		{"certain", []openai.ChatCompletionTokenLogprob{{Logprob: 0}, {Logprob: 0}}, 1},
+		{"geometric mean", []openai.ChatCompletionTokenLogprob{{Logprob: math.Log(0.5)}, {Logprob: math.Log(0.125)}}, 0.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Confidence(tt.logprobs); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Confidence() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
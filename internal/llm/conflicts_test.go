@@ -0,0 +1,44 @@
+package llm
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestConflictMarkers(t *testing.T) {
+	conflicted := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n@@ -1,1 +1,6 @@\n package main\n" +
+		"+<<<<<<< HEAD\n+var x = 1\n+=======\n+var x = 2\n+>>>>>>> feature\n"
+
+	tests := []struct {
+		name      string
+		diff      string
+		allow     bool
+		wantFiles []string
+		wantCalls int
+	}{
+		{"conflict aborts before the request", testDiff("a.go") + conflicted, false, []string{"main.go"}, 0},
+		{"allowed conflict is sent", conflicted, true, nil, 1},
+		{"clean diff is sent", testDiff("main.go"), false, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.Commit.AllowConflictMarkers = tt.allow
+
+			_, err := GenerateCommitMessage(config, tt.diff, "")
+			var conflictErr ConflictMarkersError
+			if got := errors.As(err, &conflictErr); got != (tt.wantFiles != nil) {
+				t.Fatalf("error = %v, want ConflictMarkersError: %v", err, tt.wantFiles != nil)
+			}
+			if !reflect.DeepEqual(conflictErr.Files, tt.wantFiles) {
+				t.Errorf("files = %q, want %q", conflictErr.Files, tt.wantFiles)
+			}
+			if got := len(server.Requests()); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
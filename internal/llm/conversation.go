@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+
+	defaultMaxRefinementTurns = 5
+)
+
+type ChatTurn struct {
+	Role    string
+	Content string
+}
+
+// Conversation keeps the whole history of a commit message and its
+// refinements, so every refinement is made with all previous feedback in mind.
+type Conversation struct {
+	config *utils.Config
+	prompt string
+	turns  []ChatTurn
+}
+
+// NewConversation generates the first commit message for diff and returns a
+// conversation that can refine it.
+func NewConversation(config *utils.Config, diff, userContext string) (*Conversation, string, error) {
+	diff, err := prepareDiff(config, diff)
+	if err != nil {
+		return nil, "", err
+	}
+	prompt, err := commitPrompt(config, diff, userContext)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := &Conversation{config: config, prompt: prompt}
+
+	result, err := chatNonEmpty(context.Background(), config.LLM, c.prompt)
+	utils.UpdateCost(float64(result.Cost))
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.turns = append(c.turns, ChatTurn{Role: RoleAssistant, Content: result.Message})
+	return c, formatCommitMessage(config.Commit, result.Message), nil
+}
+
+// Refine asks the model to revise its last message based on feedback. The
+// original prompt is always sent, followed by at most commit.maxRefinementTurns
+// of the most recent message/feedback exchanges.
+func (c *Conversation) Refine(feedback string) (string, error) {
+	c.turns = append(c.turns, ChatTurn{Role: RoleUser, Content: feedback})
+
+	result, err := chatTurns(context.Background(), c.config.LLM, c.history())
+	utils.UpdateCost(float64(result.Cost))
+	if err != nil {
+		c.turns = c.turns[:len(c.turns)-1]
+		return "", err
+	}
+
+	c.turns = append(c.turns, ChatTurn{Role: RoleAssistant, Content: result.Message})
+	return formatCommitMessage(c.config.Commit, result.Message), nil
+}
+
+// Turns returns the number of refinement exchanges so far.
+func (c *Conversation) Turns() int {
+	return len(c.turns) / 2
+}
+
+func (c *Conversation) history() []ChatTurn {
+	maxTurns := c.config.Commit.MaxRefinementTurns
+	if maxTurns <= 0 {
+		maxTurns = defaultMaxRefinementTurns
+	}
+
+	// Each exchange is an assistant message followed by user feedback
+	turns := c.turns
+	if keep := 2 * maxTurns; len(turns) > keep {
+		turns = turns[len(turns)-keep:]
+	}
+
+	history := make([]ChatTurn, 0, len(turns)+1)
+	history = append(history, ChatTurn{Role: RoleUser, Content: c.prompt})
+	return append(history, turns...)
+}
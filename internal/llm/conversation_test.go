@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConversationRefine(t *testing.T) {
+	replies := []string{"feat: add x", "feat: add x to y", "feat(api): add x to y", "feat(api): add x to the y endpoint"}
+	feedback := []string{"mention y", "use the api scope", "say endpoint"}
+
+	tests := []struct {
+		name     string
+		maxTurns int
+		// want are the roles and contents sent with the last refinement,
+		// after the system prompt
+		want []ChatTurn
+	}{
+		{
+			name: "all turns",
+			want: []ChatTurn{
+				{RoleUser, "<prompt>"},
+				{RoleAssistant, replies[0]}, {RoleUser, feedback[0]},
+				{RoleAssistant, replies[1]}, {RoleUser, feedback[1]},
+				{RoleAssistant, replies[2]}, {RoleUser, feedback[2]},
+			},
+		},
+		{
+			name:     "capped history",
+			maxTurns: 2,
+			want: []ChatTurn{
+				{RoleUser, "<prompt>"},
+				{RoleAssistant, replies[1]}, {RoleUser, feedback[1]},
+				{RoleAssistant, replies[2]}, {RoleUser, feedback[2]},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(replies...))
+			config := server.Config()
+			config.Commit.MaxRefinementTurns = tt.maxTurns
+
+			conversation, message, err := NewConversation(config, testDiff("api/x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message != replies[0]+"\n" {
+				t.Errorf("first message = %q, want %q", message, replies[0]+"\n")
+			}
+			for i, f := range feedback {
+				if message, err = conversation.Refine(f); err != nil {
+					t.Fatal(err)
+				}
+				if message != replies[i+1]+"\n" {
+					t.Errorf("refinement %d = %q, want %q", i+1, message, replies[i+1]+"\n")
+				}
+			}
+			if got := conversation.Turns(); got != len(feedback) {
+				t.Errorf("Turns() = %d, want %d", got, len(feedback))
+			}
+
+			requests := server.Requests()
+			initialPrompt := requests[0].LastUser()
+			last := requests[len(requests)-1]
+			var got []ChatTurn
+			for _, m := range last.Messages[1:] {
+				content := m.Text()
+				if content == initialPrompt {
+					content = "<prompt>"
+				}
+				got = append(got, ChatTurn{Role: m.Role, Content: content})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("last refinement sent\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestDependencyUpdates(t *testing.T) {
+	const note = "These changes only update dependencies."
+	manifestDiff := func(path string, lines ...string) string {
+		return "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path +
+			"\n@@ -1,3 +1,3 @@\n" + strings.Join(lines, "\n") + "\n"
+	}
+	goModBump := manifestDiff("go.mod", " require (", "-\tgolang.org/x/text v0.14.0", "+\tgolang.org/x/text v0.15.0", " )")
+
+	tests := []struct {
+		name     string
+		diff     string
+		commit   func(*utils.CommitConfig)
+		reply    string
+		want     string
+		wantErr  error
+		wantNote bool
+	}{
+		{
+			name:     "go.mod bump",
+			diff:     goModBump,
+			reply:    "build: bump golang.org/x/text to v0.15.0",
+			want:     "build(deps): bump golang.org/x/text to v0.15.0\n\n- bump golang.org/x/text from v0.14.0 to v0.15.0\n",
+			wantNote: true,
+		},
+		{
+			name:     "configured scope",
+			diff:     goModBump + manifestDiff("go.sum", "+golang.org/x/text v0.15.0 h1:def="),
+			commit:   func(c *utils.CommitConfig) { c.DepsScope = "vendor"; c.BulletStyle = utils.BulletStyleAsterisk },
+			reply:    "build: bump golang.org/x/text",
+			want:     "build(vendor): bump golang.org/x/text\n\n* bump golang.org/x/text from v0.14.0 to v0.15.0\n",
+			wantNote: true,
+		},
+		{
+			name:     "denied type rejected",
+			diff:     goModBump,
+			commit:   func(c *utils.CommitConfig) { c.DeniedTypes = []string{"build"} },
+			reply:    "build: bump golang.org/x/text",
+			wantErr:  DeniedTypeError{},
+			wantNote: true,
+		},
+		{
+			name:  "go directive bump",
+			diff:  manifestDiff("go.mod", "-go 1.22", "+go 1.24"),
+			reply: "build: require go 1.24",
+			want:  "build: require go 1.24\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			if tt.commit != nil {
+				tt.commit(&config.Commit)
+			}
+
+			result, err := GenerateCommitMessageWithIntent(config, tt.diff, "bump text for the CVE fix", "security patch")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("sent %d requests, want 1", len(requests))
+			}
+			prompt := requests[0].LastUser()
+			if got := strings.Contains(prompt, note); got != tt.wantNote {
+				t.Errorf("prompt has the dependency note: %v, want %v", got, tt.wantNote)
+			}
+			for _, s := range []string{"bump text for the CVE fix", "security patch"} {
+				if !strings.Contains(prompt, s) {
+					t.Errorf("prompt is missing %q", s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGenerateCommitMessageEmptyOutput(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []string
+		want      string
+		wantErr   error
+		wantCalls int
+	}{
+		{"retry then succeed", []string{"  \n", "fix: handle nil config"}, "fix: handle nil config\n", nil, 2},
+		{"retry then fail", []string{"", " \n\t"}, "", ErrEmptyMessage, 2},
+		{"no retry needed", []string{"fix: handle nil config"}, "fix: handle nil config\n", nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.replies...))
+
+			result, err := GenerateCommitMessage(server.Config(), testDiff("config.go"), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && !errors.As(err, &EmptyMessageError{}) {
+				t.Errorf("error = %T, want EmptyMessageError", err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+			if got := len(server.Requests()); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestGenerateCommitMessageEmptyDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+	}{
+		{"empty", ""},
+		{"whitespace only", "  \n\t\n"},
+		{"header only", "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+
+			_, err := GenerateCommitMessage(server.Config(), tt.diff, "")
+			if !errors.Is(err, EmptyDiffError{}) || !errors.Is(err, ErrEmptyDiff) {
+				t.Errorf("error = %v, want EmptyDiffError", err)
+			}
+			if got := len(server.Requests()); got != 0 {
+				t.Errorf("sent %d requests, want none", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// fastestEndpointEntry is a selection that is running or has finished.
+// ready is closed once endpoint and err are set.
+type fastestEndpointEntry struct {
+	ready    chan struct{}
+	endpoint string
+	err      error
+	// cancelled is set when the selection stopped because its caller's
+	// context ended, so it says nothing about the endpoints
+	cancelled bool
+}
+
+var (
+	fastestEndpointMu sync.Mutex
+	fastestEndpoints  = make(map[string]*fastestEndpointEntry)
+)
+
+// SelectFastestEndpoint pings every OpenAI-compatible endpoint concurrently
+// and returns the one that answered first. Unreachable endpoints are skipped.
+// The outcome is cached for the rest of the process, so repeated calls with
+// the same endpoints don't ping again, even when none of them answered.
+func SelectFastestEndpoint(endpoints []string) (string, error) {
+	return selectFastestEndpoint(context.Background(), utils.LLMConfig{}, endpoints)
+}
+
+// selectFastestEndpoint implements SelectFastestEndpoint. Concurrent first
+// callers wait for a single selection, and one cut short by its caller's
+// context isn't kept.
+func selectFastestEndpoint(ctx context.Context, llmConfig utils.LLMConfig, endpoints []string) (string, error) {
+	if len(endpoints) == 0 {
+		return "", fmt.Errorf("no endpoints to choose from")
+	}
+
+	key := strings.Join(endpoints, "\n")
+	for {
+		fastestEndpointMu.Lock()
+		entry, ok := fastestEndpoints[key]
+		if !ok {
+			entry = &fastestEndpointEntry{ready: make(chan struct{})}
+			fastestEndpoints[key] = entry
+		}
+		fastestEndpointMu.Unlock()
+
+		if !ok {
+			entry.endpoint, entry.err = pingEndpoints(ctx, llmConfig, endpoints)
+			if entry.err != nil && ctx.Err() != nil {
+				entry.cancelled = true
+				fastestEndpointMu.Lock()
+				delete(fastestEndpoints, key)
+				fastestEndpointMu.Unlock()
+			}
+			close(entry.ready)
+		}
+
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+		if entry.cancelled && ctx.Err() == nil {
+			// Another caller gave up, try again with this one's context
+			continue
+		}
+		return entry.endpoint, entry.err
+	}
+}
+
+// pingEndpoints returns the endpoint with the lowest ping latency, or every
+// ping's error if none answered.
+func pingEndpoints(ctx context.Context, llmConfig utils.LLMConfig, endpoints []string) (string, error) {
+	httpClient, err := newHTTPClient(llmConfig)
+	if err != nil {
+		return "", err
+	}
+	httpClient.Timeout = timeout
+
+	type pingResult struct {
+		endpoint string
+		latency  time.Duration
+		err      error
+	}
+	results := make(chan pingResult, len(endpoints))
+	for _, endpoint := range endpoints {
+		go func() {
+			latency, err := pingURL(ctx, httpClient, endpoint)
+			results <- pingResult{endpoint: endpoint, latency: latency, err: err}
+		}()
+	}
+
+	var fastest pingResult
+	var errs []error
+	for range endpoints {
+		result := <-results
+		if result.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.endpoint, result.err))
+			continue
+		}
+		if fastest.endpoint == "" || result.latency < fastest.latency {
+			fastest = result
+		}
+	}
+	if fastest.endpoint == "" {
+		return "", errors.Join(errs...)
+	}
+	return fastest.endpoint, nil
+}
+
+// pingURL measures how long the endpoint takes to answer a GET. Any HTTP
+// response counts, since the request isn't authenticated.
+func pingURL(ctx context.Context, httpClient *http.Client, endpoint string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/models", nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// pingServer answers pings after delay, or drops the connection if down is
+// set. hits counts the pings.
+type pingServer struct {
+	*httptest.Server
+	hits atomic.Int32
+}
+
+func newPingServer(t *testing.T, delay time.Duration, down bool) *pingServer {
+	t.Helper()
+	s := &pingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.hits.Add(1)
+		if down {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		time.Sleep(delay)
+		writeJSON(w, http.StatusOK, map[string]any{"data": []any{}})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestSelectFastestEndpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		delays  []time.Duration
+		down    []bool
+		want    int
+		wantErr bool
+	}{
+		{"fastest wins", []time.Duration{200 * time.Millisecond, 0}, []bool{false, false}, 1, false},
+		{"unreachable skipped", []time.Duration{0, 50 * time.Millisecond}, []bool{true, false}, 1, false},
+		{"none reachable", []time.Duration{0, 0}, []bool{true, true}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var servers []*pingServer
+			var endpoints []string
+			for i := range tt.delays {
+				server := newPingServer(t, tt.delays[i], tt.down[i])
+				servers = append(servers, server)
+				endpoints = append(endpoints, server.URL)
+			}
+
+			for call := range 2 {
+				got, err := selectFastestEndpoint(context.Background(), utils.LLMConfig{}, endpoints)
+				if tt.wantErr {
+					if err == nil || !strings.Contains(err.Error(), servers[0].URL) {
+						t.Errorf("call %d: error = %v, want one naming each endpoint", call, err)
+					}
+				} else if err != nil || got != endpoints[tt.want] {
+					t.Errorf("call %d: selectFastestEndpoint() = %q, %v, want %q", call, got, err, endpoints[tt.want])
+				}
+			}
+			// The second call, successful or not, reuses the first's outcome
+			for i, server := range servers {
+				if hits := server.hits.Load(); hits != 1 {
+					t.Errorf("endpoint %d pinged %d times, want once", i, hits)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectFastestEndpointConcurrent(t *testing.T) {
+	fast, slow := newPingServer(t, 20*time.Millisecond, false), newPingServer(t, 200*time.Millisecond, false)
+	endpoints := []string{slow.URL, fast.URL}
+
+	var wg sync.WaitGroup
+	results := make([]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = selectFastestEndpoint(context.Background(), utils.LLMConfig{}, endpoints)
+		}()
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		if got != fast.URL {
+			t.Errorf("caller %d got %q, want %q", i, got, fast.URL)
+		}
+	}
+	if fast.hits.Load() != 1 || slow.hits.Load() != 1 {
+		t.Errorf("pinged %d and %d times, want a single selection", fast.hits.Load(), slow.hits.Load())
+	}
+}
+
+func TestSelectFastestEndpointCancelled(t *testing.T) {
+	server := newPingServer(t, 0, false)
+	endpoints := []string{server.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := selectFastestEndpoint(ctx, utils.LLMConfig{}, endpoints); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+
+	// The cancelled selection isn't cached
+	got, err := selectFastestEndpoint(context.Background(), utils.LLMConfig{}, endpoints)
+	if err != nil || got != server.URL {
+		t.Errorf("selectFastestEndpoint() = %q, %v, want %q", got, err, server.URL)
+	}
+}
+
+func TestEndpointsConfig(t *testing.T) {
+	slow := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		time.Sleep(200 * time.Millisecond)
+		writeCompletion(w, "feat: from slow")
+	})
+	fast := newMockOpenAI(t, replyWith("feat: from fast"))
+	config := slow.Config()
+	config.LLM.Endpoints = []string{slow.URL, fast.URL}
+
+	result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Message != "feat: from fast\n" {
+		t.Errorf("message = %q, want the fastest endpoint's", result.Message)
+	}
+}
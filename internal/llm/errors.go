@@ -1,19 +1,334 @@
 package llm
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// Sentinel errors for branching on the kind of failure with errors.Is. Every
+// typed error below matches its sentinel, and request errors additionally
+// match ErrRateLimited when the provider throttled the request.
+var (
+	ErrAPIKeyMissing         = errors.New("API key missing")
+	ErrRequestFailed         = errors.New("request failed")
+	ErrRateLimited           = errors.New("rate limited")
+	ErrInvalidJSON           = errors.New("invalid JSON response")
+	ErrRequestTooLarge       = errors.New("request too large")
+	ErrEmptyMessage          = errors.New("empty commit message")
+	ErrUnsupportedModel      = errors.New("unsupported model")
+	ErrDeniedType            = errors.New("denied commit type")
+	ErrUnknownType           = errors.New("unknown commit type")
+	ErrMissingScope          = errors.New("missing scope")
+	ErrMissingHeader         = errors.New("missing commit header")
+	ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+	ErrSecretsDetected       = errors.New("secrets detected")
+	ErrConflictMarkers       = errors.New("conflict markers")
+	ErrInvalidCACert         = errors.New("invalid CA bundle")
+	ErrEmptyDiff             = errors.New("empty diff")
+	ErrAttemptBudget         = errors.New("attempt budget exhausted")
+	ErrSchemaViolation       = errors.New("response violates schema")
+	ErrEmptyPreviousSubject  = errors.New("empty previous subject")
+)
 
 type APIKeyMissingError struct{}
 type OpenAIRequestError struct{ Err error }
 type JSONParseError struct{ Err error }
+type RequestTooLargeError struct{ Size, Limit int }
+type EmptyMessageError struct{}
+type BedrockRequestError struct {
+	Err        error
+	StatusCode int
+}
+type UnsupportedBedrockModelError struct{ Model string }
+type DeniedTypeError struct{ Type string }
+type UnknownTypeError struct{ Type string }
+type MissingScopeError struct{}
+type MissingHeaderError struct{ Subject string }
+type PromptTemplateError struct{ Err error }
+type SecretsDetectedError struct{ Findings []string }
+type ConflictMarkersError struct{ Files []string }
+type CACertError struct {
+	Path string
+	Err  error
+}
+type EmptyDiffError struct{}
+type EmptyPreviousSubjectError struct{}
+type SchemaViolationError struct{ Missing, Extra []string }
+type AttemptBudgetError struct {
+	Budget int
+	Errs   []error
+}
 
 func (e APIKeyMissingError) Error() string {
 	return "KOMMIT_OPENAI_API_KEY or OPENAI_API_KEY environment variable must be set"
 }
 
+func (e APIKeyMissingError) Is(target error) bool {
+	switch target.(type) {
+	case APIKeyMissingError, *APIKeyMissingError:
+		return true
+	}
+	return target == ErrAPIKeyMissing
+}
+
 func (e OpenAIRequestError) Error() string {
 	return fmt.Sprintf("OpenAI request failed: %v", e.Err)
 }
 
+func (e OpenAIRequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e OpenAIRequestError) Is(target error) bool {
+	switch target.(type) {
+	case OpenAIRequestError, *OpenAIRequestError:
+		return true
+	}
+	if target == ErrRateLimited {
+		var apiErr *openai.Error
+		return errors.As(e.Err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return target == ErrRequestFailed
+}
+
 func (e JSONParseError) Error() string {
 	return fmt.Sprintf("JSON unmarshal failed: %v", e.Err)
 }
+
+func (e JSONParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e JSONParseError) Is(target error) bool {
+	switch target.(type) {
+	case JSONParseError, *JSONParseError:
+		return true
+	}
+	return target == ErrInvalidJSON
+}
+
+func (e RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body is %d bytes, exceeding the configured limit of %d bytes (llm.maxRequestBytes)", e.Size, e.Limit)
+}
+
+func (e RequestTooLargeError) Is(target error) bool {
+	switch target.(type) {
+	case RequestTooLargeError, *RequestTooLargeError:
+		return true
+	}
+	return target == ErrRequestTooLarge
+}
+
+func (e EmptyMessageError) Error() string {
+	return "model returned an empty commit message"
+}
+
+func (e EmptyMessageError) Is(target error) bool {
+	switch target.(type) {
+	case EmptyMessageError, *EmptyMessageError:
+		return true
+	}
+	return target == ErrEmptyMessage
+}
+
+func (e BedrockRequestError) Error() string {
+	return fmt.Sprintf("Bedrock request failed: %v", e.Err)
+}
+
+func (e BedrockRequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e BedrockRequestError) Is(target error) bool {
+	switch target.(type) {
+	case BedrockRequestError, *BedrockRequestError:
+		return true
+	}
+	if target == ErrRateLimited {
+		return e.StatusCode == http.StatusTooManyRequests
+	}
+	return target == ErrRequestFailed
+}
+
+func (e UnsupportedBedrockModelError) Error() string {
+	return fmt.Sprintf("unsupported Bedrock model family: %s", e.Model)
+}
+
+func (e UnsupportedBedrockModelError) Is(target error) bool {
+	switch target.(type) {
+	case UnsupportedBedrockModelError, *UnsupportedBedrockModelError:
+		return true
+	}
+	return target == ErrUnsupportedModel
+}
+
+func (e DeniedTypeError) Error() string {
+	return fmt.Sprintf("generated commit type %q is denied by commit.deniedTypes", e.Type)
+}
+
+func (e DeniedTypeError) Is(target error) bool {
+	switch target.(type) {
+	case DeniedTypeError, *DeniedTypeError:
+		return true
+	}
+	return target == ErrDeniedType
+}
+
+func (e UnknownTypeError) Error() string {
+	return fmt.Sprintf("generated commit type %q is not one of commit.types", e.Type)
+}
+
+func (e UnknownTypeError) Is(target error) bool {
+	switch target.(type) {
+	case UnknownTypeError, *UnknownTypeError:
+		return true
+	}
+	return target == ErrUnknownType
+}
+
+func (e MissingHeaderError) Error() string {
+	return fmt.Sprintf("generated subject %q is not a Conventional Commits header, but commit.headerOnlyOnSubject is set", e.Subject)
+}
+
+func (e MissingHeaderError) Is(target error) bool {
+	switch target.(type) {
+	case MissingHeaderError, *MissingHeaderError:
+		return true
+	}
+	return target == ErrMissingHeader
+}
+
+func (e MissingScopeError) Error() string {
+	return "generated commit message has no scope, but commit.requireScope is set"
+}
+
+func (e MissingScopeError) Is(target error) bool {
+	switch target.(type) {
+	case MissingScopeError, *MissingScopeError:
+		return true
+	}
+	return target == ErrMissingScope
+}
+
+func (e PromptTemplateError) Error() string {
+	return fmt.Sprintf("invalid commit.promptTemplate: %v", e.Err)
+}
+
+func (e PromptTemplateError) Unwrap() error {
+	return e.Err
+}
+
+func (e PromptTemplateError) Is(target error) bool {
+	switch target.(type) {
+	case PromptTemplateError, *PromptTemplateError:
+		return true
+	}
+	return target == ErrInvalidPromptTemplate
+}
+
+func (e SecretsDetectedError) Error() string {
+	return fmt.Sprintf("secret scanner reported %d finding(s):\n  %s", len(e.Findings), strings.Join(e.Findings, "\n  "))
+}
+
+func (e SecretsDetectedError) Is(target error) bool {
+	switch target.(type) {
+	case SecretsDetectedError, *SecretsDetectedError:
+		return true
+	}
+	return target == ErrSecretsDetected
+}
+
+func (e ConflictMarkersError) Error() string {
+	return fmt.Sprintf("merge-conflict markers found in %d file(s):\n  %s", len(e.Files), strings.Join(e.Files, "\n  "))
+}
+
+func (e ConflictMarkersError) Is(target error) bool {
+	switch target.(type) {
+	case ConflictMarkersError, *ConflictMarkersError:
+		return true
+	}
+	return target == ErrConflictMarkers
+}
+
+func (e CACertError) Error() string {
+	return fmt.Sprintf("failed to load CA bundle %s (llm.caCertFile): %v", e.Path, e.Err)
+}
+
+func (e CACertError) Unwrap() error {
+	return e.Err
+}
+
+func (e CACertError) Is(target error) bool {
+	switch target.(type) {
+	case CACertError, *CACertError:
+		return true
+	}
+	return target == ErrInvalidCACert
+}
+
+func (e EmptyDiffError) Error() string {
+	return "diff contains no changes"
+}
+
+func (e EmptyDiffError) Is(target error) bool {
+	switch target.(type) {
+	case EmptyDiffError, *EmptyDiffError:
+		return true
+	}
+	return target == ErrEmptyDiff
+}
+
+func (e EmptyPreviousSubjectError) Error() string {
+	return "the previous commit has no subject to fix up"
+}
+
+func (e EmptyPreviousSubjectError) Is(target error) bool {
+	switch target.(type) {
+	case EmptyPreviousSubjectError, *EmptyPreviousSubjectError:
+		return true
+	}
+	return target == ErrEmptyPreviousSubject
+}
+
+func (e AttemptBudgetError) Error() string {
+	msg := fmt.Sprintf("gave up after %d API calls (llm.totalAttemptBudget)", e.Budget)
+	if len(e.Errs) > 0 {
+		msg += ": " + errors.Join(e.Errs...).Error()
+	}
+	return msg
+}
+
+func (e AttemptBudgetError) Unwrap() []error {
+	return e.Errs
+}
+
+func (e AttemptBudgetError) Is(target error) bool {
+	switch target.(type) {
+	case AttemptBudgetError, *AttemptBudgetError:
+		return true
+	}
+	return target == ErrAttemptBudget
+}
+
+func (e SchemaViolationError) Error() string {
+	var problems []string
+	if len(e.Missing) > 0 {
+		problems = append(problems, "missing "+strings.Join(e.Missing, ", "))
+	}
+	if len(e.Extra) > 0 {
+		problems = append(problems, "unexpected "+strings.Join(e.Extra, ", "))
+	}
+	return "response doesn't match the JSON schema: " + strings.Join(problems, "; ")
+}
+
+func (e SchemaViolationError) Is(target error) bool {
+	switch target.(type) {
+	case SchemaViolationError, *SchemaViolationError:
+		return true
+	}
+	return target == ErrSchemaViolation
+}
@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+var sentinels = []error{
+	ErrAPIKeyMissing, ErrRequestFailed, ErrRateLimited, ErrInvalidJSON, ErrRequestTooLarge, ErrEmptyMessage,
+	ErrUnsupportedModel, ErrDeniedType, ErrUnknownType, ErrMissingScope, ErrMissingHeader,
+	ErrInvalidPromptTemplate, ErrSecretsDetected, ErrConflictMarkers, ErrInvalidCACert, ErrEmptyDiff,
+	ErrAttemptBudget, ErrSchemaViolation, ErrEmptyPreviousSubject,
+}
+
+func TestErrorKinds(t *testing.T) {
+	cause := errors.New("cause")
+	rateLimited := &openai.Error{StatusCode: http.StatusTooManyRequests}
+
+	tests := []struct {
+		name string
+		err  error
+		// target is a zero value of the error's type
+		target    error
+		sentinels []error
+		// cause is wrapped by the error, if any
+		cause error
+	}{
+		{"APIKeyMissingError", APIKeyMissingError{}, APIKeyMissingError{}, []error{ErrAPIKeyMissing}, nil},
+		{"*APIKeyMissingError", &APIKeyMissingError{}, APIKeyMissingError{}, []error{ErrAPIKeyMissing}, nil},
+		{"OpenAIRequestError", OpenAIRequestError{Err: cause}, OpenAIRequestError{}, []error{ErrRequestFailed}, cause},
+		{"*OpenAIRequestError", &OpenAIRequestError{Err: cause}, OpenAIRequestError{}, []error{ErrRequestFailed}, cause},
+		{"OpenAIRequestError rate limited", &OpenAIRequestError{Err: rateLimited}, OpenAIRequestError{}, []error{ErrRequestFailed, ErrRateLimited}, rateLimited},
+		{"JSONParseError", JSONParseError{Err: cause}, JSONParseError{}, []error{ErrInvalidJSON}, cause},
+		{"RequestTooLargeError", RequestTooLargeError{Size: 2, Limit: 1}, RequestTooLargeError{}, []error{ErrRequestTooLarge}, nil},
+		{"EmptyMessageError", EmptyMessageError{}, EmptyMessageError{}, []error{ErrEmptyMessage}, nil},
+		{"BedrockRequestError", BedrockRequestError{Err: cause, StatusCode: http.StatusBadRequest}, BedrockRequestError{}, []error{ErrRequestFailed}, cause},
+		{"BedrockRequestError rate limited", BedrockRequestError{Err: cause, StatusCode: http.StatusTooManyRequests}, BedrockRequestError{}, []error{ErrRequestFailed, ErrRateLimited}, cause},
+		{"UnsupportedBedrockModelError", UnsupportedBedrockModelError{Model: "x"}, UnsupportedBedrockModelError{}, []error{ErrUnsupportedModel}, nil},
+		{"DeniedTypeError", DeniedTypeError{Type: "perf"}, DeniedTypeError{}, []error{ErrDeniedType}, nil},
+		{"UnknownTypeError", UnknownTypeError{Type: "wip"}, UnknownTypeError{}, []error{ErrUnknownType}, nil},
+		{"MissingScopeError", MissingScopeError{}, MissingScopeError{}, []error{ErrMissingScope}, nil},
+		{"MissingHeaderError", MissingHeaderError{Subject: "x"}, MissingHeaderError{}, []error{ErrMissingHeader}, nil},
+		{"PromptTemplateError", PromptTemplateError{Err: cause}, PromptTemplateError{}, []error{ErrInvalidPromptTemplate}, cause},
+		{"SecretsDetectedError", SecretsDetectedError{Findings: []string{"x"}}, SecretsDetectedError{}, []error{ErrSecretsDetected}, nil},
+		{"ConflictMarkersError", ConflictMarkersError{Files: []string{"x"}}, ConflictMarkersError{}, []error{ErrConflictMarkers}, nil},
+		{"CACertError", CACertError{Path: "ca.pem", Err: fs.ErrNotExist}, CACertError{}, []error{ErrInvalidCACert}, fs.ErrNotExist},
+		{"EmptyDiffError", EmptyDiffError{}, EmptyDiffError{}, []error{ErrEmptyDiff}, nil},
+		{"EmptyPreviousSubjectError", EmptyPreviousSubjectError{}, EmptyPreviousSubjectError{}, []error{ErrEmptyPreviousSubject}, nil},
+		{"AttemptBudgetError", AttemptBudgetError{Budget: 1, Errs: []error{cause}}, AttemptBudgetError{}, []error{ErrAttemptBudget}, cause},
+		{"SchemaViolationError", SchemaViolationError{Missing: []string{"x"}}, SchemaViolationError{}, []error{ErrSchemaViolation}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("generating: %w", tt.err)
+
+			// Both the type and a pointer to it match, however the error was
+			// returned
+			if !errors.Is(wrapped, tt.target) {
+				t.Errorf("errors.Is(err, %T{}) = false", tt.target)
+			}
+			pointer := reflect.New(reflect.TypeOf(tt.target)).Interface().(error)
+			if !errors.Is(wrapped, pointer) {
+				t.Errorf("errors.Is(err, &%T{}) = false", tt.target)
+			}
+
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(wrapped, sentinel), slices.Contains(tt.sentinels, sentinel); got != want {
+					t.Errorf("errors.Is(err, %q) = %v, want %v", sentinel, got, want)
+				}
+			}
+
+			if tt.cause != nil && !errors.Is(wrapped, tt.cause) {
+				t.Error("errors.Is(err, cause) = false, want the cause unwrapped")
+			}
+
+			// errors.As finds the error as the type it was returned as
+			as := reflect.New(reflect.TypeOf(tt.err))
+			if !errors.As(wrapped, as.Interface()) || !reflect.DeepEqual(as.Elem().Interface(), tt.err) {
+				t.Errorf("errors.As(err, *%T) didn't find the error", tt.err)
+			}
+		})
+	}
+}
+
+func TestErrorKindsDontMatchOtherTypes(t *testing.T) {
+	err := fmt.Errorf("generating: %w", EmptyDiffError{})
+	for _, target := range []error{EmptyMessageError{}, &EmptyMessageError{}, MissingScopeError{}, APIKeyMissingError{}} {
+		if errors.Is(err, target) {
+			t.Errorf("errors.Is(EmptyDiffError, %T) = true", target)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package llm
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go"
+)
+
+const explainPrompt = "\n## Explanation:\n" +
+	"- Put the commit message in `message` and, in `rationale`, explain in one or two sentences why you chose " +
+	"its commit type and scope. Never mention the rationale in the message itself.\n"
+
+// ExplainedMessage is the structured answer requested when commit.explain is
+// set.
+type ExplainedMessage struct {
+	Message   string `json:"message"`
+	Rationale string `json:"rationale"`
+}
+
+var StructuredExplainedMessageSchema = GenerateSchema[ExplainedMessage]()
+
+// chatExplained asks for the commit message together with the reasoning
+// behind its type and scope. The rationale is returned separately and never
+// becomes part of the message.
+func chatExplained(ctx context.Context, config *utils.Config, prompt string) (ChatResult[string], error) {
+	schemaParam := openai.ResponseFormatJSONSchemaJSONSchemaParam{
+		Name:        openai.F("explained_commit_message"),
+		Description: openai.F("A commit message and the rationale for its type and scope."),
+		Schema:      openai.F(StructuredExplainedMessageSchema),
+		Strict:      openai.Bool(true),
+	}
+
+	explained, err := chatStructured[ExplainedMessage](ctx, config.LLM, prompt+explainPrompt, schemaParam)
+	result := ChatResult[string]{
+		Message:   explained.Message.Message,
+		Cost:      explained.Cost,
+		Warnings:  explained.Warnings,
+		Rationale: strings.TrimSpace(explained.Message.Rationale),
+	}
+	if err != nil {
+		return result, err
+	}
+	if strings.TrimSpace(result.Message) == "" {
+		return result, EmptyMessageError{}
+	}
+	return result, nil
+}
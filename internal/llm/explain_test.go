@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	const rationale = "The diff adds a new endpoint, so feat; all files are under api."
+
+	tests := []struct {
+		name    string
+		reply   ExplainedMessage
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "rationale parsed",
+			reply: ExplainedMessage{Message: "feat(api): add the orders endpoint\n\n- Add GET /orders", Rationale: "  " + rationale + "\n"},
+			want:  "feat(api): add the orders endpoint\n\n- Add GET /orders\n",
+		},
+		{
+			name:    "empty message",
+			reply:   ExplainedMessage{Rationale: rationale},
+			wantErr: ErrEmptyMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, tt.reply)
+			})
+			config := server.Config()
+			config.Commit.Explain = true
+
+			result, err := GenerateCommitMessage(config, testDiff("api/orders.go"), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+			if result.Rationale != rationale {
+				t.Errorf("rationale = %q, want %q", result.Rationale, rationale)
+			}
+			if strings.Contains(result.Message, "rationale") || strings.Contains(result.Message, rationale) {
+				t.Error("rationale leaked into the message")
+			}
+
+			req := server.Requests()[0]
+			format, _ := req.Raw["response_format"].(map[string]any)
+			if schema, _ := format["json_schema"].(map[string]any); schema["name"] != "explained_commit_message" {
+				t.Errorf("response_format = %v, want the explained message schema", format)
+			}
+			if !strings.Contains(req.LastUser(), explainPrompt) {
+				t.Error("prompt doesn't ask for the rationale")
+			}
+		})
+	}
+}
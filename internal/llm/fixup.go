@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// GenerateFixupMessage writes the autosquash message for commit.fixup, e.g.
+// `fixup! feat(ui): add dark mode`, without calling the model. It defaults to
+// a fixup when commit.fixup isn't set.
+func GenerateFixupMessage(config *utils.Config, previousSubject string) (string, error) {
+	subject, _, _ := strings.Cut(strings.TrimSpace(previousSubject), "\n")
+	if subject == "" {
+		return "", EmptyPreviousSubjectError{}
+	}
+
+	mode := config.Commit.Fixup
+	if mode == "" {
+		mode = utils.FixupModeFixup
+	}
+	return string(mode) + "! " + subject, nil
+}
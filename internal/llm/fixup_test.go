@@ -0,0 +1,39 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestGenerateFixupMessage(t *testing.T) {
+	tests := []struct {
+		name            string
+		mode            utils.FixupMode
+		previousSubject string
+		want            string
+		wantErr         error
+	}{
+		{"fixup", utils.FixupModeFixup, "feat(ui): add dark mode", "fixup! feat(ui): add dark mode", nil},
+		{"squash", utils.FixupModeSquash, "feat(ui): add dark mode", "squash! feat(ui): add dark mode", nil},
+		{"fixup by default", "", "fix: handle nil", "fixup! fix: handle nil", nil},
+		{"git log output trimmed", utils.FixupModeFixup, "fix: handle nil\n", "fixup! fix: handle nil", nil},
+		{"only the subject", utils.FixupModeSquash, "fix: handle nil\n\n- Guard it", "squash! fix: handle nil", nil},
+		{"empty previous subject", utils.FixupModeFixup, "", "", ErrEmptyPreviousSubject},
+		{"whitespace previous subject", utils.FixupModeSquash, " \n", "", ErrEmptyPreviousSubject},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{Types: testTypes, Fixup: tt.mode}}
+			got, err := GenerateFixupMessage(config, tt.previousSubject)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GenerateFixupMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestGenerateCommitMessageOfType(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		forced     string
+		reply      string
+		want       string
+		wantErr    bool
+	}{
+		{"forced type kept", "", "fix", "fix(api): handle nil orders", "fix(api): handle nil orders\n", false},
+		{"forced type replaces the model's", "", "fix", "feat(api)!: handle nil orders\n\n- Guard it", "fix(api)!: handle nil orders\n\n- Guard it\n", false},
+		{"configured type", "perf", "", "feat: cache orders", "perf: cache orders\n", false},
+		{"parameter overrides config", "perf", "fix", "feat: cache orders", "fix: cache orders\n", false},
+		{"not an allowed type", "", "wip", "feat: add x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.ForcedType = tt.configured
+
+			result, err := GenerateCommitMessageOfType(config, testDiff("api/orders.go"), "", tt.forced)
+			if tt.wantErr {
+				if !errors.Is(err, utils.ConfigFieldError{}) || !strings.Contains(err.Error(), "commit.forcedType") {
+					t.Errorf("error = %v, want a commit.forcedType error", err)
+				}
+				if got := len(server.Requests()); got != 0 {
+					t.Errorf("sent %d requests, want none", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			wantType := tt.forced
+			if wantType == "" {
+				wantType = tt.configured
+			}
+			if want := "Always use `" + wantType + "`"; !strings.Contains(server.Requests()[0].LastUser(), want) {
+				t.Errorf("prompt doesn't pin the type: want %q", want)
+			}
+		})
+	}
+}
+
+func TestForcedTypeDenied(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("feat: add x"))
+	config := server.Config()
+	config.Commit.DeniedTypes = []string{"perf"}
+
+	if _, err := GenerateCommitMessageOfType(config, testDiff("x.go"), "", "perf"); !errors.Is(err, utils.ConfigFieldError{}) {
+		t.Errorf("error = %v, want a ConfigFieldError for a denied forced type", err)
+	}
+}
@@ -0,0 +1,451 @@
+package llm
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const maxListedFiles = 10
+
+var bulletRegex = regexp.MustCompile(`^(\s*)(?:[-*•+]|\d+[.)])\s+(.*)$`)
+
+var tableSeparatorRegex = regexp.MustCompile(`^\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?$`)
+
+// formattingPrompt renders the formatting preferences from the config as
+// additional prompt instructions.
+func formattingPrompt(commit utils.CommitConfig) string {
+	prompt := "\n## Formatting Preferences:\n"
+	switch commit.Verbosity {
+	case utils.VerbosityTerse:
+		// Bullet preferences are moot without a body
+		return prompt + "- Write **only the subject line**. Do not include a body.\n"
+	case utils.VerbosityVerbose:
+		prompt += "- Always include a **detailed body**, even for small changes.\n"
+		prompt += "- Explain **why** each change was made, not only what changed.\n"
+	}
+	switch commit.BulletStyle {
+	case utils.BulletStyleAsterisk:
+		prompt += "- Start each body bullet point with `* `.\n"
+	case utils.BulletStyleNumbered:
+		prompt += "- Use a numbered list (`1. `, `2. `, ...) instead of bullet points in the body.\n"
+	default:
+		prompt += "- Start each body bullet point with `- `.\n"
+	}
+	if commit.MaxBodyBullets > 0 {
+		prompt += fmt.Sprintf("- Use at most **%d** bullet points in the body.\n", commit.MaxBodyBullets)
+	}
+	if commit.HeaderOnlyOnSubject {
+		prompt += "- Never start a body line with a commit type such as `fix: `; only the subject line has one.\n"
+	}
+	if commit.IncludeRationale {
+		prompt += "- End the body with a bullet point starting with `Why: ` that explains the motivation for the change, " +
+			"as far as it can be inferred from the diff. If the motivation isn't clear, leave this bullet out " +
+			"instead of guessing.\n"
+	}
+	return prompt
+}
+
+// formatCommitMessage applies deterministic post-processing to a generated
+// commit message so it follows the configured formatting preferences. The
+// result has exactly one blank line between subject and body, no trailing
+// spaces and a single terminating newline, unless PreserveRawFormatting is set
+// in which case the message is returned untouched.
+func formatCommitMessage(commit utils.CommitConfig, message string) string {
+	if commit.PreserveRawFormatting {
+		return message
+	}
+
+	subject, body := utils.SplitCommitMessage(normalizeWhitespace(message))
+	if commit.StripsSubjectPeriod() {
+		subject = stripSubjectPeriod(subject)
+	}
+	if body == "" || commit.Verbosity == utils.VerbosityTerse {
+		return subject + "\n"
+	}
+
+	if commit.DropRedundantBody && isRedundantBody(subject, body, commit.RedundantBodyThreshold) {
+		return subject + "\n"
+	}
+
+	return subject + "\n\n" + formatBody(commit, body) + "\n"
+}
+
+// formatBody applies the body part of formatCommitMessage: bullet style,
+// body headers and the bullet and line caps.
+func formatBody(commit utils.CommitConfig, body string) string {
+	body = normalizeBullets(convertMarkdownTables(body), commit.BulletStyle)
+	if commit.HeaderOnlyOnSubject {
+		body = stripBodyHeaders(body, commit.Types)
+	}
+	if commit.MaxBodyBullets > 0 {
+		body = truncateBullets(body, commit.MaxBodyBullets)
+	}
+	if commit.MaxBodyLines > 0 {
+		body = truncateLines(body, commit.MaxBodyLines)
+	}
+	return body
+}
+
+const defaultRedundantBodyThreshold = 0.8
+
+// isRedundantBody reports whether a body made of a single line or bullet
+// says little more than the subject, measured as the Jaccard similarity of
+// their words. Longer bodies are never considered redundant.
+func isRedundantBody(subject, body string, threshold float64) bool {
+	if threshold == 0 {
+		threshold = defaultRedundantBodyThreshold
+	}
+	if strings.Contains(body, "\n") {
+		return false
+	}
+	if matches := bulletRegex.FindStringSubmatch(body); matches != nil {
+		body = matches[2]
+	}
+	if header, ok := utils.ParseCommitHeader(subject); ok {
+		subject = header.Description
+	}
+
+	subjectWords, bodyWords := wordSet(subject), wordSet(body)
+	if len(subjectWords) == 0 || len(bodyWords) == 0 {
+		return false
+	}
+	shared := 0
+	for word := range bodyWords {
+		if subjectWords[word] {
+			shared++
+		}
+	}
+	union := len(subjectWords) + len(bodyWords) - shared
+	return float64(shared)/float64(union) >= threshold
+}
+
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// truncateBullets keeps the first max top-level bullets, along with their
+// continuation lines, and notes how many were dropped.
+func truncateBullets(body string, max int) string {
+	var kept []string
+	count, dropped := 0, 0
+	skipping := false
+	markerAt := -1
+
+	for _, line := range strings.Split(body, "\n") {
+		matches := bulletRegex.FindStringSubmatch(line)
+		switch {
+		case matches != nil && matches[1] == "":
+			count++
+			skipping = count > max
+			if skipping {
+				dropped++
+				if markerAt < 0 {
+					markerAt = len(kept)
+				}
+				continue
+			}
+		case skipping && strings.TrimSpace(line) != "" && line != strings.TrimLeft(line, " \t"):
+			// Continuation of a dropped bullet
+			continue
+		default:
+			skipping = false
+		}
+		kept = append(kept, line)
+	}
+
+	if dropped == 0 {
+		return body
+	}
+	note := fmt.Sprintf("…and %d more changes", dropped)
+	kept = append(kept[:markerAt], append([]string{note}, kept[markerAt:]...)...)
+	return strings.Join(kept, "\n")
+}
+
+const plainWrapWidth = 72
+
+// renderMessage applies the final, profile-specific formatting pass.
+// GitHub renders bodies as Markdown and auto-links references such as #123,
+// so the message is left as is. GitLab only renders a list that follows a
+// blank line, and plain git shows the text verbatim, so body lines are
+// wrapped at 72 characters. Whatever the profile, an empty scope is rendered
+// without parentheses.
+func renderMessage(message string, profile utils.RenderProfile) string {
+	subject, rest, found := strings.Cut(message, "\n")
+	subject = withoutEmptyScope(subject)
+	if !found {
+		return subject
+	}
+
+	lines := strings.Split(rest, "\n")
+	var rendered []string
+	for i, line := range lines {
+		switch profile {
+		case utils.RenderProfileGitLab:
+			isBullet := bulletRegex.MatchString(line)
+			if isBullet && i > 0 && strings.TrimSpace(lines[i-1]) != "" && !bulletRegex.MatchString(lines[i-1]) &&
+				lines[i-1] == strings.TrimLeft(lines[i-1], " \t") {
+				rendered = append(rendered, "")
+			}
+			rendered = append(rendered, line)
+		case utils.RenderProfilePlain:
+			rendered = append(rendered, wrapLine(line, plainWrapWidth)...)
+		default:
+			rendered = append(rendered, line)
+		}
+	}
+	return subject + "\n" + strings.Join(rendered, "\n")
+}
+
+// withoutEmptyScope turns `chore(): ...` or `chore( ): ...` into
+// `chore: ...`, leaving every other subject untouched.
+func withoutEmptyScope(subject string) string {
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok || strings.TrimSpace(header.Scope) != "" {
+		return subject
+	}
+	header.Scope = ""
+	return header.String()
+}
+
+// wrapLine breaks a line at spaces so each part fits in width characters
+// where possible. Continuation lines of a bullet are indented to line up with
+// its text. Words longer than width, such as URLs, are never split.
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+	if matches := bulletRegex.FindStringSubmatch(line); matches != nil {
+		indent = len(line) - len(matches[2])
+	}
+	prefix, words := line[:indent], strings.Fields(line[indent:])
+	continuation := strings.Repeat(" ", indent)
+
+	var wrapped []string
+	current := prefix
+	for _, word := range words {
+		if current != prefix && current != continuation &&
+			utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+			wrapped = append(wrapped, current)
+			current = continuation
+		}
+		if current == prefix || current == continuation {
+			current += word
+		} else {
+			current += " " + word
+		}
+	}
+	return append(wrapped, current)
+}
+
+// truncateLines keeps the first max lines of the body and marks the cut with
+// an ellipsis on the last kept line.
+func truncateLines(body string, max int) string {
+	lines := strings.Split(body, "\n")
+	if len(lines) <= max {
+		return body
+	}
+
+	kept := lines[:max]
+	for len(kept) > 1 && strings.TrimSpace(kept[len(kept)-1]) == "" {
+		kept = kept[:len(kept)-1]
+	}
+	kept[len(kept)-1] += " …"
+	return strings.Join(kept, "\n")
+}
+
+// capBodyLines enforces MaxBodyLines on a rendered message, since wrapping
+// for plain git can turn one body line into several.
+func capBodyLines(commit utils.CommitConfig, message string) string {
+	if commit.MaxBodyLines <= 0 || commit.PreserveRawFormatting {
+		return message
+	}
+	subject, body := utils.SplitCommitMessage(message)
+	if body == "" || strings.Count(body, "\n") < commit.MaxBodyLines {
+		return message
+	}
+	return subject + "\n\n" + truncateLines(body, commit.MaxBodyLines) + "\n"
+}
+
+// stripSubjectPeriod removes a single trailing period, leaving ellipses
+// alone.
+func stripSubjectPeriod(subject string) string {
+	if strings.HasSuffix(subject, ".") && !strings.HasSuffix(subject, "..") {
+		return strings.TrimRight(strings.TrimSuffix(subject, "."), " ")
+	}
+	return subject
+}
+
+// truncateMessage shortens the body so the whole message fits in limit bytes,
+// cutting at the last sentence or line boundary and marking the cut with an
+// ellipsis. The body is dropped entirely when no boundary fits, and the
+// subject is only shortened if it doesn't fit on its own.
+func truncateMessage(message string, limit int) string {
+	if len(message) <= limit {
+		return message
+	}
+
+	const ellipsis = "…"
+	subject, body := utils.SplitCommitMessage(message)
+	if budget := limit - len(subject) - len("\n\n") - len(ellipsis) - len("\n"); body != "" && budget > 0 {
+		if cut := lastSentenceEnd(body, budget); cut > 0 {
+			return subject + "\n\n" + strings.TrimRight(body[:cut], " \t\n") + ellipsis + "\n"
+		}
+	}
+
+	if len(subject)+len("\n") <= limit {
+		return subject + "\n"
+	}
+	cut := max(limit-len(ellipsis)-len("\n"), 0)
+	for cut > 0 && !utf8.RuneStart(subject[cut]) {
+		cut--
+	}
+	return strings.TrimRight(subject[:cut], " ") + ellipsis + "\n"
+}
+
+// lastSentenceEnd returns the end of the last sentence or line in text that
+// finishes within the first limit bytes, or zero if there is none.
+func lastSentenceEnd(text string, limit int) int {
+	for i := min(limit, len(text)) - 1; i > 0; i-- {
+		switch text[i] {
+		case '\n':
+			return i
+		case '.', '!', '?':
+			if i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n' {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// normalizeWhitespace strips trailing spaces from every line and collapses
+// runs of blank lines into a single one.
+func normalizeWhitespace(message string) string {
+	lines := strings.Split(message, "\n")
+	normalized := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && len(normalized) > 0 && normalized[len(normalized)-1] == "" {
+			continue
+		}
+		normalized = append(normalized, line)
+	}
+	return strings.Join(normalized, "\n")
+}
+
+// appendSection adds a paragraph to the end of the message, keeping the
+// message's terminating newline intact.
+func appendSection(message, section string) string {
+	trimmed := strings.TrimRight(message, "\n")
+	return trimmed + "\n\n" + section + message[len(trimmed):]
+}
+
+// normalizeBullets rewrites top-level list markers in the body to the given
+// style. Indented continuation lines are left untouched.
+func normalizeBullets(body string, style utils.BulletStyle) string {
+	lines := strings.Split(body, "\n")
+	number := 0
+	for i, line := range lines {
+		matches := bulletRegex.FindStringSubmatch(line)
+		if matches == nil || matches[1] != "" {
+			continue
+		}
+
+		number++
+		switch style {
+		case utils.BulletStyleAsterisk:
+			lines[i] = "* " + matches[2]
+		case utils.BulletStyleNumbered:
+			lines[i] = fmt.Sprintf("%d. %s", number, matches[2])
+		default:
+			lines[i] = "- " + matches[2]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stripBodyHeaders drops the `type(scope): ` prefix from body lines and
+// bullets that start like a commit header of one of types, keeping the rest
+// of the line. Footers such as `Refs: ` are left alone.
+func stripBodyHeaders(body string, types []string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		marker, content := "", line
+		if matches := bulletRegex.FindStringSubmatch(line); matches != nil {
+			marker, content = strings.TrimSuffix(line, matches[2]), matches[2]
+		}
+		header, ok := utils.ParseCommitHeader(content)
+		if ok && slices.Contains(types, header.Type) {
+			lines[i] = marker + header.Description
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// convertMarkdownTables turns markdown tables in the body into bullets, one
+// per row, as `first cell: other cells`. The header row is dropped. Lines
+// that aren't part of a table, i.e. followed by a separator row such as
+// `|---|---|`, are left untouched.
+func convertMarkdownTables(body string) string {
+	lines := strings.Split(body, "\n")
+	var converted []string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "|") || i+1 >= len(lines) || !tableSeparatorRegex.MatchString(strings.TrimSpace(lines[i+1])) {
+			converted = append(converted, lines[i])
+			continue
+		}
+
+		// Skip the header and separator, then convert rows until the table ends
+		for i += 2; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), "|"); i++ {
+			var cells []string
+			for _, cell := range strings.Split(strings.Trim(strings.TrimSpace(lines[i]), "|"), "|") {
+				if cell = strings.TrimSpace(cell); cell != "" {
+					cells = append(cells, cell)
+				}
+			}
+			switch len(cells) {
+			case 0:
+			case 1:
+				converted = append(converted, "- "+cells[0])
+			default:
+				converted = append(converted, "- "+cells[0]+": "+strings.Join(cells[1:], ", "))
+			}
+		}
+		i--
+	}
+	return strings.Join(converted, "\n")
+}
+
+// fileListSection lists the files touched by the diff, summarizing anything
+// beyond maxListedFiles as "+N more".
+func fileListSection(files []utils.FileDiff, style utils.BulletStyle) string {
+	marker := "- "
+	if style == utils.BulletStyleAsterisk {
+		marker = "* "
+	}
+
+	section := "Files:"
+	for i, f := range files {
+		if i == maxListedFiles {
+			section += fmt.Sprintf("\n%s+%d more", marker, len(files)-maxListedFiles)
+			break
+		}
+		section += "\n" + marker + f.Path()
+	}
+	return section
+}
@@ -0,0 +1,635 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestBulletStylePrompt(t *testing.T) {
+	tests := []struct {
+		style utils.BulletStyle
+		want  string
+	}{
+		{"", "- Start each body bullet point with `- `.\n"},
+		{utils.BulletStyleDash, "- Start each body bullet point with `- `.\n"},
+		{utils.BulletStyleAsterisk, "- Start each body bullet point with `* `.\n"},
+		{utils.BulletStyleNumbered, "- Use a numbered list (`1. `, `2. `, ...) instead of bullet points in the body.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			if prompt := formattingPrompt(utils.CommitConfig{BulletStyle: tt.style}); !strings.Contains(prompt, tt.want) {
+				t.Errorf("formattingPrompt() = %q, want it to contain %q", prompt, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeBullets(t *testing.T) {
+	const mixed = "- Add a\n* Add b\n  continued\n3) Add c\n• Add d"
+
+	tests := []struct {
+		style utils.BulletStyle
+		want  string
+	}{
+		{"", "- Add a\n- Add b\n  continued\n- Add c\n- Add d"},
+		{utils.BulletStyleDash, "- Add a\n- Add b\n  continued\n- Add c\n- Add d"},
+		{utils.BulletStyleAsterisk, "* Add a\n* Add b\n  continued\n* Add c\n* Add d"},
+		{utils.BulletStyleNumbered, "1. Add a\n2. Add b\n  continued\n3. Add c\n4. Add d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.style), func(t *testing.T) {
+			if got := normalizeBullets(mixed, tt.style); got != tt.want {
+				t.Errorf("normalizeBullets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListFilesInBody(t *testing.T) {
+	manyFiles := make([]string, 12)
+	for i := range manyFiles {
+		manyFiles[i] = "pkg/file" + string(rune('a'+i)) + ".go"
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{
+			name:  "within the cap",
+			files: []string{"cmd/main.go", "pkg/util.go"},
+			want:  "feat: add x\n\n- Add y\n\nFiles:\n- cmd/main.go\n- pkg/util.go\n",
+		},
+		{
+			name:  "beyond the cap",
+			files: manyFiles,
+			want: "feat: add x\n\n- Add y\n\nFiles:\n" +
+				"- pkg/filea.go\n- pkg/fileb.go\n- pkg/filec.go\n- pkg/filed.go\n- pkg/filee.go\n" +
+				"- pkg/filef.go\n- pkg/fileg.go\n- pkg/fileh.go\n- pkg/filei.go\n- pkg/filej.go\n" +
+				"- +2 more\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x\n\n- Add y"))
+			config := server.Config()
+			config.Commit.ListFilesInBody = true
+
+			result, err := GenerateCommitMessage(config, testDiff(tt.files...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCommitMessageWhitespace(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  bool
+		in   string
+		want string
+	}{
+		{
+			name: "extra blank lines",
+			in:   "feat: add x\n\n\n\n- Add y\n\n\n- Add z\n\n\n",
+			want: "feat: add x\n\n- Add y\n\n- Add z\n",
+		},
+		{
+			name: "trailing spaces",
+			in:   "feat: add x  \n\n- Add y \t\n- Add z\r\n",
+			want: "feat: add x\n\n- Add y\n- Add z\n",
+		},
+		{
+			name: "missing subject/body separation",
+			in:   "feat: add x\n- Add y\n- Add z",
+			want: "feat: add x\n\n- Add y\n- Add z\n",
+		},
+		{
+			name: "subject only",
+			in:   "feat: add x",
+			want: "feat: add x\n",
+		},
+		{
+			name: "raw formatting preserved",
+			raw:  true,
+			in:   "feat: add x  \n- Add y\n\n\n",
+			want: "feat: add x  \n- Add y\n\n\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := utils.CommitConfig{PreserveRawFormatting: tt.raw}
+			if got := formatCommitMessage(commit, tt.in); got != tt.want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBodyBulletsPrompt(t *testing.T) {
+	const want = "- Use at most **3** bullet points in the body.\n"
+	if prompt := formattingPrompt(utils.CommitConfig{MaxBodyBullets: 3}); !strings.Contains(prompt, want) {
+		t.Errorf("formattingPrompt() = %q, want it to contain %q", prompt, want)
+	}
+	if prompt := formattingPrompt(utils.CommitConfig{}); strings.Contains(prompt, "at most") {
+		t.Errorf("formattingPrompt() without a cap = %q", prompt)
+	}
+}
+
+func TestTruncateBullets(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		max  int
+		want string
+	}{
+		{
+			name: "within the cap",
+			body: "- Add a\n- Add b",
+			max:  2,
+			want: "- Add a\n- Add b",
+		},
+		{
+			name: "beyond the cap",
+			body: "- Add a\n- Add b\n- Add c\n- Add d",
+			max:  2,
+			want: "- Add a\n- Add b\n…and 2 more changes",
+		},
+		{
+			name: "continuation lines dropped with their bullet",
+			body: "- Add a\n  wrapped a\n- Add b\n  wrapped b",
+			max:  1,
+			want: "- Add a\n  wrapped a\n…and 1 more changes",
+		},
+		{
+			name: "nested bullets don't count",
+			body: "- Add a\n  - detail\n  - detail\n- Add b",
+			max:  2,
+			want: "- Add a\n  - detail\n  - detail\n- Add b",
+		},
+		{
+			name: "trailing text kept",
+			body: "- Add a\n- Add b\n\nBREAKING CHANGE: x",
+			max:  1,
+			want: "- Add a\n…and 1 more changes\n\nBREAKING CHANGE: x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateBullets(tt.body, tt.max); got != tt.want {
+				t.Errorf("truncateBullets() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerbosityPrompt(t *testing.T) {
+	const (
+		subjectOnly = "- Write **only the subject line**. Do not include a body.\n"
+		detailed    = "- Always include a **detailed body**, even for small changes.\n"
+		rationale   = "- Explain **why** each change was made, not only what changed.\n"
+		bullets     = "- Start each body bullet point with `- `.\n"
+	)
+
+	tests := []struct {
+		verbosity utils.Verbosity
+		want      []string
+		wantNot   []string
+	}{
+		{"", []string{bullets}, []string{subjectOnly, detailed, rationale}},
+		{utils.VerbosityNormal, []string{bullets}, []string{subjectOnly, detailed, rationale}},
+		{utils.VerbosityTerse, []string{subjectOnly}, []string{bullets, detailed, rationale}},
+		{utils.VerbosityVerbose, []string{bullets, detailed, rationale}, []string{subjectOnly}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.verbosity), func(t *testing.T) {
+			prompt := formattingPrompt(utils.CommitConfig{Verbosity: tt.verbosity})
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("formattingPrompt() = %q, want it to contain %q", prompt, want)
+				}
+			}
+			for _, want := range tt.wantNot {
+				if strings.Contains(prompt, want) {
+					t.Errorf("formattingPrompt() = %q, want it not to contain %q", prompt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestTerseDropsBody(t *testing.T) {
+	const message = "feat: add x\n\n- Add y"
+	tests := []struct {
+		verbosity utils.Verbosity
+		want      string
+	}{
+		{utils.VerbosityTerse, "feat: add x\n"},
+		{utils.VerbosityNormal, "feat: add x\n\n- Add y\n"},
+		{utils.VerbosityVerbose, "feat: add x\n\n- Add y\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.verbosity), func(t *testing.T) {
+			if got := formatCommitMessage(utils.CommitConfig{Verbosity: tt.verbosity}, message); got != tt.want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoSubjectPeriod(t *testing.T) {
+	off := false
+
+	tests := []struct {
+		name    string
+		enabled *bool
+		in      string
+		want    string
+	}{
+		{"trailing period", nil, "fix: handle nil config.", "fix: handle nil config\n"},
+		{"only one period stripped", nil, "fix: handle nil config .", "fix: handle nil config\n"},
+		{"ellipsis left alone", nil, "wip: start the parser...", "wip: start the parser...\n"},
+		{"unicode ellipsis left alone", nil, "wip: start the parser…", "wip: start the parser…\n"},
+		{"body periods kept", nil, "fix: handle nil config.\n\nThe config can be nil. Guard it.\n- Add a check.",
+			"fix: handle nil config\n\nThe config can be nil. Guard it.\n- Add a check.\n"},
+		{"disabled", &off, "fix: handle nil config.\n\n- Add a check.", "fix: handle nil config.\n\n- Add a check.\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := utils.CommitConfig{NoSubjectPeriod: tt.enabled}
+			if got := formatCommitMessage(commit, tt.in); got != tt.want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateLines(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		max  int
+		want string
+	}{
+		{"within the cap", "- Add a\n  wrapped a\n- Add b", 3, "- Add a\n  wrapped a\n- Add b"},
+		{"beyond the cap", "- Add a\n  wrapped a\n  more a\n- Add b", 2, "- Add a\n  wrapped a …"},
+		{"blank lines not kept last", "- Add a\n\n- Add b", 2, "- Add a …"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := truncateLines(tt.body, tt.max); got != tt.want {
+				t.Errorf("truncateLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBodyLines(t *testing.T) {
+	long := "- " + strings.Repeat("word ", 30)
+
+	tests := []struct {
+		name    string
+		profile utils.RenderProfile
+		reply   string
+		max     int
+		want    string
+	}{
+		{
+			name:  "within the cap",
+			reply: "feat: add x\n\n- Add a\n- Add b",
+			max:   2,
+			want:  "feat: add x\n\n- Add a\n- Add b\n",
+		},
+		{
+			name:  "one bullet over several lines",
+			reply: "feat: add x\n\n- Add a\n  wrapped\n  again\n- Add b",
+			max:   2,
+			want:  "feat: add x\n\n- Add a\n  wrapped …\n",
+		},
+		{
+			name:    "cap applied after wrapping for plain git",
+			profile: utils.RenderProfilePlain,
+			reply:   "feat: add x\n\n" + long,
+			max:     1,
+			want:    "feat: add x\n\n- word word word word word word word word word word word word word word …\n",
+		},
+		{
+			name:    "wrapped body within the cap",
+			profile: utils.RenderProfilePlain,
+			reply:   "feat: add x\n\n" + long,
+			max:     3,
+			want:    "feat: add x\n\n- word word word word word word word word word word word word word word\n  word word word word word word word word word word word word word word\n  word word\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.MaxBodyLines = tt.max
+			config.Commit.RenderProfile = tt.profile
+
+			result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestDropRedundantBody(t *testing.T) {
+	const subject = "feat(api): add order export"
+
+	tests := []struct {
+		name      string
+		disabled  bool
+		threshold float64
+		body      string
+		dropped   bool
+	}{
+		{name: "restated bullet", body: "- Add order export", dropped: true},
+		{name: "restated line", body: "Add order export.", dropped: true},
+		{name: "informative bullet", body: "- Stream rows to CSV so large exports don't time out"},
+		{name: "multiple bullets exempt", body: "- Add order export\n- Add order export"},
+		{name: "below the default threshold", body: "- Add order export to CSV"},
+		{name: "custom threshold", threshold: 0.5, body: "- Add order export to CSV", dropped: true},
+		{name: "disabled", disabled: true, body: "- Add order export"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := utils.CommitConfig{DropRedundantBody: !tt.disabled, RedundantBodyThreshold: tt.threshold}
+			want := subject + "\n\n" + tt.body + "\n"
+			if tt.dropped {
+				want = subject + "\n"
+			}
+			if got := formatCommitMessage(commit, subject+"\n\n"+tt.body); got != want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRenderMessage(t *testing.T) {
+	const message = "feat(api): add order export\n\n" +
+		"Closes #123.\n" +
+		"- Stream the rows to CSV so that exporting a large number of orders no longer times out\n" +
+		"- Add a download link\n"
+
+	tests := []struct {
+		profile utils.RenderProfile
+		want    string
+	}{
+		{"", message},
+		{utils.RenderProfileGitHub, message},
+		{
+			utils.RenderProfileGitLab,
+			"feat(api): add order export\n\n" +
+				"Closes #123.\n\n" +
+				"- Stream the rows to CSV so that exporting a large number of orders no longer times out\n" +
+				"- Add a download link\n",
+		},
+		{
+			utils.RenderProfilePlain,
+			"feat(api): add order export\n\n" +
+				"Closes #123.\n" +
+				"- Stream the rows to CSV so that exporting a large number of orders no\n" +
+				"  longer times out\n" +
+				"- Add a download link\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.profile), func(t *testing.T) {
+			if got := renderMessage(message, tt.profile); got != tt.want {
+				t.Errorf("renderMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyScopeRendered(t *testing.T) {
+	tests := []struct {
+		subject string
+		want    string
+	}{
+		{"chore(): bump deps", "chore: bump deps"},
+		{"chore( ): bump deps", "chore: bump deps"},
+		{"feat()!: drop v1", "feat!: drop v1"},
+		{"chore: bump deps", "chore: bump deps"},
+		{"feat(api): add x", "feat(api): add x"},
+		{"not a header ()", "not a header ()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subject, func(t *testing.T) {
+			if got := withoutEmptyScope(tt.subject); got != tt.want {
+				t.Errorf("withoutEmptyScope() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A structured answer can leave the scope blank
+	for _, profile := range []utils.RenderProfile{"", utils.RenderProfileGitLab, utils.RenderProfilePlain} {
+		t.Run("structured/"+string(profile), func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, ExplainedMessage{Message: "chore(): bump deps\n\n- Bump x", Rationale: "Dependencies only."})
+			})
+			config := server.Config()
+			config.Commit.Explain = true
+			config.Commit.RenderProfile = profile
+
+			result, err := GenerateCommitMessage(config, testDiff("Makefile"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "chore: bump deps\n\n- Bump x\n"; result.Message != want {
+				t.Errorf("message = %q, want %q", result.Message, want)
+			}
+			if strings.Contains(result.Message, "()") {
+				t.Errorf("message has empty parentheses: %q", result.Message)
+			}
+		})
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	url := "https://example.com/" + strings.Repeat("a", 80)
+
+	tests := []struct {
+		name  string
+		line  string
+		width int
+		want  []string
+	}{
+		{"fits", "short line", 20, []string{"short line"}},
+		{"plain text", "one two three four", 9, []string{"one two", "three", "four"}},
+		{"bullet continuation lines up", "- one two three", 9, []string{"- one two", "  three"}},
+		{"long word never split", "see " + url, 20, []string{"see", url}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapLine(tt.line, tt.width); !slices.Equal(got, tt.want) {
+				t.Errorf("wrapLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertMarkdownTables(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "table",
+			body: "| File | Change |\n|------|:------:|\n| api.go | Add export |\n| ui.tsx | Add button |",
+			want: "- api.go: Add export\n- ui.tsx: Add button",
+		},
+		{
+			name: "table between text",
+			body: "Changes:\n\n|a|b|c|\n|-|-|-|\n|x|y|z|\n\nMore text",
+			want: "Changes:\n\n- x: y, z\n\nMore text",
+		},
+		{
+			name: "single column and empty rows",
+			body: "| Change |\n| --- |\n| Add export |\n| |",
+			want: "- Add export",
+		},
+		{
+			name: "pipe without separator left alone",
+			body: "- Use a | b for the union",
+			want: "- Use a | b for the union",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertMarkdownTables(tt.body); got != tt.want {
+				t.Errorf("convertMarkdownTables() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkdownBodyNormalized(t *testing.T) {
+	tests := []struct {
+		name  string
+		style utils.BulletStyle
+		body  string
+		want  string
+	}{
+		{
+			name: "table to dashes",
+			body: "| File | Change |\n|---|---|\n| api.go | Add export |\n| ui.tsx | Add button |",
+			want: "- api.go: Add export\n- ui.tsx: Add button",
+		},
+		{
+			name: "numbered list to dashes",
+			body: "1. Add export\n2) Add button\n   with an icon",
+			want: "- Add export\n- Add button\n   with an icon",
+		},
+		{
+			name:  "table to the configured style",
+			style: utils.BulletStyleAsterisk,
+			body:  "| File | Change |\n|---|---|\n| api.go | Add export |",
+			want:  "* api.go: Add export",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := utils.CommitConfig{BulletStyle: tt.style}
+			want := "feat: add export\n\n" + tt.want + "\n"
+			if got := formatCommitMessage(commit, "feat: add export\n\n"+tt.body); got != want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderOnlyOnSubject(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		message string
+		want    string
+	}{
+		{
+			name:    "header-like bullet",
+			enabled: true,
+			message: "feat(api): add export\n\n- fix(api): handle empty orders\n- Add the endpoint",
+			want:    "feat(api): add export\n\n- handle empty orders\n- Add the endpoint\n",
+		},
+		{
+			name:    "header-like line",
+			enabled: true,
+			message: "feat: add export\n\nchore: bump the version too",
+			want:    "feat: add export\n\nbump the version too\n",
+		},
+		{
+			name:    "footers and unknown types kept",
+			enabled: true,
+			message: "feat: add export\n\n- Note: exports are async\n\nRefs: JIRA-1",
+			want:    "feat: add export\n\n- Note: exports are async\n\nRefs: JIRA-1\n",
+		},
+		{
+			name:    "disabled",
+			message: "feat: add export\n\n- fix: handle empty orders",
+			want:    "feat: add export\n\n- fix: handle empty orders\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := utils.CommitConfig{Types: testTypes, HeaderOnlyOnSubject: tt.enabled}
+			got := formatCommitMessage(commit, tt.message)
+			if got != tt.want {
+				t.Errorf("formatCommitMessage() = %q, want %q", got, tt.want)
+			}
+			if tt.enabled {
+				if err := validateCommitMessage(commit, got); err != nil {
+					t.Errorf("validateCommitMessage() = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHeaderOnlyOnSubjectMissingHeader(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("Add export\n\n- feat: add export"))
+	config := server.Config()
+	config.Commit.HeaderOnlyOnSubject = true
+
+	_, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+	if !errors.Is(err, MissingHeaderError{}) {
+		t.Errorf("error = %v, want MissingHeaderError", err)
+	}
+	if !strings.Contains(server.Requests()[0].LastUser(), "Never start a body line with a commit type") {
+		t.Error("prompt doesn't ask for the header on the subject only")
+	}
+}
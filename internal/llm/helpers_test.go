@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// chatRequest is the part of a chat completion request the tests look at.
+// Raw holds the whole decoded body.
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Raw      map[string]any
+	Header   http.Header
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// Text returns the message content, whether it was sent as a string or as
+// text parts.
+func (m chatMessage) Text() string {
+	var s string
+	if json.Unmarshal(m.Content, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	json.Unmarshal(m.Content, &parts)
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+// LastUser returns the content of the last user message.
+func (r chatRequest) LastUser() string {
+	for i := len(r.Messages) - 1; i >= 0; i-- {
+		if r.Messages[i].Role == RoleUser {
+			return r.Messages[i].Text()
+		}
+	}
+	return ""
+}
+
+// mockOpenAI is an OpenAI-compatible server that records every chat request
+// and answers it with handler. n is the zero-based index of the request.
+type mockOpenAI struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []chatRequest
+}
+
+func newMockOpenAI(t *testing.T, handler func(w http.ResponseWriter, n int, req chatRequest)) *mockOpenAI {
+	t.Helper()
+	m := &mockOpenAI{}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req chatRequest
+		json.Unmarshal(body, &req)
+		json.Unmarshal(body, &req.Raw)
+		req.Header = r.Header.Clone()
+
+		m.mu.Lock()
+		n := len(m.requests)
+		m.requests = append(m.requests, req)
+		m.mu.Unlock()
+
+		handler(w, n, req)
+	}))
+	t.Cleanup(m.Close)
+
+	// Keep the environment from redirecting the requests
+	t.Setenv("KOMMIT_OPENAI_API_KEY", "test-key")
+	for _, key := range []string{"KOMMIT_LLM_PROVIDER", "KOMMIT_LLM_MODEL", "KOMMIT_OPENAI_BASE_URL", "OPENAI_BASE_URL"} {
+		t.Setenv(key, "")
+	}
+	// Keep cost and audit files out of the user's data directory
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	return m
+}
+
+// replyWith answers the requests in order with replies, repeating the last
+// one once they run out.
+func replyWith(replies ...string) func(w http.ResponseWriter, n int, req chatRequest) {
+	return func(w http.ResponseWriter, n int, req chatRequest) {
+		writeCompletion(w, replies[min(n, len(replies)-1)])
+	}
+}
+
+func (m *mockOpenAI) Requests() []chatRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]chatRequest(nil), m.requests...)
+}
+
+// LLMConfig points a config at the mock server.
+func (m *mockOpenAI) LLMConfig() utils.LLMConfig {
+	return utils.LLMConfig{Model: "gpt-4o-mini", BaseURL: m.URL + "/"}
+}
+
+// Config returns a config with the default commit types that talks to the
+// mock server.
+func (m *mockOpenAI) Config() *utils.Config {
+	return &utils.Config{
+		LLM:    m.LLMConfig(),
+		Commit: utils.CommitConfig{Types: testTypes},
+	}
+}
+
+var testTypes = []string{"build", "chore", "ci", "docs", "feat", "fix", "perf", "refactor", "revert", "style", "test"}
+
+func writeCompletion(w http.ResponseWriter, content string) {
+	writeJSON(w, http.StatusOK, completionBody(content))
+}
+
+func completionBody(content string) map[string]any {
+	return map[string]any{
+		"id":      "chatcmpl-test",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   "gpt-4o-mini",
+		"choices": []map[string]any{{
+			"index":         0,
+			"finish_reason": "stop",
+			"message":       map[string]any{"role": "assistant", "content": content},
+		}},
+		"usage": map[string]any{"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15},
+	}
+}
+
+// writeStructured answers with v encoded as the JSON content of the reply.
+func writeStructured(w http.ResponseWriter, v any) {
+	content, _ := json.Marshal(v)
+	writeCompletion(w, string(content))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with an OpenAI-style error. Use a 4xx status other than
+// 429 to keep the SDK from retrying.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]any{"error": map[string]any{"message": message, "type": "invalid_request_error"}})
+}
+
+// testDiff builds a diff adding one line to each of the files.
+func testDiff(paths ...string) string {
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "diff --git a/%[1]s b/%[1]s\nindex 1111111..2222222 100644\n--- a/%[1]s\n+++ b/%[1]s\n@@ -1,1 +1,2 @@\n line\n+added line\n", path)
+	}
+	return b.String()
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
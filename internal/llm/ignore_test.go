@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestIgnoredFilesEntryPoints(t *testing.T) {
+	diff := testDiff("main.go") + "diff --git a/yarn.lock b/yarn.lock\nindex 3333333..4444444 100644\n--- a/yarn.lock\n+++ b/yarn.lock\n" +
+		"@@ -1 +1 @@\n-lodash@4.17.20\n+lodash@4.17.21\n" + testDiff("long.go") + "+" + strings.Repeat("x", 50) + "\n"
+
+	tests := []struct {
+		name string
+		send func(config *utils.Config) error
+	}{
+		{"RegenerateBody", func(config *utils.Config) error {
+			_, err := RegenerateBody(config, diff, "feat: add x")
+			return err
+		}},
+		{"AmendCommitMessage", func(config *utils.Config) error {
+			_, err := AmendCommitMessage(config, "feat: add x", diff)
+			return err
+		}},
+		{"NewConversation", func(config *utils.Config) error {
+			_, _, err := NewConversation(config, diff, "")
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.Commit.IgnorePatterns = []string{"*.lock"}
+			config.Commit.MaxLineLength = 20
+
+			if err := tt.send(config); err != nil {
+				t.Fatal(err)
+			}
+			prompt := server.Requests()[0].LastUser()
+			if strings.Contains(prompt, "lodash") {
+				t.Error("prompt has the contents of an ignored file")
+			}
+			if !strings.Contains(prompt, "+added line") {
+				t.Error("prompt is missing the kept file")
+			}
+			if strings.Contains(prompt, strings.Repeat("x", 50)) {
+				t.Error("prompt has a line longer than commit.maxLineLength")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// ImperativeChecker reports whether a commit description is in the
+// imperative mood.
+type ImperativeChecker func(description string) bool
+
+// imperativeCheckers are keyed by primary language subtag. Languages without
+// a checker are never flagged.
+var imperativeCheckers = map[string]ImperativeChecker{
+	"en": isEnglishImperative,
+}
+
+// Verbs whose imperative form happens to look past tense or progressive
+var englishImperativeExceptions = []string{
+	"bleed", "breed", "embed", "exceed", "feed", "need", "proceed", "seed", "shed", "shred", "speed", "succeed",
+	"bring", "ping", "ring", "sing", "spring", "string", "swing", "wing",
+}
+
+var englishIrregularPastTense = []string{
+	"began", "broke", "built", "did", "drew", "got", "made", "ran", "rewrote", "split", "took", "was", "were", "wrote",
+}
+
+// imperativeChecker returns the checker for a BCP 47 language tag such as
+// "en" or "en-GB", defaulting to English.
+func imperativeChecker(language string) ImperativeChecker {
+	if language == "" {
+		language = "en"
+	}
+	primary, _, _ := strings.Cut(strings.ToLower(language), "-")
+	if checker, ok := imperativeCheckers[primary]; ok {
+		return checker
+	}
+	return func(string) bool { return true }
+}
+
+func isEnglishImperative(description string) bool {
+	fields := strings.Fields(description)
+	if len(fields) == 0 {
+		return true
+	}
+	word := strings.ToLower(strings.Trim(fields[0], ".,:;!?\"'`"))
+	if slices.Contains(englishImperativeExceptions, word) {
+		return true
+	}
+	if slices.Contains(englishIrregularPastTense, word) {
+		return false
+	}
+	return !strings.HasSuffix(word, "ed") && !strings.HasSuffix(word, "ing")
+}
+
+func imperativeWarnings(commit utils.CommitConfig, message string) []string {
+	subject, _ := utils.SplitCommitMessage(message)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok || imperativeChecker(commit.Language)(header.Description) {
+		return nil
+	}
+	return []string{fmt.Sprintf("the subject %q doesn't look like it is in the imperative mood", header.Description)}
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestImperativeChecker(t *testing.T) {
+	tests := []struct {
+		language    string
+		description string
+		want        bool
+	}{
+		{"", "add order export", true},
+		{"en", "added order export", false},
+		{"en", "adding order export", false},
+		{"en-GB", "Wrote the docs", false},
+		{"en", "embed the fonts", true},
+		{"en", "bring back the cache", true},
+		{"en", "", true},
+		{"ja", "注文のエクスポートを追加した", true},
+		{"ja-JP", "added order export", true},
+		{"xx", "fixed it", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language+"/"+tt.description, func(t *testing.T) {
+			if got := imperativeChecker(tt.language)(tt.description); got != tt.want {
+				t.Errorf("imperativeChecker(%q)(%q) = %v, want %v", tt.language, tt.description, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImperativeWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		reply    string
+		want     bool
+	}{
+		{"English past tense flagged", "en", "feat: added order export", true},
+		{"English imperative", "en", "feat: add order export", false},
+		{"Japanese not flagged", "ja", "feat: 注文のエクスポートを追加", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.Language = tt.language
+
+			result, err := GenerateCommitMessage(config, testDiff("export.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			warned := false
+			for _, warning := range result.Warnings {
+				warned = warned || strings.Contains(warning, "imperative mood")
+			}
+			if warned != tt.want {
+				t.Errorf("warnings = %q, want imperative warning: %v", result.Warnings, tt.want)
+			}
+		})
+	}
+}
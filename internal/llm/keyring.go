@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/zalando/go-keyring"
+)
+
+const (
+	defaultKeyringService = "kommit"
+	defaultKeyringAccount = "openai"
+)
+
+// keyringAPIKey looks the API key up in the OS keychain when llm.useKeyring
+// is set. Missing entries and unavailable keyrings, e.g. on headless CI, both
+// yield "" so key resolution can fall through.
+func keyringAPIKey(llmConfig utils.LLMConfig) string {
+	if !llmConfig.UseKeyring {
+		return ""
+	}
+
+	service := llmConfig.KeyringService
+	if service == "" {
+		service = defaultKeyringService
+	}
+	account := llmConfig.KeyringAccount
+	if account == "" {
+		account = defaultKeyringAccount
+	}
+
+	apiKey, err := keyring.Get(service, account)
+	if err != nil {
+		return ""
+	}
+	return apiKey
+}
@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/zalando/go-keyring"
+)
+
+func TestKeyringAPIKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		unavailable bool
+		stored      map[[2]string]string
+		llm         utils.LLMConfig
+		want        string
+	}{
+		{
+			name:   "hit",
+			stored: map[[2]string]string{{"kommit", "openai"}: "sk-keyring"},
+			llm:    utils.LLMConfig{UseKeyring: true},
+			want:   "sk-keyring",
+		},
+		{
+			name:   "custom service and account",
+			stored: map[[2]string]string{{"work", "azure"}: "sk-work"},
+			llm:    utils.LLMConfig{UseKeyring: true, KeyringService: "work", KeyringAccount: "azure"},
+			want:   "sk-work",
+		},
+		{
+			name:   "miss",
+			stored: map[[2]string]string{{"other", "openai"}: "sk-other"},
+			llm:    utils.LLMConfig{UseKeyring: true},
+		},
+		{
+			name:        "unavailable",
+			unavailable: true,
+			llm:         utils.LLMConfig{UseKeyring: true},
+		},
+		{
+			name:   "disabled",
+			stored: map[[2]string]string{{"kommit", "openai"}: "sk-keyring"},
+			llm:    utils.LLMConfig{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.unavailable {
+				keyring.MockInitWithError(errors.New("org.freedesktop.secrets was not provided"))
+			} else {
+				keyring.MockInit()
+			}
+			for key, secret := range tt.stored {
+				if err := keyring.Set(key[0], key[1], secret); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if got := keyringAPIKey(tt.llm); got != tt.want {
+				t.Errorf("keyringAPIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyringKeyResolution(t *testing.T) {
+	tests := []struct {
+		name        string
+		envKey      string
+		unavailable bool
+		wantAuth    string
+		wantErr     error
+	}{
+		{"environment first", "sk-env", false, "Bearer sk-env", nil},
+		{"keyring after the environment", "", false, "Bearer sk-keyring", nil},
+		{"unavailable keyring falls through", "", true, "", ErrAPIKeyMissing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			t.Setenv("KOMMIT_OPENAI_API_KEY", tt.envKey)
+			t.Setenv("OPENAI_API_KEY", "")
+			if tt.unavailable {
+				keyring.MockInitWithError(errors.New("no keyring"))
+			} else {
+				keyring.MockInit()
+				if err := keyring.Set(defaultKeyringService, defaultKeyringAccount, "sk-keyring"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			config := server.Config()
+			config.LLM.UseKeyring = true
+			_, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got := server.Requests()[0].Header.Get("Authorization"); got != tt.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuth)
+			}
+		})
+	}
+}
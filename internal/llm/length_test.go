@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestMaxTotalLength(t *testing.T) {
+	const (
+		long  = "feat: add x\n\n- Add the first part. Add the second part.\n- Add a third part that is rather long"
+		short = "feat: add x\n\n- Add the parts"
+		limit = 60
+	)
+
+	tests := []struct {
+		name    string
+		replies []string
+		want    string
+	}{
+		{"within the limit", []string{short}, short + "\n"},
+		{"re-prompt", []string{long, short}, short + "\n"},
+		{"truncation fallback", []string{long, long}, "feat: add x\n\n- Add the first part. Add the second part.…\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.replies...))
+			config := server.Config()
+			config.Commit.MaxTotalLength = limit
+
+			result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+			if len(result.Message) > limit {
+				t.Errorf("message is %d bytes, want at most %d", len(result.Message), limit)
+			}
+
+			requests := server.Requests()
+			if len(requests) != len(tt.replies) {
+				t.Fatalf("sent %d requests, want %d", len(requests), len(tt.replies))
+			}
+			if len(requests) > 1 {
+				if got, want := requests[1].LastUser(), fmt.Sprintf(tooLongPrompt, len(long)+1, limit); got != want {
+					t.Errorf("re-prompt = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestMaxTotalLengthRendered(t *testing.T) {
+	// Wrapping the bullet indents its second line, two bytes over the limit
+	const reply = "feat: add x\n\n- Add the first part of the feature. Add the second part of the feature, which wraps"
+	limit := len(reply) + len("\n") + 1
+
+	server := newMockOpenAI(t, replyWith(reply))
+	config := server.Config()
+	config.Commit.MaxTotalLength = limit
+	config.Commit.RenderProfile = utils.RenderProfilePlain
+
+	result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "feat: add x\n\n- Add the first part of the feature. Add the second part of the feature,…\n"; result.Message != want {
+		t.Errorf("message = %q, want %q", result.Message, want)
+	}
+	if len(result.Message) > limit {
+		t.Errorf("message is %d bytes, want at most %d", len(result.Message), limit)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		limit   int
+		want    string
+	}{
+		{"within the limit", "feat: add x\n", 20, "feat: add x\n"},
+		{"sentence boundary", "feat: add x\n\nOne sentence. Two sentences.\n", 30, "feat: add x\n\nOne sentence.…\n"},
+		{"line boundary", "feat: add x\n\n- Add a\n- Add b and more\n", 25, "feat: add x\n\n- Add a…\n"},
+		{"no room for a body", "feat: add x\n\nOne sentence.\n", 14, "feat: add x\n"},
+		{"subject cut", "feat: add a long subject\n", 13, "feat: add…\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateMessage(tt.message, tt.limit)
+			if got != tt.want {
+				t.Errorf("truncateMessage() = %q, want %q", got, tt.want)
+			}
+			if len(got) > tt.limit {
+				t.Errorf("truncateMessage() is %d bytes, want at most %d", len(got), tt.limit)
+			}
+		})
+	}
+}
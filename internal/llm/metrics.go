@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cowboy-bebug/kommit/internal/models"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go"
+)
+
+// Metrics describes a single API call made to the model provider.
+type Metrics struct {
+	Model            string
+	Structured       bool
+	PromptTokens     int64
+	CachedTokens     int64
+	CompletionTokens int64
+	Cost             models.Cost
+	Latency          time.Duration
+	// RateLimit is parsed from the provider's x-ratelimit-* response
+	// headers, or nil when it sent none
+	RateLimit *RateLimitInfo
+}
+
+// RateLimitInfo is the provider's view of the rate limits at the time of a
+// call. Limits and remaining counts are -1 when a header is missing.
+type RateLimitInfo struct {
+	LimitRequests     int64
+	LimitTokens       int64
+	RemainingRequests int64
+	RemainingTokens   int64
+	// ResetRequests and ResetTokens are how long until the limits reset
+	ResetRequests time.Duration
+	ResetTokens   time.Duration
+}
+
+// parseRateLimitHeaders reads OpenAI's x-ratelimit-* headers, e.g.
+// `x-ratelimit-remaining-requests: 59` and `x-ratelimit-reset-tokens: 6m0s`.
+func parseRateLimitHeaders(header http.Header) *RateLimitInfo {
+	found := false
+	count := func(name string) int64 {
+		n, err := strconv.ParseInt(header.Get(name), 10, 64)
+		if err != nil {
+			return -1
+		}
+		found = true
+		return n
+	}
+	reset := func(name string) time.Duration {
+		d, err := time.ParseDuration(header.Get(name))
+		if err != nil {
+			return 0
+		}
+		found = true
+		return d
+	}
+
+	info := &RateLimitInfo{
+		LimitRequests:     count("x-ratelimit-limit-requests"),
+		LimitTokens:       count("x-ratelimit-limit-tokens"),
+		RemainingRequests: count("x-ratelimit-remaining-requests"),
+		RemainingTokens:   count("x-ratelimit-remaining-tokens"),
+		ResetRequests:     reset("x-ratelimit-reset-requests"),
+		ResetTokens:       reset("x-ratelimit-reset-tokens"),
+	}
+	if !found {
+		return nil
+	}
+	return info
+}
+
+var (
+	metricsMu       sync.RWMutex
+	metricsCallback func(Metrics)
+)
+
+// SetMetricsCallback registers fn to be called after every API call, for both
+// commit message generation and scope inference. Pass nil to unregister.
+func SetMetricsCallback(fn func(Metrics)) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	metricsCallback = fn
+}
+
+func reportMetrics(ctx context.Context, m Metrics) {
+	if usage, ok := ctx.Value(usageKey{}).(*usageRecorder); ok {
+		usage.add(m)
+	}
+
+	metricsMu.RLock()
+	fn := metricsCallback
+	metricsMu.RUnlock()
+
+	if fn != nil {
+		fn(m)
+	}
+}
+
+func reportOpenAIUsage(ctx context.Context, model string, structured bool, usage openai.CompletionUsage, rateLimit *RateLimitInfo, start time.Time) models.Cost {
+	cost := models.EstimateCost(model, usage)
+	reportMetrics(ctx, Metrics{
+		Model:            model,
+		Structured:       structured,
+		PromptTokens:     usage.PromptTokens,
+		CachedTokens:     usage.PromptTokensDetails.CachedTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             cost,
+		Latency:          time.Since(start),
+		RateLimit:        rateLimit,
+	})
+	return cost
+}
+
+type usageKey struct{}
+
+// usageRecorder totals the token usage of every API call made with a context
+// returned by withUsageRecorder, and keeps each request with its raw reply
+// for the audit record.
+type usageRecorder struct {
+	mu               sync.Mutex
+	promptTokens     int64
+	completionTokens int64
+	exchanges        []utils.AuditExchange
+}
+
+func withUsageRecorder(ctx context.Context) (context.Context, *usageRecorder) {
+	usage := &usageRecorder{}
+	return context.WithValue(ctx, usageKey{}, usage), usage
+}
+
+func (u *usageRecorder) add(m Metrics) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.promptTokens += m.PromptTokens
+	u.completionTokens += m.CompletionTokens
+}
+
+func (u *usageRecorder) totals() (int64, int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.promptTokens, u.completionTokens
+}
+
+func (u *usageRecorder) recorded() []utils.AuditExchange {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return slices.Clone(u.exchanges)
+}
+
+// recordExchange keeps the turns sent to the model and its unprocessed reply
+// when ctx comes from withUsageRecorder.
+func recordExchange(ctx context.Context, turns []ChatTurn, response string, err error) {
+	usage, ok := ctx.Value(usageKey{}).(*usageRecorder)
+	if !ok {
+		return
+	}
+
+	exchange := utils.AuditExchange{Response: response}
+	for _, turn := range turns {
+		exchange.Messages = append(exchange.Messages, utils.AuditMessage{Role: turn.Role, Content: turn.Content})
+	}
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+
+	usage.mu.Lock()
+	defer usage.mu.Unlock()
+	usage.exchanges = append(usage.exchanges, exchange)
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordMetrics collects the metrics of every API call made during the test.
+func recordMetrics(t *testing.T) func() []Metrics {
+	var mu sync.Mutex
+	var recorded []Metrics
+	SetMetricsCallback(func(m Metrics) {
+		mu.Lock()
+		defer mu.Unlock()
+		recorded = append(recorded, m)
+	})
+	t.Cleanup(func() { SetMetricsCallback(nil) })
+
+	return func() []Metrics {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Metrics(nil), recorded...)
+	}
+}
+
+func TestScopeInferenceMetrics(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		writeStructured(w, Scopes{Scopes: []string{"api"}})
+	})
+	metrics := recordMetrics(t)
+
+	_, err := GenerateScopesFromFilenamesWithProgress(context.Background(), server.LLMConfig(), []string{"api/x.go"}, nil, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := metrics()
+	if len(recorded) != 1 {
+		t.Fatalf("metrics callback fired %d times, want 1", len(recorded))
+	}
+	m := recorded[0]
+	if m.Model != "gpt-4o-mini" || !m.Structured || m.PromptTokens != 10 || m.CompletionTokens != 5 {
+		t.Errorf("metrics = %+v, want the structured call's model and usage", m)
+	}
+	if m.Cost <= 0 || m.Latency <= 0 {
+		t.Errorf("metrics = %+v, want a cost and latency", m)
+	}
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    *RateLimitInfo
+	}{
+		{
+			name: "all headers",
+			headers: map[string]string{
+				"x-ratelimit-limit-requests":     "500",
+				"x-ratelimit-limit-tokens":       "200000",
+				"x-ratelimit-remaining-requests": "499",
+				"x-ratelimit-remaining-tokens":   "199000",
+				"x-ratelimit-reset-requests":     "120ms",
+				"x-ratelimit-reset-tokens":       "1m30s",
+			},
+			want: &RateLimitInfo{
+				LimitRequests: 500, LimitTokens: 200000, RemainingRequests: 499, RemainingTokens: 199000,
+				ResetRequests: 120 * time.Millisecond, ResetTokens: 90 * time.Second,
+			},
+		},
+		{
+			name:    "some headers",
+			headers: map[string]string{"x-ratelimit-remaining-requests": "3", "x-ratelimit-reset-tokens": "bogus"},
+			want:    &RateLimitInfo{LimitRequests: -1, LimitTokens: -1, RemainingRequests: 3, RemainingTokens: -1},
+		},
+		{
+			name:    "none",
+			headers: map[string]string{"content-type": "application/json"},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			if got := parseRateLimitHeaders(header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRateLimitHeaders() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitMetrics(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		w.Header().Set("x-ratelimit-remaining-requests", "41")
+		w.Header().Set("x-ratelimit-reset-requests", "2s")
+		writeCompletion(w, "feat: add x")
+	})
+	metrics := recordMetrics(t)
+
+	if _, err := GenerateCommitMessage(server.Config(), testDiff("x.go"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := metrics()
+	if len(recorded) != 1 || recorded[0].RateLimit == nil {
+		t.Fatalf("metrics = %+v, want one call with rate limits", recorded)
+	}
+	if rl := recorded[0].RateLimit; rl.RemainingRequests != 41 || rl.ResetRequests != 2*time.Second || rl.LimitTokens != -1 {
+		t.Errorf("rate limits = %+v", rl)
+	}
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const templateSubject = "<describe the change>"
+
+// GenerateTemplateMessage builds a fill-in-the-blanks commit message from
+// deterministic heuristics only, without any API call, for air-gapped
+// environments or when the provider is unavailable. The type is inferred from
+// the kind of files changed, the scope is derived from their paths, and the
+// body lists every changed file.
+func GenerateTemplateMessage(config *utils.Config, diff string) string {
+	files := utils.ParseDiff(diff)
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path()
+	}
+
+	header := utils.CommitHeader{
+		Type:        templateType(config.Commit, files),
+		Description: templateSubject,
+	}
+	header.Scope = utils.ScopeFromRules(paths, config.Commit.PathScopeRules)
+	if header.Scope == "" {
+		header.Scope = utils.ScopeFromPaths(paths, config.Commit.Scopes)
+	}
+
+	message := header.String()
+	if bullets := templateBullets(files); bullets != "" {
+		message += "\n\n" + bullets
+	}
+	return formatCommitMessage(config.Commit, message)
+}
+
+// templateType guesses the commit type from the changed files, falling back
+// to the first allowed type when the guess isn't allowed.
+func templateType(commit utils.CommitConfig, files []utils.FileDiff) string {
+	if commit.ForcedType != "" {
+		return commit.ForcedType
+	}
+
+	guess := "chore"
+	switch {
+	case utils.IsFormatOnly(files):
+		guess = "style"
+	case allFiles(files, isDocFile):
+		guess = "docs"
+	case allFiles(files, isTestFile):
+		guess = "test"
+	case allFiles(files, isCIFile):
+		guess = "ci"
+	case allFiles(files, isBuildFile):
+		guess = "build"
+	case allFiles(files, func(f utils.FileDiff) bool { return f.Status == utils.FileStatusAdded }):
+		guess = "feat"
+	}
+
+	allowed := commit.AllowedTypes()
+	if len(allowed) == 0 || slices.Contains(allowed, guess) {
+		return guess
+	}
+	return allowed[0]
+}
+
+func templateBullets(files []utils.FileDiff) string {
+	var bullets []string
+	for i, f := range files {
+		if i == maxListedFiles {
+			bullets = append(bullets, fmt.Sprintf("- …and %d more files", len(files)-maxListedFiles))
+			break
+		}
+		switch f.Status {
+		case utils.FileStatusAdded:
+			bullets = append(bullets, "- Add "+f.Path())
+		case utils.FileStatusDeleted:
+			bullets = append(bullets, "- Remove "+f.Path())
+		case utils.FileStatusRenamed:
+			bullets = append(bullets, fmt.Sprintf("- Rename %s to %s", f.OldPath, f.NewPath))
+		default:
+			bullets = append(bullets, "- Update "+f.Path())
+		}
+	}
+	return strings.Join(bullets, "\n")
+}
+
+func allFiles(files []utils.FileDiff, match func(utils.FileDiff) bool) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if !match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDocFile(f utils.FileDiff) bool {
+	p := strings.ToLower(f.Path())
+	switch path.Ext(p) {
+	case ".md", ".mdx", ".rst", ".adoc", ".txt":
+		return true
+	}
+	return strings.HasPrefix(p, "docs/") || strings.HasPrefix(path.Base(p), "license")
+}
+
+func isTestFile(f utils.FileDiff) bool {
+	p := f.Path()
+	base := path.Base(p)
+	return strings.HasSuffix(base, "_test.go") || strings.Contains(base, ".test.") || strings.Contains(base, ".spec.") ||
+		strings.HasPrefix(base, "test_") || strings.HasPrefix(p, "test/") || strings.HasPrefix(p, "tests/") ||
+		strings.Contains(p, "/testdata/") || strings.HasPrefix(p, "testdata/")
+}
+
+func isCIFile(f utils.FileDiff) bool {
+	p := f.Path()
+	return strings.HasPrefix(p, ".github/workflows/") || strings.HasPrefix(p, ".circleci/") ||
+		p == ".gitlab-ci.yml" || p == ".travis.yml" || p == "Jenkinsfile" || p == "azure-pipelines.yml"
+}
+
+func isBuildFile(f utils.FileDiff) bool {
+	switch path.Base(f.Path()) {
+	case "go.mod", "go.sum", "Makefile", "Dockerfile", "package.json", "package-lock.json", "yarn.lock",
+		"pnpm-lock.yaml", "Cargo.toml", "Cargo.lock", "pyproject.toml", "requirements.txt", ".goreleaser.yaml":
+		return true
+	}
+	return false
+}
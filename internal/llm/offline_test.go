@@ -0,0 +1,79 @@
+package llm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const addedFileDiff = `diff --git a/internal/llm/cache.go b/internal/llm/cache.go
+new file mode 100644
+index 0000000..1111111
+--- /dev/null
++++ b/internal/llm/cache.go
+@@ -0,0 +1 @@
++package llm
+`
+
+func TestGenerateTemplateMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		commit utils.CommitConfig
+		diff   string
+		want   string
+	}{
+		{
+			name:   "modified files",
+			commit: utils.CommitConfig{Types: testTypes, Scopes: []string{"llm", "utils"}},
+			diff:   testDiff("internal/llm/openai.go", "internal/llm/format.go"),
+			want:   "chore(llm): <describe the change>\n\n- Update internal/llm/openai.go\n- Update internal/llm/format.go\n",
+		},
+		{
+			name:   "added file",
+			commit: utils.CommitConfig{Types: testTypes, Scopes: []string{"llm"}},
+			diff:   addedFileDiff,
+			want:   "feat(llm): <describe the change>\n\n- Add internal/llm/cache.go\n",
+		},
+		{
+			name:   "docs across directories",
+			commit: utils.CommitConfig{Types: testTypes},
+			diff:   testDiff("README.md", "docs/guide.md"),
+			want:   "docs: <describe the change>\n\n- Update README.md\n- Update docs/guide.md\n",
+		},
+		{
+			name:   "tests",
+			commit: utils.CommitConfig{Types: testTypes},
+			diff:   testDiff("internal/llm/openai_test.go"),
+			want:   "test(internal): <describe the change>\n\n- Update internal/llm/openai_test.go\n",
+		},
+		{
+			name:   "guess not allowed",
+			commit: utils.CommitConfig{Types: []string{"feat", "fix"}},
+			diff:   testDiff(".github/workflows/ci.yml"),
+			want:   "feat(.github): <describe the change>\n\n- Update .github/workflows/ci.yml\n",
+		},
+		{
+			name:   "forced type and path rule",
+			commit: utils.CommitConfig{Types: testTypes, ForcedType: "perf", PathScopeRules: []utils.PathScopeRule{{Pattern: "internal/**", Scope: "core"}}},
+			diff:   testDiff("internal/llm/openai.go"),
+			want:   "perf(core): <describe the change>\n\n- Update internal/llm/openai.go\n",
+		},
+	}
+
+	// No request may reach a provider
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	}))
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{LLM: utils.LLMConfig{BaseURL: server.URL + "/"}, Commit: tt.commit}
+			if got := GenerateTemplateMessage(config, tt.diff); got != tt.want {
+				t.Errorf("GenerateTemplateMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,22 @@ package llm
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/cowboy-bebug/kommit/internal/models"
@@ -22,12 +34,24 @@ const (
 	topP             = 1.0
 	presencePenalty  = 0.0
 	frequencyPenalty = 0.0
+
+	defaultTopLogprobs = 5
+
+	// scopeBatchSize caps the number of filenames sent per scope request
+	scopeBatchSize = 500
 )
 
 // System prompts
 const (
 	kommitSystemPrompt = "You are an AI that generates Conventional Git commit messages."
 	jsonResponsePrompt = "Return your response as a valid JSON object."
+	missingScopePrompt = "Your commit message has no scope, but a scope is required. Rewrite it using the closest of these scopes: %s"
+	jsonRetryPrompt    = "Your previous response was not valid JSON. Return ONLY valid JSON, without any prose or code fences."
+	tooLongPrompt      = "Your commit message is %d characters long, but it must be at most %d. Rewrite it to be more concise, " +
+		"keeping the same subject format and the most important details."
+	rangePrompt = "\n## Note:\n- The diff above is the combined result of **multiple commits**, e.g. a whole feature branch. " +
+		"Write one commit message that summarizes the overall change, as for a squash merge, rather than describing " +
+		"individual steps.\n"
 )
 
 // User prompts
@@ -79,54 +103,264 @@ const (
 	kommitBaseUserPrompt = promptMain + promptGeneralRules + promptCommitTypeGuidelines + promptScopeRules + promptMessageFormatting
 )
 
-func newClient() (*openai.Client, error) {
+func newClient(ctx context.Context, llmConfig utils.LLMConfig) (*openai.Client, error) {
 	// KOMMIT_OPENAI_API_KEY takes precedence
 	apiKey := os.Getenv("KOMMIT_OPENAI_API_KEY")
 	if apiKey == "" {
 		apiKey = os.Getenv("OPENAI_API_KEY")
 	}
+	if apiKey == "" {
+		apiKey = keyringAPIKey(llmConfig)
+	}
 
-	// If both are missing, return an error
+	// If all are missing, return an error
 	if apiKey == "" {
 		return nil, &APIKeyMissingError{}
 	}
 
+	httpClient, err := newHTTPClient(llmConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	baseURL := llmConfig.BaseURL
+	if len(llmConfig.Endpoints) > 0 {
+		if endpoint, err := selectFastestEndpoint(ctx, llmConfig, llmConfig.Endpoints); err == nil {
+			baseURL = strings.TrimSuffix(endpoint, "/") + "/"
+		}
+	}
+
 	return openai.NewClient(
 		option.WithAPIKey(apiKey),
-		option.WithRequestTimeout(timeout),
+		option.WithBaseURL(baseURL),
+		option.WithHTTPClient(httpClient),
+		option.WithRequestTimeout(responseTimeout(llmConfig)),
 	), nil
 }
 
-type ChatResult[T any] struct {
-	Message T
-	Cost    models.Cost
+// responseTimeout is how long a request may take once sent, llm.responseTimeout
+// or the default.
+func responseTimeout(llmConfig utils.LLMConfig) time.Duration {
+	if llmConfig.ResponseTimeout > 0 {
+		return llmConfig.ResponseTimeout
+	}
+	return timeout
 }
 
-func chat(model, prompt string) (ChatResult[string], error) {
-	client, err := newClient()
-	if err != nil {
-		return ChatResult[string]{}, err
+// newHTTPClient returns a client that goes through the proxy named by
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY, gives up connecting after
+// llm.connectTimeout, and additionally trusts llm.caCertFile when set.
+func newHTTPClient(llmConfig utils.LLMConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if llmConfig.ConnectTimeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: llmConfig.ConnectTimeout, KeepAlive: 30 * time.Second}).DialContext
+		transport.TLSHandshakeTimeout = llmConfig.ConnectTimeout
 	}
 
-	resp, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Model: openai.F(model),
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(kommitSystemPrompt),
-			openai.UserMessage(prompt),
-		}),
-		Temperature:      openai.Float(temperature),
+	if llmConfig.CACertFile != "" {
+		pem, err := os.ReadFile(llmConfig.CACertFile)
+		if err != nil {
+			return nil, CACertError{Path: llmConfig.CACertFile, Err: err}
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, CACertError{Path: llmConfig.CACertFile, Err: fmt.Errorf("no PEM certificates found")}
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &http.Client{Transport: userAgentTransport{
+		base:      budgetTransport{base: transport},
+		userAgent: userAgent(llmConfig),
+	}}, nil
+}
+
+// Version is reported in the default User-Agent, set from the build version.
+var Version = "unknown"
+
+// userAgent returns llm.userAgent, or `kommit/<version>` by default.
+func userAgent(llmConfig utils.LLMConfig) string {
+	if llmConfig.UserAgent != "" {
+		return llmConfig.UserAgent
+	}
+	return "kommit/" + Version
+}
+
+// userAgentTransport sets the User-Agent of every outgoing request, replacing
+// the one set by the provider SDKs.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.base.RoundTrip(req)
+}
+
+type ChatResult[T any] struct {
+	Message  T
+	Cost     models.Cost
+	Warnings []string
+	// Logprobs holds token-level log probabilities when llm.includeLogprobs
+	// is set
+	Logprobs []openai.ChatCompletionTokenLogprob
+	// Confidence is set when commit.minConfidence is configured, see
+	// Confidence
+	Confidence float64
+	// Alternatives holds extra candidates generated because Confidence fell
+	// below commit.minConfidence
+	Alternatives []string
+	// Rationale explains the chosen type and scope when commit.explain is set
+	Rationale string
+	// APICallCount is the number of requests sent to the provider for a
+	// commit message, counting retries and re-prompts
+	APICallCount int
+	// DiffStats describes the diff the commit message was generated from,
+	// after ignored files and trimming
+	DiffStats utils.DiffStats
+}
+
+func newChatParams(llmConfig utils.LLMConfig, messages []openai.ChatCompletionMessageParamUnion) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:            openai.F(llmConfig.Model),
+		Messages:         openai.F(messages),
+		Temperature:      openai.Float(effectiveTemperature(llmConfig)),
 		TopP:             openai.Float(topP),
 		PresencePenalty:  openai.Float(presencePenalty),
 		FrequencyPenalty: openai.Float(frequencyPenalty),
-	})
+	}
+
+	// Only send a user identifier when one is configured
+	if llmConfig.UserID != "" {
+		params.User = openai.F(hashUserID(llmConfig.UserID))
+	}
+
+	if llmConfig.IncludeLogprobs {
+		params.Logprobs = openai.Bool(true)
+		topLogprobs := llmConfig.TopLogprobs
+		if topLogprobs == 0 {
+			topLogprobs = defaultTopLogprobs
+		}
+		params.TopLogprobs = openai.Int(int64(topLogprobs))
+	}
+
+	omitParams(&params, llmConfig.OmitParams)
+	return params
+}
+
+// omitParams clears the parameters named in llm.omitParams so they are left
+// out of the request body. response_format is handled by structuredParams.
+func omitParams(params *openai.ChatCompletionNewParams, names []string) {
+	// The SDK's field type is internal, so unset fields are copied from here
+	var unset openai.ChatCompletionNewParams
+	for _, name := range names {
+		switch name {
+		case "temperature":
+			params.Temperature = unset.Temperature
+		case "top_p":
+			params.TopP = unset.TopP
+		case "presence_penalty":
+			params.PresencePenalty = unset.PresencePenalty
+		case "frequency_penalty":
+			params.FrequencyPenalty = unset.FrequencyPenalty
+		case "user":
+			params.User = unset.User
+		case "logprobs":
+			params.Logprobs = unset.Logprobs
+		case "top_logprobs":
+			params.TopLogprobs = unset.TopLogprobs
+		}
+	}
+}
+
+// hashUserID turns the configured identifier into a stable opaque value so
+// no personal information (e.g. an email address) leaves the machine.
+func hashUserID(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return hex.EncodeToString(sum[:])
+}
+
+// checkRequestSize rejects requests whose serialized body exceeds the
+// configured limit. A limit of zero disables the check.
+func checkRequestSize(params openai.ChatCompletionNewParams, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(params)
 	if err != nil {
-		return ChatResult[string]{}, &OpenAIRequestError{Err: err}
+		return err
 	}
+	return checkBodySize(body, limit)
+}
 
-	return ChatResult[string]{
-		Message: resp.Choices[0].Message.Content,
-		Cost:    models.EstimateCost(model, resp.Usage),
-	}, nil
+func checkBodySize(body []byte, limit int) error {
+	if limit > 0 && len(body) > limit {
+		return RequestTooLargeError{Size: len(body), Limit: limit}
+	}
+	return nil
+}
+
+func chat(ctx context.Context, llmConfig utils.LLMConfig, prompt string) (ChatResult[string], error) {
+	return chatTurns(ctx, llmConfig, []ChatTurn{{Role: RoleUser, Content: prompt}})
+}
+
+// chatTurns sends a whole conversation to the model and returns its reply to
+// the last turn. A trailing assistant turn primes the reply: the model
+// continues from it, and it is prepended to the returned message.
+func chatTurns(ctx context.Context, llmConfig utils.LLMConfig, turns []ChatTurn) (ChatResult[string], error) {
+	result, err := sendTurns(ctx, llmConfig, turns)
+	if prime := primeOf(turns); err == nil && prime != "" && !strings.HasPrefix(result.Message, prime) {
+		result.Message = prime + result.Message
+	}
+	return result, err
+}
+
+// primeOf returns the content of a trailing assistant turn, if any.
+func primeOf(turns []ChatTurn) string {
+	if len(turns) == 0 || turns[len(turns)-1].Role != RoleAssistant {
+		return ""
+	}
+	return turns[len(turns)-1].Content
+}
+
+func sendTurns(ctx context.Context, llmConfig utils.LLMConfig, turns []ChatTurn) (ChatResult[string], error) {
+	llmConfig, err := resolveLLMConfig(llmConfig)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+	if llmConfig.Provider == utils.ProviderBedrock {
+		return bedrockChat(ctx, llmConfig, kommitSystemPrompt, turns)
+	}
+
+	client, err := newClient(ctx, llmConfig)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+
+	messages := []openai.ChatCompletionMessageParamUnion{openai.SystemMessage(kommitSystemPrompt)}
+	for _, turn := range turns {
+		if turn.Role == RoleAssistant {
+			messages = append(messages, openai.AssistantMessage(turn.Content))
+		} else {
+			messages = append(messages, openai.UserMessage(turn.Content))
+		}
+	}
+
+	params := newChatParams(llmConfig, messages)
+	if err := checkRequestSize(params, llmConfig.MaxRequestBytes); err != nil {
+		return ChatResult[string]{}, err
+	}
+
+	result, err := completeWithTools(ctx, client, llmConfig, params)
+	recordExchange(ctx, turns, result.Message, err)
+	return result, err
 }
 
 func wrapInCSVCodeBlock(x []string) string {
@@ -147,46 +381,500 @@ func GenerateSchema[T any]() any {
 	return schema
 }
 
-func chatStructured[T any](model, prompt string, schema openai.ResponseFormatJSONSchemaJSONSchemaParam) (ChatResult[T], error) {
-	client, err := newClient()
+func chatStructured[T any](ctx context.Context, llmConfig utils.LLMConfig, prompt string, schema openai.ResponseFormatJSONSchemaJSONSchemaParam) (ChatResult[T], error) {
+	llmConfig, err := resolveLLMConfig(llmConfig)
 	if err != nil {
 		return ChatResult[T]{}, err
 	}
+	if llmConfig.Provider == utils.ProviderBedrock {
+		return bedrockChatStructured[T](ctx, llmConfig, prompt, schema.Schema.Value)
+	}
 
-	resp, err := client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Model:            openai.F(model),
-		Temperature:      openai.Float(temperature),
-		TopP:             openai.Float(topP),
-		PresencePenalty:  openai.Float(presencePenalty),
-		FrequencyPenalty: openai.Float(frequencyPenalty),
-		Messages: openai.F([]openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(kommitSystemPrompt + jsonResponsePrompt),
-			openai.UserMessage(prompt),
-		}),
-		ResponseFormat: openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](
-			openai.ResponseFormatJSONSchemaParam{
-				Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
-				JSONSchema: openai.F(schema),
-			}),
-	})
+	client, err := newClient(ctx, llmConfig)
+	if err != nil {
+		return ChatResult[T]{}, err
+	}
+
+	if llmConfig.SchemaName != "" {
+		schema.Name = openai.F(llmConfig.SchemaName)
+	}
+	if llmConfig.StrictSchema != nil {
+		schema.Strict = openai.Bool(*llmConfig.StrictSchema)
+	}
+
+	params, err := structuredParams(llmConfig, prompt, schema, true)
 	if err != nil {
+		return ChatResult[T]{}, err
+	}
+
+	var warnings []string
+	start := time.Now()
+	resp, rateLimit, err := createCompletion(ctx, client, params)
+	if isSchemaUnsupported(err) {
+		// Some models and deployments reject JSON schemas, so ask for plain
+		// JSON and describe the schema in the prompt instead
+		warnings = append(warnings, fmt.Sprintf("%s rejected the JSON schema, fell back to prompt-enforced JSON", llmConfig.Model))
+		if params, err = structuredParams(llmConfig, prompt, schema, false); err != nil {
+			return ChatResult[T]{}, err
+		}
+		start = time.Now()
+		resp, rateLimit, err = createCompletion(ctx, client, params)
+	}
+	turns := []ChatTurn{{Role: RoleUser, Content: prompt}}
+	if err != nil {
+		recordExchange(ctx, turns, "", err)
 		return ChatResult[T]{}, &OpenAIRequestError{Err: err}
 	}
+	cost := reportOpenAIUsage(ctx, llmConfig.Model, true, resp.Usage, rateLimit, start)
+
+	choice, err := firstChoice(resp)
+	recordExchange(ctx, turns, choice.Message.Content, err)
+	if err != nil {
+		return ChatResult[T]{Cost: cost}, err
+	}
+	content := choice.Message.Content
+	result, parseErr := parseStructuredWithSchema[T](content, schema.Schema.Value)
+	if parseErr != nil {
+		// Give the model one more chance to fix its answer
+		params.Messages = openai.F(append(params.Messages.Value,
+			openai.AssistantMessage(content),
+			openai.UserMessage(jsonRetryPrompt),
+		))
+		turns = append(turns, ChatTurn{Role: RoleAssistant, Content: content}, ChatTurn{Role: RoleUser, Content: jsonRetryPrompt})
+		start = time.Now()
+		resp, rateLimit, err = createCompletion(ctx, client, params)
+		if err != nil {
+			recordExchange(ctx, turns, "", err)
+			return ChatResult[T]{Cost: cost}, &OpenAIRequestError{Err: err}
+		}
+		cost += reportOpenAIUsage(ctx, llmConfig.Model, true, resp.Usage, rateLimit, start)
 
-	content := resp.Choices[0].Message.Content
-	var result T
-	if err := json.Unmarshal([]byte(content), &result); err != nil {
-		return ChatResult[T]{}, &JSONParseError{Err: err}
+		choice, err = firstChoice(resp)
+		recordExchange(ctx, turns, choice.Message.Content, err)
+		if err != nil {
+			return ChatResult[T]{Cost: cost}, err
+		}
+		result, parseErr = parseStructuredWithSchema[T](choice.Message.Content, schema.Schema.Value)
+		if errors.Is(parseErr, ErrSchemaViolation) {
+			return ChatResult[T]{Cost: cost}, parseErr
+		}
+		if parseErr != nil {
+			return ChatResult[T]{Cost: cost}, &JSONParseError{Err: parseErr}
+		}
 	}
 
 	return ChatResult[T]{
-		Message: result,
-		Cost:    models.EstimateCost(model, resp.Usage),
+		Message:  result,
+		Cost:     cost,
+		Warnings: warnings,
 	}, nil
 }
 
+// structuredParams builds a request for a JSON answer. With nativeSchema the
+// schema is passed as the response format; otherwise it is put in the prompt
+// and only a JSON object is requested.
+func structuredParams(llmConfig utils.LLMConfig, prompt string, schema openai.ResponseFormatJSONSchemaJSONSchemaParam, nativeSchema bool) (openai.ChatCompletionNewParams, error) {
+	omitResponseFormat := slices.Contains(llmConfig.OmitParams, "response_format")
+	if omitResponseFormat {
+		// Without a response format the schema has to be in the prompt
+		nativeSchema = false
+	}
+
+	var responseFormat openai.ChatCompletionNewParamsResponseFormatUnion = openai.ResponseFormatJSONSchemaParam{
+		Type:       openai.F(openai.ResponseFormatJSONSchemaTypeJSONSchema),
+		JSONSchema: openai.F(schema),
+	}
+	if !nativeSchema {
+		embedded, err := schemaPrompt(schema.Schema.Value)
+		if err != nil {
+			return openai.ChatCompletionNewParams{}, err
+		}
+		prompt += embedded
+		responseFormat = openai.ResponseFormatJSONObjectParam{
+			Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
+		}
+	}
+
+	params := newChatParams(llmConfig, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(kommitSystemPrompt + jsonResponsePrompt),
+		openai.UserMessage(prompt),
+	})
+	if !omitResponseFormat {
+		params.ResponseFormat = openai.F(responseFormat)
+	}
+	if err := checkRequestSize(params, llmConfig.MaxRequestBytes); err != nil {
+		return openai.ChatCompletionNewParams{}, err
+	}
+	return params, nil
+}
+
+// schemaPrompt describes a JSON schema for providers or models that can't
+// enforce it natively.
+func schemaPrompt(schema any) (string, error) {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return "", err
+	}
+	return "\n\nRespond with a JSON object matching this JSON schema:\n" + string(schemaJSON), nil
+}
+
+// isSchemaUnsupported reports whether the API rejected the request because
+// of its JSON schema response format.
+func isSchemaUnsupported(err error) bool {
+	var apiErr *openai.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	// The error body isn't always unwrapped into the fields, so check it raw
+	body := strings.ToLower(apiErr.JSON.RawJSON())
+	return strings.Contains(body, "response_format") || strings.Contains(body, "json_schema") || strings.Contains(body, "strict")
+}
+
+// firstChoice returns the first choice of a response. Some OpenAI-compatible
+// servers answer with no choices at all, e.g. when a content filter kicks in.
+func firstChoice(resp *openai.ChatCompletion) (openai.ChatCompletionChoice, error) {
+	if len(resp.Choices) == 0 {
+		return openai.ChatCompletionChoice{}, &OpenAIRequestError{Err: errors.New("response contains no choices")}
+	}
+	return resp.Choices[0], nil
+}
+
 func GenerateCommitMessage(config *utils.Config, diff, userContext string) (ChatResult[string], error) {
+	return generateCommitMessage(context.Background(), config, diff, userContext)
+}
+
+// GenerateCommitMessageOfType is like GenerateCommitMessage, but pins the
+// commit type to forcedType, which must be one of the allowed types. An empty
+// forcedType falls back to commit.forcedType.
+func GenerateCommitMessageOfType(config *utils.Config, diff, userContext, forcedType string) (ChatResult[string], error) {
+	if forcedType != "" {
+		forced := *config
+		forced.Commit.ForcedType = forcedType
+		config = &forced
+	}
+	return generateCommitMessage(context.Background(), config, diff, userContext)
+}
+
+// GenerateCommitMessageFromRange generates a single summary commit message,
+// e.g. for a squash merge, from the aggregate diff of a range such as
+// `git diff main...feature`.
+func GenerateCommitMessageFromRange(config *utils.Config, rangeDiff string) (ChatResult[string], error) {
+	return generateMessage(context.Background(), config, rangeDiff, generateOptions{isRange: true})
+}
+
+// GenerateCommitMessageWithIntent is like GenerateCommitMessage, but also
+// tells the model what the author meant to do, e.g. "refactor auth to use
+// context". The intent guides the description; the diff still decides what
+// the message says.
+func GenerateCommitMessageWithIntent(config *utils.Config, diff, userContext, intent string) (ChatResult[string], error) {
+	return generateMessage(context.Background(), config, diff, generateOptions{userContext: userContext, intent: intent})
+}
+
+// GenerateCommitMessageForIndex is like GenerateCommitMessageWithIntent for
+// the staged changes. worktreeDiff is `git diff HEAD`, from which the files
+// staged hunk by hunk are told apart.
+func GenerateCommitMessageForIndex(config *utils.Config, diff, worktreeDiff, userContext, intent string) (ChatResult[string], error) {
+	return generateMessage(context.Background(), config, diff, generateOptions{
+		userContext:  userContext,
+		intent:       intent,
+		worktreeDiff: worktreeDiff,
+	})
+}
+
+func generateCommitMessage(ctx context.Context, config *utils.Config, diff, userContext string) (ChatResult[string], error) {
+	return generateMessage(ctx, config, diff, generateOptions{userContext: userContext})
+}
+
+// generateOptions adds per-invocation context to a generation.
+type generateOptions struct {
+	userContext string
+	intent      string
+	// isRange marks diffs spanning several commits
+	isRange bool
+	// worktreeDiff is `git diff HEAD` when diff is the index
+	worktreeDiff string
+}
+
+// generateMessage runs the whole pipeline for diff: safety checks, filtering,
+// prompting and post-processing.
+func generateMessage(ctx context.Context, config *utils.Config, diff string, opts generateOptions) (ChatResult[string], error) {
+	if !utils.HasChanges(diff) {
+		return ChatResult[string]{}, EmptyDiffError{}
+	}
+	if forced := config.Commit.ForcedType; forced != "" && !slices.Contains(config.Commit.AllowedTypes(), forced) {
+		return ChatResult[string]{}, utils.ConfigFieldError{Field: "commit.forcedType", Message: fmt.Sprintf("%q is not an allowed type", forced)}
+	}
+
+	diff, err := prepareDiff(config, diff)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+	stats := utils.ComputeDiffStats(utils.ParseDiff(diff))
+
+	if config.Commit.DetectReverts {
+		reverted, err := utils.FindRevertedCommit(diff)
+		if err == nil && reverted != nil {
+			return ChatResult[string]{Message: revertMessage(reverted), DiffStats: stats}, nil
+		}
+	}
+
+	prompt, err := commitPrompt(config, diff, opts.userContext)
+	if err != nil {
+		return ChatResult[string]{}, err
+	}
+	if opts.isRange {
+		prompt += rangePrompt
+	}
+	if opts.worktreeDiff != "" {
+		prompt += partialFilesPrompt(utils.PartiallyStagedFiles(utils.ParseDiff(diff), utils.ParseDiff(opts.worktreeDiff)))
+	}
+	prompt += intentPrompt(opts.intent)
+
+	var result ChatResult[string]
+	if !config.Audit.Enabled {
+		result, err = completeWithinBudget(ctx, config, diff, prompt)
+	} else {
+		start := time.Now()
+		ctx, usage := withUsageRecorder(ctx)
+		result, err = completeWithinBudget(ctx, config, diff, prompt)
+		if auditErr := writeAuditRecord(config, start, diff, prompt, result, usage, err); auditErr != nil {
+			result.Warnings = append(result.Warnings, auditErr.Error())
+		}
+	}
+
+	if limit := config.Commit.WarnDiffBytes; limit > 0 && len(diff) > limit {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("the diff is %d bytes, more than commit.warnDiffBytes (%d); "+
+			"consider ignoring generated files or committing in smaller chunks", len(diff), limit))
+	}
+	result.DiffStats = stats
+	return result, err
+}
+
+// completeWithinBudget runs completeCommitMessage under
+// llm.totalAttemptBudget, when set, so that retries can't multiply the number
+// of API calls, and reports how many calls were made. A budget that only runs
+// out in an optional step, such as the shorter-message re-prompt, keeps the
+// best message so far.
+func completeWithinBudget(ctx context.Context, config *utils.Config, diff, prompt string) (ChatResult[string], error) {
+	ctx, budget, release := withAttemptBudget(ctx, config.LLM.TotalAttemptBudget)
+	defer release()
+
+	result, err := completeCommitMessage(ctx, config, diff, prompt)
+	result.APICallCount = budget.attempts()
+	if budgetErr := budget.exhausted(); budgetErr != nil {
+		if err != nil {
+			return result, budgetErr
+		}
+		result.Warnings = append(result.Warnings, fmt.Sprintf("llm.totalAttemptBudget (%d) ran out, kept the best message so far", config.LLM.TotalAttemptBudget))
+	}
+	return result, err
+}
+
+// writeAuditRecord stores what was sent and received for one generation.
+func writeAuditRecord(config *utils.Config, start time.Time, diff, prompt string, result ChatResult[string], usage *usageRecorder, genErr error) error {
+	llmConfig, err := resolveLLMConfig(config.LLM)
+	if err != nil {
+		llmConfig = config.LLM
+	}
+
+	record := utils.AuditRecord{
+		Time:            start,
+		Provider:        llmConfig.Provider,
+		Model:           llmConfig.Model,
+		DiffFingerprint: utils.DiffFingerprint(diff),
+		SystemPrompt:    kommitSystemPrompt,
+		Prompt:          prompt,
+		Response:        result.Message,
+		Exchanges:       usage.recorded(),
+		Cost:            float64(result.Cost),
+	}
+	if len(record.Exchanges) > 0 {
+		record.RawResponse = record.Exchanges[0].Response
+	}
+	record.PromptTokens, record.CompletionTokens = usage.totals()
+	if genErr != nil {
+		record.Error = genErr.Error()
+	}
+
+	if _, err := utils.WriteAuditRecord(config, record); err != nil {
+		return fmt.Errorf("audit record not written: %w", err)
+	}
+	return nil
+}
+
+// completeCommitMessage asks the model for a commit message for the prepared
+// prompt and post-processes the answer.
+func completeCommitMessage(ctx context.Context, config *utils.Config, diff, prompt string) (ChatResult[string], error) {
+	if config.Commit.MinConfidence > 0 {
+		// Confidence is scored from the log probabilities
+		withLogprobs := *config
+		withLogprobs.LLM.IncludeLogprobs = true
+		config = &withLogprobs
+	}
+
+	var result ChatResult[string]
+	var err error
+	if config.Commit.Explain {
+		result, err = chatExplained(ctx, config, prompt)
+	} else {
+		result, err = chatForCommitType(ctx, config, prompt)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if config.Commit.ForcedType != "" {
+		result.Message = withType(result.Message, config.Commit.ForcedType)
+	}
+
+	scope, authoritative := derivedScope(config.Commit, utils.ParseDiff(diff))
+	if scope != "" && authoritative {
+		result.Message = withScope(result.Message, scope)
+	} else if config.Commit.RequireScope {
+		result, err = ensureScope(ctx, config, prompt, result)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if config.Commit.SingleWordScope {
+		result.Message = withSingleWordScope(result.Message)
+	}
+
+	result.Message = formatCommitMessage(config.Commit, result.Message)
+	if err := validateCommitMessage(config.Commit, result.Message); err != nil {
+		return result, err
+	}
+	if config.Commit.ProofreadPass {
+		result = proofread(ctx, config, result)
+	}
+	result.Warnings = append(result.Warnings, modelWarnings(config.LLM)...)
+	result.Warnings = append(result.Warnings, discouragedTypeWarnings(config.Commit, result.Message)...)
+	result.Warnings = append(result.Warnings, imperativeWarnings(config.Commit, result.Message)...)
+	if config.Commit.MinConfidence > 0 {
+		result = withAlternatives(ctx, config, prompt, result)
+	}
+
+	var sections []string
+	files := utils.ParseDiff(diff)
+	if section := dependencySection(files, config.Commit.BulletStyle); section != "" && utils.IsDependencyOnly(files) {
+		sections = append(sections, section)
+	}
+	if config.Commit.ListFilesInBody && len(files) > 0 {
+		sections = append(sections, fileListSection(files, config.Commit.BulletStyle))
+	}
+	if refs := utils.ExtractTicketRefs(diff, config.Commit.TicketPatterns); config.Commit.TicketRefs && len(refs) > 0 {
+		sections = append(sections, "Refs: "+strings.Join(refs, ", "))
+	}
+	if config.Commit.MaxTotalLength > 0 {
+		// Leave room for the sections, which are appended verbatim
+		limit := config.Commit.MaxTotalLength
+		for _, section := range sections {
+			limit -= len(section) + 2
+		}
+		result = ensureMaxLength(ctx, config, prompt, result, limit)
+		for i, alternative := range result.Alternatives {
+			result.Alternatives[i] = truncateMessage(alternative, limit)
+		}
+	}
+	for _, section := range sections {
+		result.Message = appendSection(result.Message, section)
+		for i, alternative := range result.Alternatives {
+			result.Alternatives[i] = appendSection(alternative, section)
+		}
+	}
+
+	result.Message = renderFinal(config.Commit, result.Message)
+	for i, alternative := range result.Alternatives {
+		result.Alternatives[i] = renderFinal(config.Commit, alternative)
+	}
+	return result, nil
+}
+
+// renderFinal renders message for the configured profile and caps its body,
+// then holds what actually gets committed to commit.maxTotalLength, since
+// rendering can add indentation and blank lines.
+func renderFinal(commit utils.CommitConfig, message string) string {
+	message = capBodyLines(commit, renderMessage(message, commit.RenderProfile))
+	if commit.MaxTotalLength > 0 {
+		message = truncateMessage(message, commit.MaxTotalLength)
+	}
+	return message
+}
+
+// prepareDiff runs the checks and filters every diff goes through before it
+// is sent to the model: the conflict-marker and secret-scan gates, then
+// commit.recentFileLimit, ignored files and commit.maxLineLength.
+func prepareDiff(config *utils.Config, diff string) (string, error) {
+	if !config.Commit.AllowConflictMarkers {
+		if files := utils.FilesWithConflictMarkers(utils.ParseDiff(diff)); len(files) > 0 {
+			return "", ConflictMarkersError{Files: files}
+		}
+	}
+
+	// Never send the diff anywhere if the secret scanner objects
+	if config.Privacy.SecretScanCommand != "" {
+		findings, err := utils.ScanForSecrets(config.Privacy.SecretScanCommand, diff)
+		if err != nil {
+			return "", err
+		}
+		if len(findings) > 0 {
+			return "", SecretsDetectedError{Findings: findings}
+		}
+	}
+
+	if config.Commit.RecentFileLimit > 0 {
+		trimmed, err := utils.TrimToRecentFiles(diff, config.Commit.RecentFileLimit)
+		if err != nil {
+			return "", err
+		}
+		diff = trimmed
+	}
+	ignorePatterns, err := utils.IgnorePatterns(config)
+	if err != nil {
+		return "", err
+	}
+	diff = utils.FilterIgnoredFiles(diff, ignorePatterns)
+	return utils.TruncateLongLines(diff, config.Commit.MaxLineLength), nil
+}
+
+// ensureMaxLength asks the model once for a shorter message when the result
+// exceeds limit, and truncates the body as a last resort.
+func ensureMaxLength(ctx context.Context, config *utils.Config, prompt string, result ChatResult[string], limit int) ChatResult[string] {
+	if len(result.Message) <= limit {
+		return result
+	}
+
+	retry, err := chatTurns(ctx, config.LLM, []ChatTurn{
+		{Role: RoleUser, Content: prompt},
+		{Role: RoleAssistant, Content: result.Message},
+		{Role: RoleUser, Content: fmt.Sprintf(tooLongPrompt, len(result.Message), limit)},
+	})
+	retry.Cost += result.Cost
+	if err == nil && strings.TrimSpace(retry.Message) != "" {
+		retry.Message = formatCommitMessage(config.Commit, retry.Message)
+		if validateCommitMessage(config.Commit, retry.Message) == nil {
+			result.Message = retry.Message
+		}
+	}
+	result.Cost = retry.Cost
+	result.Message = truncateMessage(result.Message, limit)
+	return result
+}
+
+// commitPrompt builds the user prompt asking for a commit message for diff,
+// using commit.promptTemplate when one is configured.
+func commitPrompt(config *utils.Config, diff, userContext string) (string, error) {
+	if config.Commit.PromptTemplate != "" {
+		return renderPromptTemplate(config.Commit.PromptTemplate, newPromptData(config, diff, userContext))
+	}
+
 	prompt := kommitBaseUserPrompt
+	if len(config.Commit.TypeDescriptions) > 0 {
+		// Extend the built-in type guidelines with the user's definitions
+		prompt = promptMain + promptGeneralRules + promptCommitTypeGuidelines +
+			typeDescriptionsPrompt(config.Commit.TypeDescriptions) + promptScopeRules + promptMessageFormatting
+	}
 
 	// user context
 	if userContext != "" {
@@ -198,21 +886,580 @@ func GenerateCommitMessage(config *utils.Config, diff, userContext string) (Chat
 	// context: commit types
 	prompt += "\n## Context:\n"
 	prompt += "- **Allowed commit types**:\n"
-	prompt += wrapInCSVCodeBlock(config.Commit.Types)
+	prompt += wrapInCSVCodeBlock(config.Commit.AllowedTypes())
+	if len(config.Commit.DeniedTypes) > 0 {
+		prompt += "- **Never use these commit types, even if they seem to fit**:\n"
+		prompt += wrapInCSVCodeBlock(config.Commit.DeniedTypes)
+	}
+	if len(config.Commit.DiscouragedTypes) > 0 {
+		prompt += "- **Avoid these commit types and only use them as a last resort, when no other type fits**:\n"
+		prompt += wrapInCSVCodeBlock(config.Commit.DiscouragedTypes)
+	}
+	if config.Commit.ForcedType != "" {
+		prompt += fmt.Sprintf("  - **Note:** The commit type has already been decided. Always use `%s`, "+
+			"and only choose the scope, subject and body.\n", config.Commit.ForcedType)
+	}
 
 	// context: commit scopes
 	prompt += "- **Allowed scopes _(only if changes are limited to a single scope)_:\n"
 	prompt += wrapInCSVCodeBlock(config.Commit.Scopes)
-	prompt += "  - **Note:** If the changes span multiple scopes, do not use a scope in the commit message.\n"
+	if config.Commit.RequireScope {
+		prompt += "  - **Note:** A scope is **required**. Always use one of the allowed scopes, choosing the closest one " +
+			"even if the changes span multiple scopes. This overrides the scope rules above.\n"
+	} else {
+		prompt += "  - **Note:** If the changes span multiple scopes, do not use a scope in the commit message.\n"
+	}
+	if config.Commit.SingleWordScope {
+		prompt += "  - **Note:** A scope must be a **single word**, without hyphens, slashes or spaces.\n"
+	}
+	files := utils.ParseDiff(diff)
+	if scope, _ := derivedScope(config.Commit, files); scope != "" {
+		prompt += fmt.Sprintf("  - **Note:** All changed files belong to the `%s` scope. Use it as the scope.\n", scope)
+	}
+
+	prompt += glossaryPrompt(config.Commit.Glossary)
+
+	// context: renamed files
+	prompt += renamePrompt(files)
+	if config.Commit.DetectFormatOnly && utils.IsFormatOnly(files) {
+		prompt += formatOnlyPrompt(config.Commit.AllowedTypes())
+	}
+	if utils.IsDependencyOnly(files) {
+		prompt += dependencyPrompt(config.Commit, files)
+	}
+
+	// context: formatting
+	prompt += formattingPrompt(config.Commit)
+	prompt += examplesPrompt(config.Commit)
+	prompt += historyPrompt(config.Commit)
 
 	// diff
-	prompt += "\n## Git Diff:\n"
+	prompt += diffPrompt(diff)
+
+	return prompt, nil
+}
+
+// chatForCommitType generates a message and, when the inferred type has its
+// own temperature configured, generates it again with that temperature.
+func chatForCommitType(ctx context.Context, config *utils.Config, prompt string) (ChatResult[string], error) {
+	turns := primedTurns(config.Commit, prompt)
+	result, err := chatNonEmptyTurns(ctx, config.LLM, turns)
+	if err != nil || len(config.Commit.TemperatureByType) == 0 {
+		return result, err
+	}
+
+	// Re-generate with the temperature configured for the inferred type
+	subject, _ := utils.SplitCommitMessage(result.Message)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok {
+		return result, nil
+	}
+	typeTemperature, ok := config.Commit.TemperatureByType[header.Type]
+	if !ok || typeTemperature == effectiveTemperature(config.LLM) {
+		return result, nil
+	}
+
+	llmConfig := config.LLM
+	llmConfig.Temperature = &typeTemperature
+	retry, err := chatNonEmptyTurns(ctx, llmConfig, turns)
+	retry.Cost += result.Cost
+	return retry, err
+}
+
+// primedTurns asks for a commit message for prompt. With commit.primeResponse
+// and a forced type, the reply is primed with the type, and the opening
+// parenthesis when a scope is required, so the model can only continue in
+// the Conventional Commits format.
+func primedTurns(commit utils.CommitConfig, prompt string) []ChatTurn {
+	turns := []ChatTurn{{Role: RoleUser, Content: prompt}}
+	if !commit.PrimeResponse || commit.ForcedType == "" {
+		return turns
+	}
+	prime := commit.ForcedType
+	if commit.RequireScope {
+		prime += "("
+	}
+	return append(turns, ChatTurn{Role: RoleAssistant, Content: prime})
+}
+
+// ensureScope re-prompts once when the generated message has no scope.
+func ensureScope(ctx context.Context, config *utils.Config, prompt string, result ChatResult[string]) (ChatResult[string], error) {
+	if hasScope(result.Message) {
+		return result, nil
+	}
+
+	retry, err := chatTurns(ctx, config.LLM, []ChatTurn{
+		{Role: RoleUser, Content: prompt},
+		{Role: RoleAssistant, Content: result.Message},
+		{Role: RoleUser, Content: fmt.Sprintf(missingScopePrompt, strings.Join(config.Commit.Scopes, ", "))},
+	})
+	retry.Cost += result.Cost
+	if err != nil {
+		return retry, err
+	}
+	if !hasScope(retry.Message) {
+		return retry, MissingScopeError{}
+	}
+	return retry, nil
+}
+
+// derivedScope returns the scope shared by every changed file according to
+// commit.pathScopeRules, commit.depsScope for dependency-only diffs, or else
+// by directory name when commit.deriveScopeFromPath is enabled. The second return value reports
+// whether the scope should override the model's choice.
+func derivedScope(commit utils.CommitConfig, files []utils.FileDiff) (string, bool) {
+	if len(files) == 0 {
+		return "", false
+	}
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path()
+	}
+
+	if scope := utils.ScopeFromRules(paths, commit.PathScopeRules); scope != "" {
+		return scope, true
+	}
+	if utils.IsDependencyOnly(files) {
+		if commit.DepsScope != "" {
+			return commit.DepsScope, true
+		}
+		return defaultDepsScope, true
+	}
+	if commit.DeriveScopeFromPath == "" {
+		return "", false
+	}
+	authoritative := commit.DeriveScopeFromPath == utils.ScopeDerivationAuthoritative
+	if scope := goPackageScope(files, commit.Scopes); scope != "" {
+		return scope, authoritative
+	}
+	return utils.ScopeFromPaths(paths, commit.Scopes), authoritative
+}
+
+// goPackageScope maps the dominant Go package of the files to one of the
+// allowed scopes, or uses it as is when no scopes are configured.
+func goPackageScope(files []utils.FileDiff, allowedScopes []string) string {
+	var diff strings.Builder
+	for _, f := range files {
+		diff.WriteString(f.String())
+	}
+	pkg := utils.ScopeFromGoPackages(diff.String())
+	if pkg == "" || len(allowedScopes) == 0 {
+		return pkg
+	}
+	for _, scope := range allowedScopes {
+		if strings.EqualFold(scope, pkg) {
+			return scope
+		}
+	}
+	return ""
+}
+
+// withScope replaces the scope in the message's subject, leaving messages
+// that don't follow the Conventional Commits format untouched.
+func withScope(message, scope string) string {
+	subject, rest, found := strings.Cut(message, "\n")
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok {
+		return message
+	}
+	header.Scope = scope
+	if !found {
+		return header.String()
+	}
+	return header.String() + "\n" + rest
+}
+
+// Words that never make a scope on their own
+var scopeStopWords = []string{"a", "an", "and", "the", "of", "or", "for", "to", "in", "on", "with"}
+
+var scopeWordSeparatorRegex = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// withSingleWordScope collapses a multi-word scope such as `user-auth` or
+// `api, ui` to its first significant word, and drops the scope when it has
+// none.
+func withSingleWordScope(message string) string {
+	subject, _ := utils.SplitCommitMessage(message)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok || header.Scope == "" {
+		return message
+	}
+
+	scope := ""
+	for _, word := range scopeWordSeparatorRegex.Split(header.Scope, -1) {
+		if word != "" && !slices.Contains(scopeStopWords, strings.ToLower(word)) {
+			scope = word
+			break
+		}
+	}
+	if scope == header.Scope {
+		return message
+	}
+	return withScope(message, scope)
+}
+
+// withType replaces the type in the message's subject, leaving messages that
+// don't follow the Conventional Commits format untouched.
+func withType(message, commitType string) string {
+	subject, rest, found := strings.Cut(message, "\n")
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok {
+		return message
+	}
+	header.Type = commitType
+	if !found {
+		return header.String()
+	}
+	return header.String() + "\n" + rest
+}
+
+func hasScope(message string) bool {
+	subject, _ := utils.SplitCommitMessage(message)
+	header, ok := utils.ParseCommitHeader(subject)
+	return ok && strings.TrimSpace(header.Scope) != ""
+}
+
+// chatNonEmpty retries once when the model answers with a blank message, and
+// returns an EmptyMessageError if the retry is blank too.
+func chatNonEmpty(ctx context.Context, llmConfig utils.LLMConfig, prompt string) (ChatResult[string], error) {
+	return chatNonEmptyTurns(ctx, llmConfig, []ChatTurn{{Role: RoleUser, Content: prompt}})
+}
+
+func chatNonEmptyTurns(ctx context.Context, llmConfig utils.LLMConfig, turns []ChatTurn) (ChatResult[string], error) {
+	var cost models.Cost
+	for range 2 {
+		result, err := chatTurns(ctx, llmConfig, turns)
+		result.Cost += cost
+		if err != nil || strings.TrimSpace(strings.TrimPrefix(result.Message, primeOf(turns))) != "" {
+			return result, err
+		}
+		cost = result.Cost
+	}
+	return ChatResult[string]{Cost: cost}, EmptyMessageError{}
+}
+
+func discouragedTypeWarnings(commit utils.CommitConfig, message string) []string {
+	subject, _ := utils.SplitCommitMessage(message)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok || !slices.Contains(commit.DiscouragedTypes, header.Type) || header.Type == commit.ForcedType {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s is a discouraged commit type, consider whether another type fits", header.Type)}
+}
+
+func modelWarnings(llmConfig utils.LLMConfig) []string {
+	if llmConfig.SuppressModelWarnings || !models.IsLowQualityModel(llmConfig.Model) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s tends to write poor commit messages, consider upgrading to %s", llmConfig.Model, models.OpenAIModelGPT4oMini)}
+}
+
+func effectiveTemperature(llmConfig utils.LLMConfig) float64 {
+	if llmConfig.Temperature != nil {
+		return *llmConfig.Temperature
+	}
+	return temperature
+}
+
+func revertMessage(reverted *utils.RevertedCommit) string {
+	return fmt.Sprintf("revert: %s\n\nThis reverts commit %s.", reverted.Subject, reverted.Hash)
+}
+
+const defaultDepsScope = "deps"
+
+// dependencyPrompt asks for a `build` commit, or `chore` when build isn't
+// allowed, for diffs that only update dependencies. The updated versions are
+// appended to the body by dependencySection.
+func dependencyPrompt(commit utils.CommitConfig, files []utils.FileDiff) string {
+	prompt := "\n## Dependency Updates:\n"
+	prompt += "- **Note:** These changes only update dependencies."
+	for _, t := range []string{"build", "chore"} {
+		if slices.Contains(commit.AllowedTypes(), t) {
+			prompt += fmt.Sprintf(" Use the `%s` commit type.", t)
+			break
+		}
+	}
+	if len(utils.DependencyUpdates(files)) > 0 {
+		prompt += " The updated versions are listed in the body automatically, so only write the subject."
+	}
+	return prompt + "\n"
+}
+
+// dependencySection lists the dependency updates of the diff, or returns an
+// empty string when no versions could be read from it.
+func dependencySection(files []utils.FileDiff, style utils.BulletStyle) string {
+	marker := "- "
+	if style == utils.BulletStyleAsterisk {
+		marker = "* "
+	}
+
+	var lines []string
+	for _, update := range utils.DependencyUpdates(files) {
+		lines = append(lines, marker+update.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+const defaultExampleTokenBudget = 2000
+
+// examplesPrompt renders commit.examples as pairs of diff and message. The
+// oldest examples are dropped until the rest fit in the token budget.
+func examplesPrompt(commit utils.CommitConfig) string {
+	budget := commit.ExampleTokenBudget
+	if budget == 0 {
+		budget = defaultExampleTokenBudget
+	}
+
+	var pairs []string
+	for i := len(commit.Examples) - 1; i >= 0; i-- {
+		example := commit.Examples[i]
+		pair := "### Diff:\n```diff\n" + strings.TrimRight(example.Diff, "\n") + "\n```\n" +
+			"### Commit message:\n```text\n" + strings.TrimSpace(example.Message) + "\n```\n"
+		if budget -= estimateTokens(pair); budget < 0 {
+			break
+		}
+		pairs = append([]string{pair}, pairs...)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	prompt := "\n## Examples:\n"
+	prompt += "**Match the style of these commit messages written for earlier diffs**:\n"
+	return prompt + strings.Join(pairs, "")
+}
+
+const (
+	defaultHistoryRecencyBias = 0.8
+	// historySearchDepth bounds how far back style examples are looked for
+	historySearchDepth = 500
+)
+
+// historyPrompt lists subjects from the repo's history for the model to
+// match, when commit.historyExamples is set.
+func historyPrompt(commit utils.CommitConfig) string {
+	if commit.HistoryExamples <= 0 {
+		return ""
+	}
+	history, err := utils.GetCommitHistory(historySearchDepth)
+	if err != nil {
+		return ""
+	}
+
+	bias := defaultHistoryRecencyBias
+	if commit.HistoryRecencyBias != nil {
+		bias = *commit.HistoryRecencyBias
+	}
+	selected := utils.SelectStyleExamples(history, commit.HistoryExamples, bias)
+	if len(selected) == 0 {
+		return ""
+	}
+
+	subjects := make([]string, len(selected))
+	for i, c := range selected {
+		subjects[i] = "- " + c.Subject
+	}
+	prompt := "\n## Commit History:\n"
+	prompt += "**Match the style of these subjects from the repository's history**:\n"
+	return prompt + strings.Join(subjects, "\n") + "\n"
+}
+
+// estimateTokens roughly approximates the token count of English text and
+// code at four bytes per token.
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+func intentPrompt(intent string) string {
+	if intent = strings.TrimSpace(intent); intent == "" {
+		return ""
+	}
+	prompt := "\n## Author's Intent:\n"
+	prompt += "- The author's stated intent is: " + intent + "\n"
+	prompt += "- Use it to guide how you describe the changes, but base the message on the diff. If the intent " +
+		"contradicts the diff, follow the diff and don't mention the intent.\n"
+	return prompt
+}
+
+func diffPrompt(diff string) string {
+	prompt := "\n## Git Diff:\n"
 	prompt += "**Based on the following diff**:\n"
 	prompt += "```diff\n"
 	prompt += diff + "\n"
 	prompt += "```\n"
+	return prompt
+}
+
+// RegenerateBody asks the model for a new body while keeping the given
+// subject verbatim. Only the body is formatted, and since a body is asked
+// for explicitly, commit.verbosity terse is ignored.
+func RegenerateBody(config *utils.Config, diff, subject string) (string, error) {
+	diff, err := prepareDiff(config, diff)
+	if err != nil {
+		return "", err
+	}
+	commit := config.Commit
+	if commit.Verbosity == utils.VerbosityTerse {
+		commit.Verbosity = ""
+	}
+
+	prompt := "Write only the **body** of a Conventional Commit message for the subject below, adhering to these rules:\n"
+	prompt += promptGeneralRules + promptMessageFormatting
+
+	prompt += "\n## Subject:\n"
+	prompt += "**The subject is fixed. Do not repeat or rewrite it**:\n"
+	prompt += "- " + subject + "\n"
+
+	prompt += renamePrompt(utils.ParseDiff(diff))
+	prompt += formattingPrompt(commit)
+	prompt += diffPrompt(diff)
+
+	result, err := chat(context.Background(), config.LLM, prompt)
+	utils.UpdateCost(float64(result.Cost))
+	if err != nil {
+		return "", err
+	}
+
+	body := strings.TrimSpace(result.Message)
+	// Drop the subject if the model echoed it back anyway
+	if first, rest, _ := strings.Cut(body, "\n"); strings.TrimSpace(first) == strings.TrimSpace(subject) {
+		body = strings.TrimSpace(rest)
+	}
+
+	if !commit.PreserveRawFormatting {
+		body = formatBody(commit, strings.TrimSpace(normalizeWhitespace(body)))
+	}
+	if body == "" {
+		return subject + "\n", nil
+	}
+	return subject + "\n\n" + body + "\n", nil
+}
+
+// AmendCommitMessage asks the model to extend an existing message with more
+// changes, as when amending a commit. The existing subject is kept unless the
+// scope changes, and its body bullets are kept alongside the new ones.
+func AmendCommitMessage(config *utils.Config, existingMessage, additionalDiff string) (string, error) {
+	additionalDiff, err := prepareDiff(config, additionalDiff)
+	if err != nil {
+		return "", err
+	}
+
+	prompt := "Update the Conventional Commit message below so that it also covers the **additional changes** in the diff, adhering to these rules:\n"
+	prompt += promptGeneralRules + promptMessageFormatting
+
+	prompt += "\n## Existing Commit Message:\n"
+	prompt += "**Keep the subject verbatim unless the additional changes fundamentally change its scope. " +
+		"Keep the existing body bullets and add bullets for the additional changes**:\n"
+	prompt += "```text\n" + strings.TrimSpace(existingMessage) + "\n```\n"
+
+	prompt += "\n## Context:\n"
+	prompt += "- **Allowed commit types**:\n"
+	prompt += wrapInCSVCodeBlock(config.Commit.AllowedTypes())
+	prompt += "- **Allowed scopes**:\n"
+	prompt += wrapInCSVCodeBlock(config.Commit.Scopes)
+	prompt += formattingPrompt(config.Commit)
+	prompt += diffPrompt(additionalDiff)
+
+	result, err := chatNonEmpty(context.Background(), config.LLM, prompt)
+	utils.UpdateCost(float64(result.Cost))
+	if err != nil {
+		return "", err
+	}
+
+	message := formatCommitMessage(config.Commit, result.Message)
+	// Restore the subject if the model reworded it without changing the scope
+	oldSubject, _ := utils.SplitCommitMessage(existingMessage)
+	newSubject, body := utils.SplitCommitMessage(message)
+	oldHeader, oldOK := utils.ParseCommitHeader(oldSubject)
+	newHeader, newOK := utils.ParseCommitHeader(newSubject)
+	if oldOK && newOK && oldHeader.Scope == newHeader.Scope && oldSubject != newSubject {
+		message = formatCommitMessage(config.Commit, oldSubject+"\n\n"+body)
+	}
+
+	if err := validateCommitMessage(config.Commit, message); err != nil {
+		return "", err
+	}
+	return message, nil
+}
+
+func renamePrompt(files []utils.FileDiff) string {
+	renames := utils.Renames(files)
+	if len(renames) == 0 {
+		return ""
+	}
+
+	prompt := "\n## Renamed Files:\n"
+	for _, rename := range renames {
+		prompt += "- renamed " + rename + "\n"
+	}
+	if utils.IsRenameOnly(files) {
+		prompt += "- **Note:** The changes only move or rename files. Describe the move explicitly " +
+			"(e.g. \"move X to Y\") and use `refactor` or `chore` as the commit type.\n"
+	}
+	return prompt
+}
+
+func partialFilesPrompt(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
 
-	return chat(config.LLM.Model, prompt)
+	prompt := "\n## Partially Staged Files:\n"
+	for _, path := range paths {
+		prompt += "- `" + path + "`\n"
+	}
+	prompt += "- **Note:** Only some of the changes in these files are being committed. Describe only what the " +
+		"diff shows and do not claim the whole file or module was rewritten.\n"
+	return prompt
+}
+
+// typeDescriptionsPrompt renders commit.typeDescriptions as more entries of
+// the commit type guidelines, sorted by type.
+func typeDescriptionsPrompt(descriptions map[string]string) string {
+	types := make([]string, 0, len(descriptions))
+	for commitType := range descriptions {
+		types = append(types, commitType)
+	}
+	sort.Strings(types)
+
+	prompt := ""
+	for _, commitType := range types {
+		prompt += fmt.Sprintf("  - `%s`: %s\n", commitType, strings.TrimSpace(descriptions[commitType]))
+	}
+	return prompt
+}
+
+// glossaryPrompt renders commit.glossary sorted by term, or nothing when it's
+// empty.
+func glossaryPrompt(glossary map[string]string) string {
+	if len(glossary) == 0 {
+		return ""
+	}
+	terms := make([]string, 0, len(glossary))
+	for term := range glossary {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+
+	prompt := "\n## Glossary:\n"
+	prompt += "**Use these project terms consistently (they are case-insensitive)**:\n"
+	for _, term := range terms {
+		prompt += fmt.Sprintf("- `%s`: %s\n", term, strings.TrimSpace(glossary[term]))
+	}
+	return prompt
+}
+
+// formatOnlyPrompt steers whitespace-only diffs toward `style`, or `chore`
+// when `style` isn't allowed.
+func formatOnlyPrompt(types []string) string {
+	commitType := "style"
+	if !slices.Contains(types, commitType) {
+		commitType = "chore"
+	}
+
+	prompt := "\n## Formatting-Only Changes:\n"
+	prompt += fmt.Sprintf("- **Note:** These changes only affect whitespace or formatting, not behavior. "+
+		"Use the `%s` commit type.\n", commitType)
+	return prompt
 }
 
 type Scopes struct {
@@ -221,7 +1468,121 @@ type Scopes struct {
 
 var StructuredScopesSchema = GenerateSchema[Scopes]()
 
-func GenerateScopesFromFilenames(model string, filenames, existingScopes []string) (ChatResult[Scopes], error) {
+func GenerateScopesFromFilenames(model string, filenames, existingScopes []string, maxScopes int) (ChatResult[Scopes], error) {
+	return GenerateScopesFromFilenamesWithProgress(context.Background(), utils.LLMConfig{Model: model}, filenames, existingScopes, maxScopes, nil)
+}
+
+// GenerateScopesFromFilenamesWithProgress splits large file lists into
+// batches that are sent concurrently, calling progress (if non-nil) with the
+// number of completed batches after each one finishes. Calls to progress are
+// serialized and done increases by one each time up to total. When maxScopes
+// is positive, only that many scopes are kept, preferring the ones most files
+// map to.
+func GenerateScopesFromFilenamesWithProgress(ctx context.Context, llmConfig utils.LLMConfig, filenames, existingScopes []string, maxScopes int, progress func(done, total int)) (ChatResult[Scopes], error) {
+	result, err := generateScopesBatched(ctx, llmConfig, filenames, existingScopes, progress, nil)
+	if err == nil && maxScopes > 0 {
+		result.Message.Scopes = mostSupportedScopes(result.Message.Scopes, filenames, maxScopes)
+	}
+	return result, err
+}
+
+// GenerateScopesStream works like GenerateScopesFromFilenames but sends each
+// newly discovered scope to out as soon as its batch completes, so a UI can
+// show them as they come in. Scopes in existingScopes and duplicates are
+// never sent. out is closed once all batches are done. Errors are only
+// returned, and on success the scopes sent add up to the returned Scopes.
+func GenerateScopesStream(ctx context.Context, model string, filenames, existingScopes []string, out chan<- string) (ChatResult[Scopes], error) {
+	defer close(out)
+
+	seen := make(map[string]bool)
+	for _, scope := range existingScopes {
+		seen[strings.ToLower(strings.TrimSpace(scope))] = true
+	}
+	emit := func(scopes []string) {
+		for _, scope := range scopes {
+			scope = strings.TrimSpace(scope)
+			key := strings.ToLower(scope)
+			if scope == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			select {
+			case out <- scope:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	return generateScopesBatched(ctx, utils.LLMConfig{Model: model}, filenames, existingScopes, nil, emit)
+}
+
+// generateScopesBatched sends the batches concurrently. progress and
+// onScopes, when non-nil, are called after each batch with calls serialized;
+// onScopes receives the scopes of that batch as suggested by the model.
+func generateScopesBatched(ctx context.Context, llmConfig utils.LLMConfig, filenames, existingScopes []string, progress func(done, total int), onScopes func([]string)) (ChatResult[Scopes], error) {
+	var batches [][]string
+	for start := 0; start < len(filenames); start += scopeBatchSize {
+		batches = append(batches, filenames[start:min(start+scopeBatchSize, len(filenames))])
+	}
+	if len(batches) == 0 {
+		batches = [][]string{nil}
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		done   int
+		merged ChatResult[Scopes]
+		errs   []error
+	)
+	jobs := make(chan []string)
+	for range min(maxConcurrentGenerations, len(batches)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range jobs {
+				result, err := generateScopes(ctx, llmConfig, batch, existingScopes)
+
+				mu.Lock()
+				merged.Cost += result.Cost
+				merged.Message.Scopes = append(merged.Message.Scopes, result.Message.Scopes...)
+				if err != nil {
+					errs = append(errs, err)
+				} else if onScopes != nil {
+					onScopes(result.Message.Scopes)
+				}
+				done++
+				if progress != nil {
+					progress(done, len(batches))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, batch := range batches {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case jobs <- batch:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return ChatResult[Scopes]{Cost: merged.Cost}, err
+	}
+
+	merged.Message.Scopes = normalizeScopes(merged.Message.Scopes, existingScopes)
+	return merged, nil
+}
+
+func generateScopes(ctx context.Context, llmConfig utils.LLMConfig, filenames, existingScopes []string) (ChatResult[Scopes], error) {
 	prompt := "Based on the following project structure, guess module or package names used in this project:\n"
 	prompt += strings.Join(filenames, "\n")
 
@@ -240,10 +1601,63 @@ func GenerateScopesFromFilenames(model string, filenames, existingScopes []strin
 		Strict:      openai.Bool(true),
 	}
 
-	result, err := chatStructured[Scopes](model, prompt, schemaParam)
-	if err != nil {
-		return ChatResult[Scopes]{}, err
+	return chatStructured[Scopes](ctx, llmConfig, prompt, schemaParam)
+}
+
+// mostSupportedScopes keeps the limit scopes that name a directory or file
+// (ignoring extensions) in the most filenames. Ties keep the earlier scope,
+// and the result stays in the order of scopes.
+func mostSupportedScopes(scopes, filenames []string, limit int) []string {
+	if len(scopes) <= limit {
+		return scopes
 	}
 
-	return result, nil
+	support := make(map[string]int, len(scopes))
+	for _, filename := range filenames {
+		names := make(map[string]bool)
+		for _, part := range strings.Split(filepath.ToSlash(filename), "/") {
+			names[strings.ToLower(part)] = true
+			names[strings.ToLower(strings.TrimSuffix(part, filepath.Ext(part)))] = true
+		}
+		for _, scope := range scopes {
+			if names[strings.ToLower(scope)] {
+				support[scope]++
+			}
+		}
+	}
+
+	ranked := slices.Clone(scopes)
+	slices.SortStableFunc(ranked, func(a, b string) int { return support[b] - support[a] })
+	kept := ranked[:limit]
+	return slices.DeleteFunc(slices.Clone(scopes), func(scope string) bool { return !slices.Contains(kept, scope) })
+}
+
+// normalizeScopes trims and de-duplicates the scopes suggested by the model,
+// drops the ones already present in existingScopes, and sorts the rest
+// case-insensitively so the output is stable across runs.
+func normalizeScopes(scopes, existingScopes []string) []string {
+	seen := make(map[string]bool)
+	for _, scope := range existingScopes {
+		seen[strings.ToLower(strings.TrimSpace(scope))] = true
+	}
+
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		key := strings.ToLower(scope)
+		if scope == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, scope)
+	}
+
+	sort.Slice(normalized, func(i, j int) bool {
+		a, b := strings.ToLower(normalized[i]), strings.ToLower(normalized[j])
+		if a == b {
+			return normalized[i] < normalized[j]
+		}
+		return a < b
+	})
+	return normalized
 }
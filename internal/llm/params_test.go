@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestUserIDParam(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID string
+		want   any
+	}{
+		{"configured", "dev@example.com", hashUserID("dev@example.com")},
+		{"unset", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			llmConfig := server.LLMConfig()
+			llmConfig.UserID = tt.userID
+
+			if _, err := chat(context.Background(), llmConfig, "prompt"); err != nil {
+				t.Fatal(err)
+			}
+			user, present := server.Requests()[0].Raw["user"]
+			if tt.want == nil {
+				if present {
+					t.Errorf("user = %v, want it omitted", user)
+				}
+				return
+			}
+			if user != tt.want {
+				t.Errorf("user = %v, want %v", user, tt.want)
+			}
+			if user == tt.userID {
+				t.Error("user identifier was sent unhashed")
+			}
+		})
+	}
+}
+
+func TestIncludeLogprobs(t *testing.T) {
+	tests := []struct {
+		name            string
+		include         bool
+		topLogprobs     int
+		wantTopLogprobs any
+	}{
+		{"disabled", false, 0, nil},
+		{"default top logprobs", true, 0, float64(defaultTopLogprobs)},
+		{"configured top logprobs", true, 2, float64(2)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				body := completionBody("feat: add x")
+				body["choices"].([]map[string]any)[0]["logprobs"] = map[string]any{
+					"content": []map[string]any{
+						{"token": "feat", "logprob": -0.01, "bytes": []int{102, 101, 97, 116}, "top_logprobs": []map[string]any{
+							{"token": "feat", "logprob": -0.01, "bytes": []int{102, 101, 97, 116}},
+							{"token": "fix", "logprob": -4.2, "bytes": []int{102, 105, 120}},
+						}},
+					},
+				}
+				writeJSON(w, http.StatusOK, body)
+			})
+			llmConfig := server.LLMConfig()
+			llmConfig.IncludeLogprobs = tt.include
+			llmConfig.TopLogprobs = tt.topLogprobs
+
+			result, err := chat(context.Background(), llmConfig, "prompt")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw := server.Requests()[0].Raw
+			if got, present := raw["logprobs"]; present != tt.include || (present && got != true) {
+				t.Errorf("logprobs = %v (present: %v), want present: %v", got, present, tt.include)
+			}
+			if got := raw["top_logprobs"]; got != tt.wantTopLogprobs {
+				t.Errorf("top_logprobs = %v, want %v", got, tt.wantTopLogprobs)
+			}
+
+			if len(result.Logprobs) != 1 {
+				t.Fatalf("Logprobs = %+v, want one token", result.Logprobs)
+			}
+			lp := result.Logprobs[0]
+			if lp.Token != "feat" || lp.Logprob != -0.01 || len(lp.TopLogprobs) != 2 || lp.TopLogprobs[1].Token != "fix" {
+				t.Errorf("Logprobs[0] = %+v, want the mocked token", lp)
+			}
+		})
+	}
+}
+
+func TestOmitParams(t *testing.T) {
+	all := []string{"temperature", "top_p", "presence_penalty", "frequency_penalty", "user", "logprobs", "top_logprobs"}
+
+	tests := []struct {
+		name string
+		omit []string
+	}{
+		{"none", nil},
+		{"penalties", []string{"frequency_penalty", "presence_penalty"}},
+		{"everything", all},
+		{"unknown names ignored", []string{"top_p", "seed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			llmConfig := server.LLMConfig()
+			llmConfig.UserID = "dev@example.com"
+			llmConfig.IncludeLogprobs = true
+			llmConfig.OmitParams = tt.omit
+
+			if _, err := chat(context.Background(), llmConfig, "prompt"); err != nil {
+				t.Fatal(err)
+			}
+			raw := server.Requests()[0].Raw
+			for _, name := range all {
+				_, present := raw[name]
+				if omitted := slices.Contains(tt.omit, name); present == omitted {
+					t.Errorf("%s present: %v, want %v", name, present, !omitted)
+				}
+			}
+			if raw["model"] == nil || raw["messages"] == nil {
+				t.Errorf("request lost its required fields: %v", raw)
+			}
+		})
+	}
+}
+
+func TestOmitResponseFormat(t *testing.T) {
+	tests := []struct {
+		name       string
+		omit       []string
+		wantFormat bool
+	}{
+		{"kept", nil, true},
+		{"omitted", []string{"response_format"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, Scopes{Scopes: []string{"api"}})
+			})
+			llmConfig := server.LLMConfig()
+			llmConfig.OmitParams = tt.omit
+
+			if _, err := chatStructured[Scopes](context.Background(), llmConfig, "prompt", testScopesSchema); err != nil {
+				t.Fatal(err)
+			}
+			request := server.Requests()[0]
+			if _, present := request.Raw["response_format"]; present != tt.wantFormat {
+				t.Errorf("response_format present: %v, want %v", present, tt.wantFormat)
+			}
+			if !tt.wantFormat && !strings.Contains(request.LastUser(), `"scopes"`) {
+				t.Error("the schema isn't in the prompt without a response format")
+			}
+		})
+	}
+}
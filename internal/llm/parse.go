@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// ParsedMessage is a commit message split into the parts a UI would render
+// differently, e.g. to highlight the type and scope.
+type ParsedMessage struct {
+	Type     string
+	Scope    string
+	Breaking bool
+	Subject  string
+	// Body is the full body, Bullets its top-level list items with indented
+	// continuation lines joined in
+	Body    string
+	Bullets []string
+}
+
+// ParseGeneratedMessage splits a generated or hand-written commit message into
+// its header fields and body bullets. It fails if the subject line doesn't
+// follow the Conventional Commits format.
+func ParseGeneratedMessage(msg string) (ParsedMessage, error) {
+	subject, body := utils.SplitCommitMessage(msg)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok {
+		return ParsedMessage{}, fmt.Errorf("not a conventional commit header: %q", subject)
+	}
+
+	parsed := ParsedMessage{
+		Type:     header.Type,
+		Scope:    header.Scope,
+		Breaking: header.Breaking,
+		Subject:  header.Description,
+		Body:     body,
+	}
+
+	inBullet := false
+	for _, line := range strings.Split(body, "\n") {
+		if matches := bulletRegex.FindStringSubmatch(line); matches != nil && matches[1] == "" {
+			parsed.Bullets = append(parsed.Bullets, strings.TrimSpace(matches[2]))
+			inBullet = true
+			continue
+		}
+		if inBullet && strings.TrimSpace(line) != "" && strings.TrimLeft(line, " \t") != line {
+			last := len(parsed.Bullets) - 1
+			parsed.Bullets[last] += " " + strings.TrimSpace(line)
+			continue
+		}
+		inBullet = false
+	}
+
+	return parsed, nil
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGeneratedMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     string
+		want    ParsedMessage
+		wantErr bool
+	}{
+		{
+			name: "without scope",
+			msg:  "fix: handle nil config\n",
+			want: ParsedMessage{Type: "fix", Subject: "handle nil config"},
+		},
+		{
+			name: "with scope",
+			msg:  "feat(api): add orders endpoint",
+			want: ParsedMessage{Type: "feat", Scope: "api", Subject: "add orders endpoint"},
+		},
+		{
+			name: "breaking",
+			msg:  "refactor(auth)!: drop session cookies",
+			want: ParsedMessage{Type: "refactor", Scope: "auth", Breaking: true, Subject: "drop session cookies"},
+		},
+		{
+			name: "multi-bullet body",
+			msg: "feat(api): add orders endpoint\n\n- Add GET /orders\n- Page results by\n  created time\n" +
+				"  - nested detail\n\nRefs: SHOP-42\n",
+			want: ParsedMessage{
+				Type:    "feat",
+				Scope:   "api",
+				Subject: "add orders endpoint",
+				Body:    "- Add GET /orders\n- Page results by\n  created time\n  - nested detail\n\nRefs: SHOP-42",
+				Bullets: []string{"Add GET /orders", "Page results by created time - nested detail"},
+			},
+		},
+		{
+			name: "numbered and asterisk bullets",
+			msg:  "docs: rewrite the guide\n\n1. Explain setup\n* Explain usage",
+			want: ParsedMessage{
+				Type:    "docs",
+				Subject: "rewrite the guide",
+				Body:    "1. Explain setup\n* Explain usage",
+				Bullets: []string{"Explain setup", "Explain usage"},
+			},
+		},
+		{
+			name:    "not a conventional commit",
+			msg:     "Fixed the thing\n\n- It works now",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGeneratedMessage(tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGeneratedMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGeneratedMessage() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go/option"
+)
+
+// Latency breaks a round trip down into its connection phases. Phases that
+// didn't happen, e.g. DNS when connecting through a proxy by IP, are zero.
+type Latency struct {
+	DNS       time.Duration
+	Connect   time.Duration
+	TLS       time.Duration
+	FirstByte time.Duration
+	Total     time.Duration
+}
+
+// Ping issues a minimal request to the configured provider and returns the
+// round-trip time.
+func Ping(config *utils.Config) (time.Duration, error) {
+	latency, err := PingPhases(context.Background(), config)
+	return latency.Total, err
+}
+
+// PingPhases is like Ping but also reports how long each connection phase
+// took. For OpenAI it fetches the configured model, which also checks the API
+// key; for Bedrock it only checks that the regional endpoint answers.
+func PingPhases(ctx context.Context, config *utils.Config) (Latency, error) {
+	llmConfig, err := resolveLLMConfig(config.LLM)
+	if err != nil {
+		return Latency{}, err
+	}
+
+	var latency Latency
+	var dnsStart, connectStart, tlsStart time.Time
+	start := time.Now()
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { latency.DNS = time.Since(dnsStart) },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { latency.Connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { latency.TLS = time.Since(tlsStart) },
+		GotFirstResponseByte: func() { latency.FirstByte = time.Since(start) },
+	})
+
+	if llmConfig.Provider == utils.ProviderBedrock {
+		err = pingEndpoint(ctx, llmConfig)
+	} else {
+		err = pingOpenAI(ctx, llmConfig)
+	}
+	latency.Total = time.Since(start)
+	return latency, err
+}
+
+func pingOpenAI(ctx context.Context, llmConfig utils.LLMConfig) error {
+	client, err := newClient(ctx, llmConfig)
+	if err != nil {
+		return err
+	}
+	// Retries would hide the latency of the first attempt
+	if _, err := client.Models.Get(ctx, llmConfig.Model, option.WithMaxRetries(0)); err != nil {
+		return &OpenAIRequestError{Err: err}
+	}
+	return nil
+}
+
+func pingEndpoint(ctx context.Context, llmConfig utils.LLMConfig) error {
+	if llmConfig.BaseURL == "" {
+		provider, err := NewBedrockProvider(ctx, llmConfig)
+		if err != nil {
+			return err
+		}
+		llmConfig.Region = provider.Region
+		llmConfig, _ = resolveLLMConfig(llmConfig)
+	}
+
+	httpClient, err := newHTTPClient(llmConfig)
+	if err != nil {
+		return err
+	}
+	httpClient.Timeout = timeout
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, llmConfig.BaseURL+"ping", nil)
+	if err != nil {
+		return &BedrockRequestError{Err: err}
+	}
+	// Any HTTP response means the endpoint is reachable
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return &BedrockRequestError{Err: err}
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestPing(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"reachable", http.StatusOK, false},
+		{"rejected", http.StatusUnauthorized, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				time.Sleep(delay)
+				if tt.status != http.StatusOK {
+					writeError(w, tt.status, "invalid api key")
+					return
+				}
+				writeJSON(w, http.StatusOK, map[string]any{"id": "gpt-4o-mini", "object": "model", "created": 0, "owned_by": "openai"})
+			})
+			server.Server.Config.Handler = recordPath(server.Server.Config.Handler, &path)
+
+			latency, err := PingPhases(t.Context(), server.Config())
+			if tt.wantErr {
+				var reqErr *OpenAIRequestError
+				if !errors.As(err, &reqErr) {
+					t.Fatalf("PingPhases() error = %v, want an OpenAIRequestError", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if path != "/models/gpt-4o-mini" {
+				t.Errorf("path = %q, want /models/gpt-4o-mini", path)
+			}
+			if latency.Total < delay || latency.FirstByte < delay || latency.FirstByte > latency.Total {
+				t.Errorf("latency = %+v, want a first byte after %v within the total", latency, delay)
+			}
+			if latency.Connect <= 0 {
+				t.Errorf("connect latency = %v, want it measured", latency.Connect)
+			}
+			if len(server.Requests()) != 1 {
+				t.Errorf("sent %d requests, want 1 without retries", len(server.Requests()))
+			}
+		})
+	}
+}
+
+func TestPingMissingAPIKey(t *testing.T) {
+	server := newMockOpenAI(t, replyWith(""))
+	t.Setenv("KOMMIT_OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_API_KEY", "")
+	config := server.Config()
+
+	if _, err := Ping(config); !errors.Is(err, &APIKeyMissingError{}) {
+		t.Errorf("Ping() error = %v, want APIKeyMissingError", err)
+	}
+	if len(server.Requests()) != 0 {
+		t.Errorf("sent %d requests, want none", len(server.Requests()))
+	}
+}
+
+func recordPath(next http.Handler, path *string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path = r.URL.Path
+		next.ServeHTTP(w, r)
+	})
+}
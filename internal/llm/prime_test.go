@@ -0,0 +1,57 @@
+package llm
+
+import "testing"
+
+func TestPrimeResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		prime        bool
+		forced       string
+		requireScope bool
+		reply        string
+		want         string
+		wantPrime    string
+	}{
+		{"disabled", false, "fix", false, "fix: handle nil orders", "fix: handle nil orders\n", ""},
+		{"primed with the type", true, "fix", false, ": handle nil orders", "fix: handle nil orders\n", "fix"},
+		{"primed with the scope's parenthesis", true, "fix", true, "api): handle nil orders", "fix(api): handle nil orders\n", "fix("},
+		{"prefix repeated by the model", true, "fix", true, "fix(api): handle nil orders", "fix(api): handle nil orders\n", "fix("},
+		{"body kept", true, "fix", false, ": handle nil orders\n\n- Guard it", "fix: handle nil orders\n\n- Guard it\n", "fix"},
+		{"no forced type", true, "", false, "feat: add x", "feat: add x\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.PrimeResponse = tt.prime
+			config.Commit.ForcedType = tt.forced
+			config.Commit.RequireScope = tt.requireScope
+			config.Commit.Scopes = []string{"api"}
+
+			result, err := GenerateCommitMessage(config, testDiff("api/orders.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("sent %d requests, want 1", len(requests))
+			}
+			messages := requests[0].Messages
+			last := messages[len(messages)-1]
+			if tt.wantPrime == "" {
+				if last.Role != RoleUser {
+					t.Errorf("last message is from %q, want an unprimed request", last.Role)
+				}
+				return
+			}
+			if last.Role != RoleAssistant || last.Text() != tt.wantPrime {
+				t.Errorf("last message = %s %q, want the assistant prime %q", last.Role, last.Text(), tt.wantPrime)
+			}
+		})
+	}
+}
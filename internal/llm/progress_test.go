@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestScopeInferenceProgress(t *testing.T) {
+	filenames := make([]string, 2*scopeBatchSize+1)
+	for i := range filenames {
+		filenames[i] = fmt.Sprintf("pkg%d/file.go", i%7)
+	}
+	const total = 3
+
+	tests := []struct {
+		name      string
+		failBatch bool
+		wantErr   bool
+	}{
+		{"all batches succeed", false, false},
+		{"a batch fails", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				if tt.failBatch && n == 1 {
+					writeError(w, http.StatusBadRequest, "bad batch")
+					return
+				}
+				writeStructured(w, Scopes{Scopes: []string{fmt.Sprintf("scope%d", n)}})
+			})
+
+			var (
+				mu    sync.Mutex
+				calls [][2]int
+			)
+			progress := func(done, total int) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, [2]int{done, total})
+			}
+
+			_, err := GenerateScopesFromFilenamesWithProgress(context.Background(), server.LLMConfig(), filenames, nil, 0, progress)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			// Failed batches count as done too, so the bar always completes
+			want := [][2]int{{1, total}, {2, total}, {3, total}}
+			if !reflect.DeepEqual(calls, want) {
+				t.Errorf("progress calls = %v, want %v", calls, want)
+			}
+			if got := len(server.Requests()); got != total {
+				t.Errorf("sent %d requests, want %d", got, total)
+			}
+		})
+	}
+}
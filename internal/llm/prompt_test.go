@@ -0,0 +1,356 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const renameDiff = `diff --git a/pkg/old.go b/pkg/new.go
+similarity index 100%
+rename from pkg/old.go
+rename to pkg/new.go
+diff --git a/docs/a.md b/guide/a.md
+similarity index 100%
+rename from docs/a.md
+rename to guide/a.md
+`
+
+func TestCommitPromptRenames(t *testing.T) {
+	tests := []struct {
+		name         string
+		diff         string
+		wantRenames  bool
+		wantMoveNote bool
+	}{
+		{"rename only", renameDiff, true, true},
+		{"rename and modification", renameDiff + testDiff("main.go"), true, false},
+		{"no renames", testDiff("main.go"), false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{Types: testTypes}}
+			prompt, err := commitPrompt(config, tt.diff, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hasRenames := strings.Contains(prompt, "- renamed pkg/old.go → pkg/new.go\n") &&
+				strings.Contains(prompt, "- renamed docs/a.md → guide/a.md\n")
+			if hasRenames != tt.wantRenames {
+				t.Errorf("rename context present = %v, want %v", hasRenames, tt.wantRenames)
+			}
+			hasNote := strings.Contains(prompt, "use `refactor` or `chore` as the commit type")
+			if hasNote != tt.wantMoveNote {
+				t.Errorf("rename-only type note present = %v, want %v", hasNote, tt.wantMoveNote)
+			}
+		})
+	}
+}
+
+func TestPartiallyStagedNote(t *testing.T) {
+	const note = "## Partially Staged Files:\n- `auth/session.go`\n"
+	hunk := func(start int) string {
+		return fmt.Sprintf("@@ -%[1]d,3 +%[1]d,3 @@\n line\n-old\n+new\n line\n", start)
+	}
+	header := "diff --git a/auth/session.go b/auth/session.go\n--- a/auth/session.go\n+++ b/auth/session.go\n"
+	staged := header + hunk(2)
+
+	tests := []struct {
+		name     string
+		generate func(config *utils.Config) (ChatResult[string], error)
+		want     bool
+	}{
+		{"single hunk staged", func(config *utils.Config) (ChatResult[string], error) {
+			return GenerateCommitMessageForIndex(config, staged, header+hunk(2)+hunk(20), "", "")
+		}, true},
+		{"full file staged", func(config *utils.Config) (ChatResult[string], error) {
+			return GenerateCommitMessageForIndex(config, staged, staged, "", "")
+		}, false},
+		{"not the index", func(config *utils.Config) (ChatResult[string], error) {
+			return GenerateCommitMessage(config, staged, "")
+		}, false},
+		{"range", func(config *utils.Config) (ChatResult[string], error) {
+			return GenerateCommitMessageFromRange(config, staged)
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("fix(auth): refresh sessions"))
+			if _, err := tt.generate(server.Config()); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Contains(server.Requests()[0].LastUser(), note); got != tt.want {
+				t.Errorf("prompt has the partially staged note: %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartialFilesPrompt(t *testing.T) {
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"full-file diff", nil, nil},
+		{"single-hunk diff", []string{"auth/session.go"}, []string{
+			"## Partially Staged Files:\n- `auth/session.go`\n",
+			"Only some of the changes in these files are being committed",
+			"do not claim the whole file or module was rewritten",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := partialFilesPrompt(tt.paths)
+			if tt.want == nil && prompt != "" {
+				t.Errorf("partialFilesPrompt() = %q, want nothing", prompt)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("partialFilesPrompt() = %q, want it to contain %q", prompt, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatOnlyPrompt(t *testing.T) {
+	const (
+		reindented = "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n" +
+			"@@ -1,3 +1,3 @@\n func main() {\n-  run()\n+\trun()\n }\n"
+		note = "These changes only affect whitespace or formatting, not behavior."
+	)
+
+	tests := []struct {
+		name     string
+		detect   bool
+		types    []string
+		diff     string
+		wantType string
+	}{
+		{"whitespace only", true, testTypes, reindented, "style"},
+		{"whitespace only without style", true, []string{"feat", "fix", "chore"}, reindented, "chore"},
+		{"mixed", true, testTypes, reindented + testDiff("lib.go"), ""},
+		{"detection off", false, testTypes, reindented, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{Types: tt.types, DetectFormatOnly: tt.detect}}
+			prompt, err := commitPrompt(config, tt.diff, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantType == "" {
+				if strings.Contains(prompt, note) {
+					t.Error("prompt has the formatting-only note")
+				}
+				return
+			}
+			if want := note + " Use the `" + tt.wantType + "` commit type."; !strings.Contains(prompt, want) {
+				t.Errorf("prompt doesn't contain %q", want)
+			}
+		})
+	}
+}
+
+func TestTypeDescriptionsPrompt(t *testing.T) {
+	const (
+		buildRule = "  - `build`: For build systems, scripts, or settings (e.g., Makefile, Dockerfile).\n"
+		docsRule  = "  - `docs`: For documentation changes (e.g., README, CHANGELOG), **but not** script or code changes.\n"
+	)
+
+	tests := []struct {
+		name         string
+		descriptions map[string]string
+		want         string
+	}{
+		{"built-ins only", nil, buildRule + docsRule + "\n## **Scope Rules**"},
+		{
+			name:         "custom types after the built-ins",
+			descriptions: map[string]string{"spike": " exploratory work ", "experiment": "trying out an idea"},
+			want: buildRule + docsRule +
+				"  - `experiment`: trying out an idea\n" +
+				"  - `spike`: exploratory work\n" +
+				"\n## **Scope Rules**",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{Types: testTypes, TypeDescriptions: tt.descriptions}}
+			prompt, err := commitPrompt(config, testDiff("x.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(prompt, tt.want) {
+				t.Errorf("prompt doesn't contain %q:\n%s", tt.want, prompt)
+			}
+		})
+	}
+}
+
+func TestExamplesPrompt(t *testing.T) {
+	// Each pair is a little over 100 tokens
+	example := func(name string) utils.CommitExample {
+		return utils.CommitExample{
+			Diff:    "+++ b/" + name + ".go\n" + strings.Repeat("+x\n", 130),
+			Message: "feat(" + name + "): add " + name,
+		}
+	}
+	examples := []utils.CommitExample{example("old"), example("mid"), example("new")}
+
+	tests := []struct {
+		name     string
+		budget   int
+		wantKept []string
+		wantGone []string
+	}{
+		{"all fit", 0, []string{"old", "mid", "new"}, nil},
+		{"oldest dropped", 250, []string{"mid", "new"}, []string{"old"}},
+		{"none fit", 50, nil, []string{"old", "mid", "new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.Commit.Examples = examples
+			config.Commit.ExampleTokenBudget = tt.budget
+
+			if _, err := GenerateCommitMessage(config, testDiff("main.go"), ""); err != nil {
+				t.Fatal(err)
+			}
+			prompt := server.Requests()[0].LastUser()
+
+			for _, name := range tt.wantKept {
+				pair := "### Diff:\n```diff\n+++ b/" + name + ".go\n"
+				message := "### Commit message:\n```text\nfeat(" + name + "): add " + name + "\n```\n"
+				at := strings.Index(prompt, pair)
+				if at < 0 || !strings.Contains(prompt[at:], message) {
+					t.Errorf("prompt is missing the %s example pair", name)
+				}
+			}
+			for _, name := range tt.wantGone {
+				if strings.Contains(prompt, "+++ b/"+name+".go") {
+					t.Errorf("prompt kept the %s example", name)
+				}
+			}
+			if got, want := strings.Contains(prompt, "## Examples:"), len(tt.wantKept) > 0; got != want {
+				t.Errorf("prompt has the examples section: %v, want %v", got, want)
+			}
+			if len(tt.wantKept) == 2 && strings.Index(prompt, "b/mid.go") > strings.Index(prompt, "b/new.go") {
+				t.Error("examples aren't oldest first")
+			}
+		})
+	}
+}
+
+func TestMaxLineLengthPrompt(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("build: update the bundle"))
+	config := server.Config()
+	config.Commit.MaxLineLength = 200
+
+	diff := testDiff("app.min.js") + "+" + strings.Repeat("x", 10000) + "\n"
+	if _, err := GenerateCommitMessage(config, diff, ""); err != nil {
+		t.Fatal(err)
+	}
+	prompt := server.Requests()[0].LastUser()
+	if !strings.Contains(prompt, "+"+strings.Repeat("x", 200)+"…(truncated)\n") || strings.Contains(prompt, strings.Repeat("x", 201)) {
+		t.Error("prompt doesn't cap the long line at 200 characters")
+	}
+}
+
+func TestIntentPrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		intent string
+		want   string
+	}{
+		{"intent", "refactor auth to use context", "- The author's stated intent is: refactor auth to use context\n"},
+		{"trimmed", "  refactor auth \n", "- The author's stated intent is: refactor auth\n"},
+		{"whitespace only", " \n\t", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("refactor(auth): use context"))
+			if _, err := GenerateCommitMessageWithIntent(server.Config(), testDiff("auth/auth.go"), "", tt.intent); err != nil {
+				t.Fatal(err)
+			}
+
+			prompt := server.Requests()[0].LastUser()
+			if tt.want == "" {
+				if strings.Contains(prompt, "## Author's Intent:") {
+					t.Errorf("prompt has an intent section:\n%s", prompt)
+				}
+				return
+			}
+			if !strings.Contains(prompt, "## Author's Intent:\n"+tt.want) {
+				t.Errorf("prompt is missing %q:\n%s", tt.want, prompt)
+			}
+			if !strings.Contains(prompt, "If the intent contradicts the diff, follow the diff") {
+				t.Error("prompt doesn't favor the diff over the intent")
+			}
+		})
+	}
+}
+
+func TestEmptyIntentAddsNothing(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("feat: add x"))
+	if _, err := GenerateCommitMessage(server.Config(), testDiff("x.go"), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GenerateCommitMessageWithIntent(server.Config(), testDiff("x.go"), "", ""); err != nil {
+		t.Fatal(err)
+	}
+	requests := server.Requests()
+	if without, with := requests[0].LastUser(), requests[1].LastUser(); without != with {
+		t.Errorf("an empty intent changed the prompt:\n%s\nvs\n%s", without, with)
+	}
+}
+
+func TestGlossaryPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		glossary map[string]string
+		want     string
+	}{
+		{
+			name:     "sorted terms",
+			glossary: map[string]string{"plp": "product listing page", "pdp": " product detail page "},
+			want: "\n## Glossary:\n**Use these project terms consistently (they are case-insensitive)**:\n" +
+				"- `pdp`: product detail page\n- `plp`: product listing page\n",
+		},
+		{name: "nil", glossary: nil},
+		{name: "empty", glossary: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat(pdp): add reviews"))
+			config := server.Config()
+			config.Commit.Glossary = tt.glossary
+
+			if _, err := GenerateCommitMessage(config, testDiff("pdp/reviews.go"), ""); err != nil {
+				t.Fatal(err)
+			}
+			prompt := server.Requests()[0].LastUser()
+			if tt.want == "" {
+				if strings.Contains(prompt, "## Glossary:") {
+					t.Errorf("prompt has a glossary section:\n%s", prompt)
+				}
+				return
+			}
+			if !strings.Contains(prompt, tt.want) {
+				t.Errorf("prompt is missing the glossary:\n%s", prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const proofreadPrompt = "Fix obvious spelling and grammar mistakes in the commit message below. " +
+	"Do not change its meaning, wording or line breaks beyond the fixes. " +
+	"Keep the type, scope and every bullet exactly as they are. " +
+	"Return only the corrected commit message, without code fences.\n\n"
+
+// proofread asks the model to fix typos in the message. The correction is
+// dropped if it changes the structure of the message: its type, scope,
+// breaking marker, number of bullets or line layout.
+func proofread(ctx context.Context, config *utils.Config, result ChatResult[string]) ChatResult[string] {
+	corrected, err := chat(ctx, config.LLM, proofreadPrompt+result.Message)
+	result.Cost += corrected.Cost
+	if err != nil {
+		return result
+	}
+
+	message := formatCommitMessage(config.Commit, corrected.Message)
+	if sameStructure(result.Message, message) && validateCommitMessage(config.Commit, message) == nil {
+		result.Message = message
+	}
+	return result
+}
+
+func sameStructure(original, corrected string) bool {
+	a, err := ParseGeneratedMessage(original)
+	if err != nil {
+		return false
+	}
+	b, err := ParseGeneratedMessage(corrected)
+	if err != nil {
+		return false
+	}
+	return a.Type == b.Type && a.Scope == b.Scope && a.Breaking == b.Breaking &&
+		len(a.Bullets) == len(b.Bullets) && slices.Equal(lineMarkers(a.Body), lineMarkers(b.Body))
+}
+
+// lineMarkers reduces a body to the leading bullet marker of each line.
+func lineMarkers(body string) []string {
+	var markers []string
+	for _, line := range strings.Split(body, "\n") {
+		marker := ""
+		if matches := bulletRegex.FindStringSubmatch(line); matches != nil {
+			marker = strings.TrimSuffix(line, matches[2])
+		}
+		markers = append(markers, marker)
+	}
+	return markers
+}
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProofreadPass(t *testing.T) {
+	const original = "fix(api): handel nil ordres\n\n- Gaurd the lookup\n- Retrun early"
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		corrected string
+		want      string
+		wantCalls int
+	}{
+		{
+			name:      "typos fixed",
+			enabled:   true,
+			corrected: "fix(api): handle nil orders\n\n- Guard the lookup\n- Return early",
+			want:      "fix(api): handle nil orders\n\n- Guard the lookup\n- Return early\n",
+			wantCalls: 2,
+		},
+		{
+			name:      "changed type rejected",
+			enabled:   true,
+			corrected: "feat(api): handle nil orders\n\n- Guard the lookup\n- Return early",
+			want:      original + "\n",
+			wantCalls: 2,
+		},
+		{
+			name:      "changed scope rejected",
+			enabled:   true,
+			corrected: "fix(orders): handle nil orders\n\n- Guard the lookup\n- Return early",
+			want:      original + "\n",
+			wantCalls: 2,
+		},
+		{
+			name:      "merged bullets rejected",
+			enabled:   true,
+			corrected: "fix(api): handle nil orders\n\n- Guard the lookup and return early",
+			want:      original + "\n",
+			wantCalls: 2,
+		},
+		{
+			name:      "breaking marker added rejected",
+			enabled:   true,
+			corrected: "fix(api)!: handle nil orders\n\n- Guard the lookup\n- Return early",
+			want:      original + "\n",
+			wantCalls: 2,
+		},
+		{
+			name:      "disabled",
+			want:      original + "\n",
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(original, tt.corrected))
+			config := server.Config()
+			config.Commit.ProofreadPass = tt.enabled
+
+			result, err := GenerateCommitMessage(config, testDiff("api/orders.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("sent %d requests, want %d", len(requests), tt.wantCalls)
+			}
+			if tt.wantCalls > 1 && !strings.HasSuffix(requests[1].LastUser(), original+"\n") {
+				t.Errorf("proofread prompt doesn't end with the message:\n%s", requests[1].LastUser())
+			}
+		})
+	}
+}
+
+func TestProofreadPassFailure(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		if n == 0 {
+			writeCompletion(w, "fix: handel nil")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "bad request")
+	})
+	config := server.Config()
+	config.Commit.ProofreadPass = true
+
+	result, err := GenerateCommitMessage(config, testDiff("x.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Message != "fix: handel nil\n" {
+		t.Errorf("message = %q, want the original when proofreading fails", result.Message)
+	}
+}
@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestIncludeRationale(t *testing.T) {
+	const instruction = "- End the body with a bullet point starting with `Why: `"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		reply   string
+		want    string
+	}{
+		{
+			name:    "why bullet accepted",
+			enabled: true,
+			reply:   "fix(api): retry idempotent requests\n\n- Retry GETs on 503\n- Why: the gateway drops requests during deploys",
+			want:    "fix(api): retry idempotent requests\n\n- Retry GETs on 503\n- Why: the gateway drops requests during deploys\n",
+		},
+		{
+			name:    "why bullet omitted",
+			enabled: true,
+			reply:   "fix(api): retry idempotent requests\n\n- Retry GETs on 503",
+			want:    "fix(api): retry idempotent requests\n\n- Retry GETs on 503\n",
+		},
+		{
+			name:  "disabled",
+			reply: "fix(api): retry idempotent requests",
+			want:  "fix(api): retry idempotent requests\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.IncludeRationale = tt.enabled
+			config.Commit.RequireScope = true
+			config.Commit.Scopes = []string{"api"}
+
+			result, err := GenerateCommitMessage(config, testDiff("api/client.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			prompt := server.Requests()[0].LastUser()
+			if got := strings.Contains(prompt, instruction); got != tt.enabled {
+				t.Errorf("prompt has the rationale instruction: %v, want %v", got, tt.enabled)
+			}
+			if tt.enabled && !strings.Contains(prompt, "leave this bullet out instead of guessing") {
+				t.Error("prompt doesn't tell the model to leave out an unclear rationale")
+			}
+		})
+	}
+}
+
+func TestRationaleBulletValidates(t *testing.T) {
+	commit := utils.CommitConfig{Types: testTypes, IncludeRationale: true, HeaderOnlyOnSubject: true}
+	if err := validateCommitMessage(commit, "feat: add x\n\n- Add x\n- Why: users asked for it\n"); err != nil {
+		t.Errorf("validateCommitMessage() = %v, want a Why bullet to pass", err)
+	}
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestRegenerateBody(t *testing.T) {
+	const subject = "feat(api): add pagination to the list endpoint"
+
+	tests := []struct {
+		name    string
+		subject string
+		commit  utils.CommitConfig
+		reply   string
+		want    string
+	}{
+		{
+			name:    "body only",
+			subject: subject,
+			reply:   "- Add page and limit query parameters\n- Return the total count in a header",
+			want:    subject + "\n\n- Add page and limit query parameters\n- Return the total count in a header\n",
+		},
+		{
+			name:    "echoed subject is dropped",
+			subject: subject,
+			reply:   subject + "\n\n- Add page and limit query parameters",
+			want:    subject + "\n\n- Add page and limit query parameters\n",
+		},
+		{
+			name:    "subject period kept",
+			subject: "fix: handle empty pages.",
+			reply:   "* Return an empty list instead of null",
+			want:    "fix: handle empty pages.\n\n- Return an empty list instead of null\n",
+		},
+		{
+			name:    "terse verbosity still gets a body",
+			subject: subject,
+			commit:  utils.CommitConfig{Verbosity: utils.VerbosityTerse},
+			reply:   "- Add page and limit query parameters",
+			want:    subject + "\n\n- Add page and limit query parameters\n",
+		},
+		{
+			name:    "redundant body kept",
+			subject: subject,
+			commit:  utils.CommitConfig{DropRedundantBody: true},
+			reply:   "- Add pagination to the list endpoint",
+			want:    subject + "\n\n- Add pagination to the list endpoint\n",
+		},
+		{
+			name:    "body formatted",
+			subject: subject,
+			commit:  utils.CommitConfig{BulletStyle: utils.BulletStyleAsterisk, MaxBodyBullets: 1},
+			reply:   "- Add page and limit query parameters  \n- Return the total count in a header",
+			want:    subject + "\n\n* Add page and limit query parameters\n…and 1 more changes\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			tt.commit.Types = config.Commit.Types
+			config.Commit = tt.commit
+
+			got, err := RegenerateBody(config, testDiff("api/list.go"), tt.subject)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RegenerateBody() = %q, want %q", got, tt.want)
+			}
+			prompt := server.Requests()[0].LastUser()
+			if !strings.Contains(prompt, "- "+tt.subject+"\n") {
+				t.Errorf("prompt doesn't contain the fixed subject:\n%s", prompt)
+			}
+			if strings.Contains(prompt, "Do not include a body") {
+				t.Error("prompt asks for no body")
+			}
+		})
+	}
+}
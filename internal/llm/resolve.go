@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/cowboy-bebug/kommit/internal/models"
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1/"
+
+// ResolveLLM returns the provider, model and base URL a generation would use,
+// after applying defaults and environment overrides, without making a
+// request. Environment variables take precedence over the config:
+//
+//   - KOMMIT_LLM_PROVIDER overrides llm.provider (default "openai")
+//   - KOMMIT_LLM_MODEL overrides llm.model (default gpt-4o-mini)
+//   - llm.modelAliases then maps the model name to a real one
+//   - KOMMIT_OPENAI_BASE_URL, then OPENAI_BASE_URL, override llm.baseURL
+func ResolveLLM(config *utils.Config) (providerName, model, baseURL string, err error) {
+	resolved, err := resolveLLMConfig(config.LLM)
+	if err != nil {
+		return "", "", "", err
+	}
+	return resolved.Provider, resolved.Model, resolved.BaseURL, nil
+}
+
+func resolveLLMConfig(llmConfig utils.LLMConfig) (utils.LLMConfig, error) {
+	resolved := llmConfig
+
+	if provider := os.Getenv("KOMMIT_LLM_PROVIDER"); provider != "" {
+		resolved.Provider = provider
+	}
+	if resolved.Provider == "" {
+		resolved.Provider = utils.ProviderOpenAI
+	}
+
+	if model := os.Getenv("KOMMIT_LLM_MODEL"); model != "" {
+		resolved.Model = model
+	}
+	resolved.Model = resolved.ResolveModel(resolved.Model)
+
+	switch resolved.Provider {
+	case utils.ProviderOpenAI:
+		if resolved.Model == "" {
+			resolved.Model = models.OpenAIModelGPT4oMini
+		}
+		for _, key := range []string{"KOMMIT_OPENAI_BASE_URL", "OPENAI_BASE_URL"} {
+			if baseURL := os.Getenv(key); baseURL != "" {
+				resolved.BaseURL = baseURL
+				break
+			}
+		}
+		if resolved.BaseURL == "" {
+			resolved.BaseURL = defaultOpenAIBaseURL
+		}
+		if _, err := url.Parse(resolved.BaseURL); err != nil {
+			return resolved, fmt.Errorf("invalid base URL %q: %w", resolved.BaseURL, err)
+		}
+		if !strings.HasSuffix(resolved.BaseURL, "/") {
+			resolved.BaseURL += "/"
+		}
+	case utils.ProviderBedrock:
+		if resolved.Model == "" {
+			return resolved, fmt.Errorf("llm.model must be set to a Bedrock model ID")
+		}
+		region := resolved.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = os.Getenv("AWS_DEFAULT_REGION")
+		}
+		// Without an explicit region the AWS shared config decides it when the
+		// provider is created, so the endpoint can't be known yet.
+		if region != "" {
+			resolved.Region = region
+			resolved.BaseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/", region)
+		}
+	default:
+		return resolved, fmt.Errorf("unknown provider %q", resolved.Provider)
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestResolveLLM(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		llm          utils.LLMConfig
+		wantProvider string
+		wantModel    string
+		wantBaseURL  string
+		wantErr      bool
+	}{
+		{
+			name:         "defaults",
+			wantProvider: utils.ProviderOpenAI,
+			wantModel:    "gpt-4o-mini",
+			wantBaseURL:  "https://api.openai.com/v1/",
+		},
+		{
+			name:         "explicit config",
+			llm:          utils.LLMConfig{Model: "gpt-4o", BaseURL: "http://localhost:8080/v1"},
+			wantProvider: utils.ProviderOpenAI,
+			wantModel:    "gpt-4o",
+			wantBaseURL:  "http://localhost:8080/v1/",
+		},
+		{
+			name: "env overrides config",
+			env: map[string]string{
+				"KOMMIT_LLM_MODEL":       "gpt-4.1",
+				"KOMMIT_OPENAI_BASE_URL": "http://proxy.internal/v1/",
+				"OPENAI_BASE_URL":        "http://ignored/v1/",
+			},
+			llm:          utils.LLMConfig{Model: "gpt-4o", BaseURL: "http://localhost:8080/v1/"},
+			wantProvider: utils.ProviderOpenAI,
+			wantModel:    "gpt-4.1",
+			wantBaseURL:  "http://proxy.internal/v1/",
+		},
+		{
+			name:         "OPENAI_BASE_URL fallback",
+			env:          map[string]string{"OPENAI_BASE_URL": "http://fallback/v1"},
+			wantProvider: utils.ProviderOpenAI,
+			wantModel:    "gpt-4o-mini",
+			wantBaseURL:  "http://fallback/v1/",
+		},
+		{
+			name:         "alias",
+			env:          map[string]string{"KOMMIT_LLM_MODEL": "fast"},
+			llm:          utils.LLMConfig{ModelAliases: map[string]string{"fast": "gpt-4o-mini"}},
+			wantProvider: utils.ProviderOpenAI,
+			wantModel:    "gpt-4o-mini",
+			wantBaseURL:  "https://api.openai.com/v1/",
+		},
+		{
+			name:         "bedrock region from env",
+			env:          map[string]string{"KOMMIT_LLM_PROVIDER": utils.ProviderBedrock, "AWS_REGION": "eu-west-1"},
+			llm:          utils.LLMConfig{Model: "anthropic.claude-3-haiku-20240307-v1:0"},
+			wantProvider: utils.ProviderBedrock,
+			wantModel:    "anthropic.claude-3-haiku-20240307-v1:0",
+			wantBaseURL:  "https://bedrock-runtime.eu-west-1.amazonaws.com/",
+		},
+		{
+			name:    "bedrock without a model",
+			llm:     utils.LLMConfig{Provider: utils.ProviderBedrock, Region: "us-east-1"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown provider",
+			llm:     utils.LLMConfig{Provider: "mystery"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"KOMMIT_LLM_PROVIDER", "KOMMIT_LLM_MODEL", "KOMMIT_OPENAI_BASE_URL", "OPENAI_BASE_URL", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			provider, model, baseURL, err := ResolveLLM(&utils.Config{LLM: tt.llm})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveLLM() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if provider != tt.wantProvider || model != tt.wantModel || baseURL != tt.wantBaseURL {
+				t.Errorf("ResolveLLM() = %q, %q, %q, want %q, %q, %q",
+					provider, model, baseURL, tt.wantProvider, tt.wantModel, tt.wantBaseURL)
+			}
+		})
+	}
+}
+
+func TestModelAliasesInRequests(t *testing.T) {
+	tests := []struct {
+		name  string
+		model string
+		want  string
+	}{
+		{"alias", "smart", "gpt-4o-2024-08-06"},
+		{"non-aliased name", "gpt-4o", "gpt-4o"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.LLM.Model = tt.model
+			config.LLM.ModelAliases = map[string]string{"smart": "gpt-4o-2024-08-06", "fast": "gpt-4o-mini"}
+
+			if _, err := GenerateCommitMessage(config, testDiff("x.go"), ""); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.Requests()[0].Model; got != tt.want {
+				t.Errorf("request model = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestRevertMessage(t *testing.T) {
+	got := revertMessage(&utils.RevertedCommit{Hash: "0123abc", Subject: "feat: add b"})
+	want := "revert: feat: add b\n\nThis reverts commit 0123abc."
+	if got != want {
+		t.Errorf("revertMessage() = %q, want %q", got, want)
+	}
+}
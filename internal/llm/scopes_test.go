@@ -0,0 +1,372 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestGenerateScopesSorted(t *testing.T) {
+	tests := []struct {
+		name     string
+		returned []string
+		existing []string
+		want     []string
+	}{
+		{
+			name:     "unsorted",
+			returned: []string{"ui", "API", "cli", "auth"},
+			want:     []string{"API", "auth", "cli", "ui"},
+		},
+		{
+			name:     "existing scopes excluded",
+			returned: []string{"web", "Auth", "db"},
+			existing: []string{"auth"},
+			want:     []string{"db", "web"},
+		},
+		{
+			name:     "trimmed and de-duplicated",
+			returned: []string{" db ", "db", "", "Cache"},
+			want:     []string{"Cache", "db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, Scopes{Scopes: tt.returned})
+			})
+
+			result, err := GenerateScopesFromFilenamesWithProgress(context.Background(), server.LLMConfig(),
+				[]string{"api/handler.go", "ui/app.tsx"}, tt.existing, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Message.Scopes, tt.want) {
+				t.Errorf("scopes = %q, want %q", result.Message.Scopes, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []string
+		want      string
+		wantErr   error
+		wantCalls int
+	}{
+		{"scope present", []string{"feat(api): add x"}, "feat(api): add x\n", nil, 1},
+		{"re-prompt on missing scope", []string{"feat: add x", "feat(api): add x"}, "feat(api): add x\n", nil, 2},
+		{"still missing after re-prompt", []string{"feat: add x", "feat: add x"}, "", ErrMissingScope, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.replies...))
+			config := server.Config()
+			config.Commit.RequireScope = true
+			config.Commit.Scopes = []string{"api", "ui"}
+
+			result, err := GenerateCommitMessage(config, testDiff("api/x.go"), "")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("sent %d requests, want %d", len(requests), tt.wantCalls)
+			}
+			if !strings.Contains(requests[0].LastUser(), "A scope is **required**") {
+				t.Error("prompt doesn't ask for a scope")
+			}
+			if tt.wantCalls > 1 {
+				if got, want := requests[1].LastUser(), fmt.Sprintf(missingScopePrompt, "api, ui"); got != want {
+					t.Errorf("re-prompt = %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireScopeWithoutScopes(t *testing.T) {
+	config := &utils.Config{Commit: utils.CommitConfig{Types: testTypes, RequireScope: true}}
+	err := config.Validate()
+	if !errors.Is(err, utils.ConfigFieldError{}) || !strings.Contains(err.Error(), "commit.requireScope") {
+		t.Errorf("Validate() = %v, want a commit.requireScope error", err)
+	}
+}
+
+func TestDeriveScopeFromPath(t *testing.T) {
+	const hint = "All changed files belong to the `llm` scope"
+
+	tests := []struct {
+		name       string
+		derivation utils.ScopeDerivation
+		paths      []string
+		want       string
+		wantHint   bool
+	}{
+		{"off", "", []string{"internal/llm/a.go"}, "feat(api): add x\n", false},
+		{"hint", utils.ScopeDerivationHint, []string{"internal/llm/a.go"}, "feat(api): add x\n", true},
+		{"authoritative", utils.ScopeDerivationAuthoritative, []string{"internal/llm/a.go"}, "feat(llm): add x\n", true},
+		{"authoritative across scopes", utils.ScopeDerivationAuthoritative, []string{"internal/llm/a.go", "internal/utils/b.go"}, "feat(api): add x\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat(api): add x"))
+			config := server.Config()
+			config.Commit.Scopes = []string{"api", "llm", "utils"}
+			config.Commit.DeriveScopeFromPath = tt.derivation
+
+			result, err := GenerateCommitMessage(config, testDiff(tt.paths...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+			if got := strings.Contains(server.Requests()[0].LastUser(), hint); got != tt.wantHint {
+				t.Errorf("prompt has the scope hint: %v, want %v", got, tt.wantHint)
+			}
+		})
+	}
+}
+
+func TestPathScopeRules(t *testing.T) {
+	rules := []utils.PathScopeRule{
+		{Pattern: "services/payments/**", Scope: "payments"},
+		{Pattern: "services/**", Scope: "services"},
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"matching rule", []string{"services/payments/charge.go"}, "feat(payments): add x\n"},
+		{"first rule wins", []string{"services/payments/api/handler.go"}, "feat(payments): add x\n"},
+		{"no match falls back to the model", []string{"web/app.tsx"}, "feat(web): add x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat(web): add x"))
+			config := server.Config()
+			config.Commit.PathScopeRules = rules
+
+			result, err := GenerateCommitMessage(config, testDiff(tt.paths...), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleWordScope(t *testing.T) {
+	const instruction = "A scope must be a **single word**"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		reply   string
+		want    string
+	}{
+		{"single word passes through", true, "feat(auth): add login\n\n- Add it", "feat(auth): add login\n\n- Add it\n"},
+		{"hyphenated collapsed", true, "feat(user-auth): add login", "feat(user): add login\n"},
+		{"list collapsed", true, "feat(api, ui)!: add login", "feat(api)!: add login\n"},
+		{"stop words skipped", true, "feat(the/payments): add refunds", "feat(payments): add refunds\n"},
+		{"no significant word dropped", true, "feat(-/-): add login", "feat: add login\n"},
+		{"no scope", true, "feat: add login", "feat: add login\n"},
+		{"disabled", false, "feat(user-auth): add login", "feat(user-auth): add login\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.SingleWordScope = tt.enabled
+
+			result, err := GenerateCommitMessage(config, testDiff("auth/login.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+			if got := strings.Contains(server.Requests()[0].LastUser(), instruction); got != tt.enabled {
+				t.Errorf("prompt has the single-word instruction: %v, want %v", got, tt.enabled)
+			}
+		})
+	}
+}
+
+func TestGoPackageScope(t *testing.T) {
+	goDiff := func(path, pkg string) string {
+		return fmt.Sprintf("diff --git a/%[1]s b/%[1]s\n--- a/%[1]s\n+++ b/%[1]s\n@@ -1,2 +1,3 @@\n package %[2]s\n \n+var x = 1\n", path, pkg)
+	}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		diff   string
+		want   string
+	}{
+		{"single package", nil, goDiff("orders.go", "shop") + goDiff("cart.go", "shop"), "feat(shop): add x\n"},
+		{"mapped to an allowed scope", []string{"Shop"}, goDiff("orders.go", "shop"), "feat(Shop): add x\n"},
+		{"multiple packages fall back to the path", nil, goDiff("svc/a.go", "orders") + goDiff("svc/b.go", "billing"), "feat(svc): add x\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat(web): add x"))
+			config := server.Config()
+			config.Commit.Scopes = tt.scopes
+			config.Commit.DeriveScopeFromPath = utils.ScopeDerivationAuthoritative
+
+			result, err := GenerateCommitMessage(config, tt.diff, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateScopesStream(t *testing.T) {
+	// Three batches, told apart by their directory
+	var filenames []string
+	for batch := range 3 {
+		for i := range scopeBatchSize {
+			filenames = append(filenames, fmt.Sprintf("batch%d/file%d.go", batch, i))
+		}
+	}
+	replies := map[string][]string{
+		"batch0/": {"api", "auth"},
+		"batch1/": {"auth", "db"},
+		"batch2/": {"db", "ui", "legacy"},
+	}
+	existing := []string{"legacy"}
+
+	newServer := func(t *testing.T, failing string) {
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			for dir, scopes := range replies {
+				if strings.Contains(req.LastUser(), dir) {
+					if dir == failing {
+						writeError(w, http.StatusBadRequest, "bad request")
+						return
+					}
+					writeStructured(w, Scopes{Scopes: scopes})
+					return
+				}
+			}
+			writeError(w, http.StatusBadRequest, "unexpected batch")
+		})
+		// The streaming API only takes a model name
+		t.Setenv("KOMMIT_OPENAI_BASE_URL", server.URL+"/")
+	}
+
+	t.Run("matches the batched result", func(t *testing.T) {
+		newServer(t, "")
+
+		out := make(chan string)
+		var streamed []string
+		collected := make(chan struct{})
+		go func() {
+			defer close(collected)
+			for scope := range out {
+				streamed = append(streamed, scope)
+			}
+		}()
+
+		result, err := GenerateScopesStream(context.Background(), "gpt-4o-mini", filenames, existing, out)
+		<-collected
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		batched, err := GenerateScopesFromFilenames("gpt-4o-mini", filenames, existing, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(streamed)
+		if want := []string{"api", "auth", "db", "ui"}; !reflect.DeepEqual(streamed, want) {
+			t.Errorf("streamed %q, want %q", streamed, want)
+		}
+		if !reflect.DeepEqual(streamed, batched.Message.Scopes) {
+			t.Errorf("streamed %q, batched %q", streamed, batched.Message.Scopes)
+		}
+		if got := slices.Sorted(slices.Values(result.Message.Scopes)); !reflect.DeepEqual(got, streamed) {
+			t.Errorf("returned %q, streamed %q", result.Message.Scopes, streamed)
+		}
+	})
+
+	t.Run("error returned and channel closed", func(t *testing.T) {
+		newServer(t, "batch1/")
+
+		out := make(chan string, len(filenames))
+		_, err := GenerateScopesStream(context.Background(), "gpt-4o-mini", filenames, existing, out)
+		if err == nil {
+			t.Error("error = nil, want the failed batch's error")
+		}
+		// Only ends once out is closed
+		for range out {
+		}
+	})
+}
+
+func TestMaxScopes(t *testing.T) {
+	filenames := []string{
+		"api/handler.go", "api/routes.go", "api/middleware.go",
+		"db/schema.sql", "db/migrate.go",
+		"ui/app.tsx",
+		"README.md",
+	}
+	returned := []string{"ui", "api", "docs", "db"}
+
+	tests := []struct {
+		name      string
+		maxScopes int
+		want      []string
+	}{
+		{"uncapped", 0, []string{"api", "db", "docs", "ui"}},
+		{"most-supported kept", 2, []string{"api", "db"}},
+		{"single scope", 1, []string{"api"}},
+		{"unsupported scope dropped first", 3, []string{"api", "db", "ui"}},
+		{"cap above the count", 10, []string{"api", "db", "docs", "ui"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, Scopes{Scopes: returned})
+			})
+
+			result, err := GenerateScopesFromFilenamesWithProgress(context.Background(), server.LLMConfig(),
+				filenames, nil, tt.maxScopes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Message.Scopes, tt.want) {
+				t.Errorf("scopes = %q, want %q", result.Message.Scopes, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const maxSubjectLength = 72
+
+// ScoreCommitMessage rates a commit message from 0 to 100 by how well it
+// follows the Conventional Commits format and the config, without any API
+// call. Messages that don't parse at all score 0.
+func ScoreCommitMessage(msg string, config *utils.Config) int {
+	subject, body := utils.SplitCommitMessage(msg)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok {
+		return 0
+	}
+
+	score := 30
+	allowed := config.Commit.AllowedTypes()
+	if len(allowed) == 0 || slices.Contains(allowed, header.Type) {
+		score += 20
+	}
+	if utf8.RuneCountInString(subject) <= maxSubjectLength {
+		score += 15
+	}
+	switch {
+	case header.Scope == "":
+		if !config.Commit.RequireScope {
+			score += 15
+		}
+	case len(config.Commit.Scopes) == 0 || slices.Contains(config.Commit.Scopes, header.Scope):
+		score += 15
+	}
+	// Terse messages shouldn't have a body, all others should
+	if (body == "") == (config.Commit.Verbosity == utils.VerbosityTerse) {
+		score += 10
+	}
+	if !config.Commit.StripsSubjectPeriod() || !strings.HasSuffix(header.Description, ".") {
+		score += 5
+	}
+	if first, _ := utf8.DecodeRuneInString(header.Description); !unicode.IsUpper(first) {
+		score += 5
+	}
+	return score
+}
+
+// GenerateBestCommitMessage generates n candidates concurrently and returns
+// the one with the highest ScoreCommitMessage, preferring earlier candidates
+// on ties. Failed candidates are skipped; an error is returned only if all of
+// them fail. The cost covers every candidate.
+func GenerateBestCommitMessage(config *utils.Config, diff, userContext string, n int) (ChatResult[string], error) {
+	n = max(n, 1)
+	results := make([]ChatResult[string], n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = generateCommitMessage(context.Background(), config, diff, userContext)
+		}()
+	}
+	wg.Wait()
+
+	best, bestScore := -1, -1
+	var total ChatResult[string]
+	for i, result := range results {
+		total.Cost += result.Cost
+		total.APICallCount += result.APICallCount
+		if errs[i] != nil {
+			continue
+		}
+		if score := ScoreCommitMessage(result.Message, config); score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best < 0 {
+		return total, errors.Join(errs...)
+	}
+
+	results[best].Cost = total.Cost
+	results[best].APICallCount = total.APICallCount
+	return results[best], nil
+}
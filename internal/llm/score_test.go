@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestScoreCommitMessage(t *testing.T) {
+	config := &utils.Config{Commit: utils.CommitConfig{Types: []string{"feat", "fix"}, Scopes: []string{"api"}}}
+
+	tests := []struct {
+		name    string
+		message string
+		want    int
+	}{
+		{"well-formed", "feat(api): add order export\n\n- Stream rows to CSV\n", 100},
+		{"not conventional", "Added order export", 0},
+		{"type not allowed", "perf(api): add order export\n\n- Stream rows\n", 80},
+		{"scope not allowed", "feat(web): add order export\n\n- Stream rows\n", 85},
+		{"no scope", "feat: add order export\n\n- Stream rows\n", 100},
+		{"no body", "feat(api): add order export\n", 90},
+		{"subject too long", "feat(api): " + strings.Repeat("add ", 20) + "\n\n- Stream rows\n", 85},
+		{"capitalized with a period", "feat(api): Add order export.\n\n- Stream rows\n", 90},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScoreCommitMessage(tt.message, config); got != tt.want {
+				t.Errorf("ScoreCommitMessage() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoreCommitMessageRanking(t *testing.T) {
+	config := &utils.Config{Commit: utils.CommitConfig{Types: testTypes}}
+	wellFormed := ScoreCommitMessage("fix: handle nil orders\n\n- Guard the lookup\n", config)
+	malformed := ScoreCommitMessage("Fixed: Handle nil orders.", config)
+	if wellFormed <= malformed {
+		t.Errorf("well-formed scored %d, malformed %d", wellFormed, malformed)
+	}
+}
+
+func TestGenerateBestCommitMessage(t *testing.T) {
+	candidates := []string{
+		"feat(api): Add order export.",
+		"feat(api): add order export\n\n- Stream rows to CSV",
+		"feat: " + strings.Repeat("add ", 20),
+	}
+
+	t.Run("top candidate selected", func(t *testing.T) {
+		server := newMockOpenAI(t, replyWith(candidates...))
+		config := server.Config()
+
+		result, err := GenerateBestCommitMessage(config, testDiff("api/export.go"), "", len(candidates))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "feat(api): add order export\n\n- Stream rows to CSV\n"; result.Message != want {
+			t.Errorf("message = %q, want %q", result.Message, want)
+		}
+		if len(server.Requests()) != len(candidates) || result.APICallCount != len(candidates) {
+			t.Errorf("sent %d requests, counted %d, want %d", len(server.Requests()), result.APICallCount, len(candidates))
+		}
+	})
+
+	t.Run("failed candidates skipped", func(t *testing.T) {
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			if n == 0 {
+				writeCompletion(w, "fix: handle nil")
+				return
+			}
+			writeError(w, http.StatusBadRequest, "bad request")
+		})
+
+		result, err := GenerateBestCommitMessage(server.Config(), testDiff("x.go"), "", 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Message != "fix: handle nil\n" {
+			t.Errorf("message = %q", result.Message)
+		}
+	})
+
+	t.Run("all candidates fail", func(t *testing.T) {
+		server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+			writeError(w, http.StatusBadRequest, "bad request")
+		})
+
+		if _, err := GenerateBestCommitMessage(server.Config(), testDiff("x.go"), "", 2); err == nil {
+			t.Error("error = nil, want one")
+		}
+	})
+}
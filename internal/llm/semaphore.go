@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// Semaphore limits how many API calls run at once. *semaphore.Weighted from
+// golang.org/x/sync satisfies it, so a budget can be shared with other tools
+// in the same process.
+type Semaphore interface {
+	Acquire(ctx context.Context, n int64) error
+	Release(n int64)
+}
+
+var (
+	semaphoreMu sync.RWMutex
+	semaphore   Semaphore
+)
+
+// SetSemaphore makes every API call acquire one unit of sem first and release
+// it once the call returns, successfully or not. Pass nil to remove the limit.
+func SetSemaphore(sem Semaphore) {
+	semaphoreMu.Lock()
+	defer semaphoreMu.Unlock()
+	semaphore = sem
+}
+
+// acquire blocks until the shared semaphore, if any, admits one more call.
+// The returned function must be called to release it.
+func acquire(ctx context.Context) (func(), error) {
+	semaphoreMu.RLock()
+	sem := semaphore
+	semaphoreMu.RUnlock()
+
+	if sem == nil {
+		return func() {}, nil
+	}
+	if err := sem.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	return func() { sem.Release(1) }, nil
+}
+
+func createCompletion(ctx context.Context, client *openai.Client, params openai.ChatCompletionNewParams) (*openai.ChatCompletion, *RateLimitInfo, error) {
+	release, err := acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	var httpResp *http.Response
+	resp, err := client.Chat.Completions.New(ctx, params, option.WithResponseInto(&httpResp))
+	if httpResp == nil {
+		return resp, nil, err
+	}
+	return resp, parseRateLimitHeaders(httpResp.Header), err
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// fakeSemaphore records the calls made to it and how many units are held.
+type fakeSemaphore struct {
+	mu         sync.Mutex
+	acquireErr error
+	held       int
+	acquired   int
+	released   int
+}
+
+func (s *fakeSemaphore) Acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.acquireErr != nil {
+		return s.acquireErr
+	}
+	s.acquired += int(n)
+	s.held += int(n)
+	return nil
+}
+
+func (s *fakeSemaphore) Release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.released += int(n)
+	s.held -= int(n)
+}
+
+func (s *fakeSemaphore) Held() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.held
+}
+
+func TestSemaphore(t *testing.T) {
+	acquireErr := errors.New("budget exhausted")
+
+	chatCall := func(llmConfig utils.LLMConfig) error {
+		_, err := chat(context.Background(), llmConfig, "prompt")
+		return err
+	}
+	structuredCall := func(llmConfig utils.LLMConfig) error {
+		_, err := chatStructured[Scopes](context.Background(), llmConfig, "prompt", testScopesSchema)
+		return err
+	}
+
+	tests := []struct {
+		name         string
+		call         func(utils.LLMConfig) error
+		fail         bool
+		acquireErr   error
+		wantErr      bool
+		wantRequests int
+		wantAcquired int
+	}{
+		{"success", chatCall, false, nil, false, 1, 1},
+		{"request error", chatCall, true, nil, true, 1, 1},
+		{"acquire error", chatCall, false, acquireErr, true, 0, 0},
+		{"structured success", structuredCall, false, nil, false, 1, 1},
+		{"structured request error", structuredCall, true, nil, true, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sem := &fakeSemaphore{acquireErr: tt.acquireErr}
+			SetSemaphore(sem)
+			t.Cleanup(func() { SetSemaphore(nil) })
+
+			heldDuringRequest := -1
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				heldDuringRequest = sem.Held()
+				if tt.fail {
+					writeError(w, http.StatusBadRequest, "bad request")
+					return
+				}
+				writeStructured(w, Scopes{Scopes: []string{"api"}})
+			})
+
+			err := tt.call(server.LLMConfig())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.acquireErr != nil && !errors.Is(err, tt.acquireErr) {
+				t.Errorf("error = %v, want the acquire error", err)
+			}
+
+			if got := len(server.Requests()); got != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+			if tt.wantRequests > 0 && heldDuringRequest != 1 {
+				t.Errorf("semaphore held %d units during the request, want 1", heldDuringRequest)
+			}
+			if sem.acquired != tt.wantAcquired || sem.released != tt.wantAcquired || sem.Held() != 0 {
+				t.Errorf("acquired %d, released %d, held %d; want %d acquired and released",
+					sem.acquired, sem.released, sem.Held(), tt.wantAcquired)
+			}
+		})
+	}
+}
+
+func TestSemaphoreUnset(t *testing.T) {
+	SetSemaphore(nil)
+	release, err := acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
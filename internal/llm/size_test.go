@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxRequestBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		prompt    string
+		limit     int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"oversized prompt", strings.Repeat("x", 5000), 1000, true, 0},
+		{"within limit", "small diff", 100000, false, 1},
+		{"no limit", strings.Repeat("x", 5000), 0, false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			llmConfig := server.LLMConfig()
+			llmConfig.MaxRequestBytes = tt.limit
+
+			_, err := chat(context.Background(), llmConfig, tt.prompt)
+			var tooLarge RequestTooLargeError
+			if got := errors.As(err, &tooLarge); got != tt.wantErr {
+				t.Fatalf("chat() error = %v, want RequestTooLargeError: %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (tooLarge.Limit != tt.limit || tooLarge.Size <= tt.limit) {
+				t.Errorf("RequestTooLargeError = %+v, want size above limit %d", tooLarge, tt.limit)
+			}
+			if got := len(server.Requests()); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestMaxRequestBytesBedrock(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompt  string
+		limit   int
+		wantErr bool
+	}{
+		{"oversized prompt", strings.Repeat("x", 5000), 1000, true},
+		{"within limit", "small diff", 100000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := 0
+			provider := testBedrockProvider(func(req *http.Request) (*http.Response, error) {
+				sent++
+				return bedrockResponse(`{"content":[{"type":"text","text":"feat: add x"}]}`), nil
+			})
+			provider.MaxRequestBytes = tt.limit
+
+			_, err := provider.Chat(context.Background(), kommitSystemPrompt, tt.prompt, 0)
+			if got := errors.Is(err, ErrRequestTooLarge); got != tt.wantErr {
+				t.Fatalf("Chat() error = %v, want RequestTooLargeError: %v", err, tt.wantErr)
+			}
+			if wantSent := map[bool]int{true: 0, false: 1}[tt.wantErr]; sent != wantSent {
+				t.Errorf("sent %d requests, want %d", sent, wantSent)
+			}
+		})
+	}
+}
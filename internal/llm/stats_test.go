@@ -0,0 +1,19 @@
+package llm
+
+import "testing"
+
+func TestDiffStatsInResult(t *testing.T) {
+	server := newMockOpenAI(t, replyWith("feat: add x"))
+	config := server.Config()
+	config.Commit.IgnorePatterns = []string{"*.lock"}
+
+	diff := testDiff("a.go", "b.go", "yarn.lock")
+	result, err := GenerateCommitMessage(config, diff, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The ignored file is still listed, but its contents aren't counted
+	if got, want := result.DiffStats.String(), "3 files, +2 −0"; got != want {
+		t.Errorf("DiffStats = %q, want %q", got, want)
+	}
+}
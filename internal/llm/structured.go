@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var codeFenceRegex = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*\\n(.*?)```")
+
+// parseStructuredFromText unmarshals a JSON answer from a free-text response,
+// for providers and fallbacks without native schema enforcement. Besides pure
+// JSON it accepts JSON inside a code fence and JSON surrounded by prose, in
+// which case the first balanced JSON object is used.
+func parseStructuredFromText[T any](raw string) (T, error) {
+	result, _, err := decodeStructured[T](raw)
+	return result, err
+}
+
+// parseStructuredWithSchema is like parseStructuredFromText, but also checks
+// the decoded object against schema, see validateAgainstSchema.
+func parseStructuredWithSchema[T any](raw string, schema any) (T, error) {
+	result, decoded, err := decodeStructured[T](raw)
+	if err != nil {
+		return result, err
+	}
+	return result, validateAgainstSchema(decoded, schema)
+}
+
+// decodeStructured implements parseStructuredFromText and also returns the
+// JSON text that was decoded.
+func decodeStructured[T any](raw string) (T, string, error) {
+	var result T
+	trimmed := strings.TrimSpace(raw)
+	err := json.Unmarshal([]byte(trimmed), &result)
+	if err == nil {
+		return result, trimmed, nil
+	}
+
+	var candidates []string
+	if matches := codeFenceRegex.FindStringSubmatch(raw); matches != nil {
+		candidates = append(candidates, matches[1])
+	}
+	if extracted, ok := extractJSONObject(raw); ok {
+		candidates = append(candidates, extracted)
+	}
+	for _, candidate := range candidates {
+		var extractedResult T
+		if json.Unmarshal([]byte(candidate), &extractedResult) == nil {
+			return extractedResult, candidate, nil
+		}
+	}
+	return result, "", err
+}
+
+// validateAgainstSchema checks that a decoded JSON object has every property
+// the schema requires and, unless the schema allows additional properties, no
+// others. Only the top level is checked, and anything that isn't an object
+// schema passes.
+func validateAgainstSchema(decoded string, schema any) error {
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+	var objectSchema struct {
+		Properties           map[string]json.RawMessage `json:"properties"`
+		Required             []string                   `json:"required"`
+		AdditionalProperties *bool                      `json:"additionalProperties"`
+	}
+	if err := json.Unmarshal(schemaJSON, &objectSchema); err != nil || objectSchema.Properties == nil {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(decoded), &object); err != nil {
+		return nil
+	}
+
+	var violation SchemaViolationError
+	for _, field := range objectSchema.Required {
+		if _, ok := object[field]; !ok {
+			violation.Missing = append(violation.Missing, field)
+		}
+	}
+	if objectSchema.AdditionalProperties != nil && !*objectSchema.AdditionalProperties {
+		for field := range object {
+			if _, ok := objectSchema.Properties[field]; !ok {
+				violation.Extra = append(violation.Extra, field)
+			}
+		}
+		sort.Strings(violation.Extra)
+	}
+	if len(violation.Missing) > 0 || len(violation.Extra) > 0 {
+		return violation
+	}
+	return nil
+}
+
+// extractJSONObject returns the substring from the first `{` up to its
+// matching `}`, skipping braces inside string literals.
+func extractJSONObject(content string) (string, bool) {
+	start := strings.Index(content, "{")
+	if start < 0 {
+		return "", false
+	}
+
+	depth := 0
+	inString := false
+	escaped := false
+	for i := start; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return content[start : i+1], true
+			}
+		}
+	}
+	return "", false
+}
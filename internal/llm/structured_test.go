@@ -0,0 +1,277 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+var testScopesSchema = openai.ResponseFormatJSONSchemaJSONSchemaParam{
+	Name:   openai.F("names"),
+	Schema: openai.F(StructuredScopesSchema),
+	Strict: openai.Bool(true),
+}
+
+func TestChatStructuredRecovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		replies   []string
+		want      []string
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "pure JSON",
+			replies:   []string{`{"scopes":["api"]}`},
+			want:      []string{"api"},
+			wantCalls: 1,
+		},
+		{
+			name:      "JSON wrapped in prose",
+			replies:   []string{`Sure! Here are the scopes: {"scopes":["api","ui"]} Let me know if you need more.`},
+			want:      []string{"api", "ui"},
+			wantCalls: 1,
+		},
+		{
+			name:      "broken JSON fixed after re-prompt",
+			replies:   []string{`{"scopes":["api",]}`, `{"scopes":["api"]}`},
+			want:      []string{"api"},
+			wantCalls: 2,
+		},
+		{
+			name:      "broken JSON after re-prompt",
+			replies:   []string{`{"scopes":["api",]}`, `scopes: api`},
+			wantErr:   ErrInvalidJSON,
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.replies...))
+
+			result, err := chatStructured[Scopes](context.Background(), server.LLMConfig(), "prompt", testScopesSchema)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(result.Message.Scopes, tt.want) {
+				t.Errorf("scopes = %q, want %q", result.Message.Scopes, tt.want)
+			}
+
+			requests := server.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("sent %d requests, want %d", len(requests), tt.wantCalls)
+			}
+			if tt.wantCalls == 2 {
+				retry := requests[1]
+				if got := retry.LastUser(); got != jsonRetryPrompt {
+					t.Errorf("re-prompt = %q, want %q", got, jsonRetryPrompt)
+				}
+				if got := retry.Messages[len(retry.Messages)-2].Text(); got != tt.replies[0] {
+					t.Errorf("re-prompt doesn't include the broken answer, got %q", got)
+				}
+			}
+		})
+	}
+}
+
+func TestChatStructuredNoChoices(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		body := completionBody("")
+		body["choices"] = []any{}
+		writeJSON(w, http.StatusOK, body)
+	})
+
+	_, err := chatStructured[Scopes](context.Background(), server.LLMConfig(), "prompt", testScopesSchema)
+	if !errors.Is(err, &OpenAIRequestError{}) {
+		t.Errorf("error = %v, want OpenAIRequestError", err)
+	}
+}
+
+func TestChatStructuredSchemaOptions(t *testing.T) {
+	strict, notStrict := true, false
+
+	tests := []struct {
+		name       string
+		schemaName string
+		strict     *bool
+		wantName   string
+		wantStrict bool
+	}{
+		{"defaults", "", nil, "names", true},
+		{"strict", "scopes", &strict, "scopes", true},
+		{"non-strict", "scopes", &notStrict, "scopes", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				writeStructured(w, Scopes{Scopes: []string{"api"}})
+			})
+			llmConfig := server.LLMConfig()
+			llmConfig.SchemaName = tt.schemaName
+			llmConfig.StrictSchema = tt.strict
+
+			if _, err := chatStructured[Scopes](context.Background(), llmConfig, "prompt", testScopesSchema); err != nil {
+				t.Fatal(err)
+			}
+
+			format, _ := server.Requests()[0].Raw["response_format"].(map[string]any)
+			schema, _ := format["json_schema"].(map[string]any)
+			if format["type"] != "json_schema" || schema["name"] != tt.wantName || schema["strict"] != tt.wantStrict {
+				t.Errorf("response_format = %v, want a %q schema with strict %v", format, tt.wantName, tt.wantStrict)
+			}
+		})
+	}
+}
+
+func TestChatStructuredSchemaUnsupported(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		if n == 0 {
+			writeError(w, http.StatusBadRequest, "Invalid parameter: 'response_format' of type 'json_schema' is not supported with this model.")
+			return
+		}
+		writeStructured(w, Scopes{Scopes: []string{"api"}})
+	})
+
+	result, err := chatStructured[Scopes](context.Background(), server.LLMConfig(), "prompt", testScopesSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Message.Scopes, []string{"api"}) {
+		t.Errorf("scopes = %q", result.Message.Scopes)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "fell back to prompt-enforced JSON") {
+		t.Errorf("warnings = %q, want the fallback warning", result.Warnings)
+	}
+
+	requests := server.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(requests))
+	}
+	format, _ := requests[1].Raw["response_format"].(map[string]any)
+	if format["type"] != "json_object" {
+		t.Errorf("fallback response_format = %v, want json_object", format)
+	}
+	if prompt := requests[1].LastUser(); !strings.HasPrefix(prompt, "prompt") || !strings.Contains(prompt, `"scopes"`) {
+		t.Errorf("fallback prompt doesn't describe the schema:\n%s", prompt)
+	}
+}
+
+func TestParseStructuredFromText(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{"pure JSON", `{"scopes": ["api", "ui"]}`, []string{"api", "ui"}, false},
+		{"padded JSON", "\n  {\"scopes\": [\"api\"]}\n", []string{"api"}, false},
+		{"code fence", "```json\n{\"scopes\": [\"api\"]}\n```", []string{"api"}, false},
+		{"code fence with prose", "Here you go:\n```\n{\"scopes\": [\"db\"]}\n```\nLet me know!", []string{"db"}, false},
+		{"leading prose", `Sure! The scopes are {"scopes": ["cli"]}`, []string{"cli"}, false},
+		{"trailing text", `{"scopes": ["cli"]} Hope that helps.`, []string{"cli"}, false},
+		{"braces inside strings", `Result: {"scopes": ["a}b", "{c"]} done`, []string{"a}b", "{c"}, false},
+		{"no JSON", "I can't help with that.", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStructuredFromText[Scopes](tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseStructuredFromText() = %q, want an error", got.Scopes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Scopes, tt.want) {
+				t.Errorf("scopes = %q, want %q", got.Scopes, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatStructuredSchemaValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		replies     []string
+		want        []string
+		wantMissing []string
+		wantExtra   []string
+		wantCalls   int
+	}{
+		{"valid", []string{`{"scopes": ["api"]}`}, []string{"api"}, nil, nil, 1},
+		{"missing field", []string{`{}`, `{}`}, nil, []string{"scopes"}, nil, 2},
+		{"extra field", []string{`{"scopes": ["api"], "reason": "x"}`, `{"scopes": ["api"], "reason": "x"}`}, nil, nil, []string{"reason"}, 2},
+		{"fixed on retry", []string{`{"names": ["api"]}`, `{"scopes": ["ui"]}`}, []string{"ui"}, nil, nil, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.replies...))
+
+			result, err := chatStructured[Scopes](context.Background(), server.LLMConfig(), "prompt", testScopesSchema)
+			if got := len(server.Requests()); got != tt.wantCalls {
+				t.Errorf("sent %d requests, want %d", got, tt.wantCalls)
+			}
+			if tt.want != nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(result.Message.Scopes, tt.want) {
+					t.Errorf("scopes = %q, want %q", result.Message.Scopes, tt.want)
+				}
+				return
+			}
+
+			var violation SchemaViolationError
+			if !errors.As(err, &violation) || !errors.Is(err, ErrSchemaViolation) {
+				t.Fatalf("error = %v, want a SchemaViolationError", err)
+			}
+			if !reflect.DeepEqual(violation.Missing, tt.wantMissing) || !reflect.DeepEqual(violation.Extra, tt.wantExtra) {
+				t.Errorf("violation = %+v, want missing %q and extra %q", violation, tt.wantMissing, tt.wantExtra)
+			}
+		})
+	}
+}
+
+func TestValidateAgainstSchema(t *testing.T) {
+	schema := map[string]any{
+		"type":                 "object",
+		"properties":           map[string]any{"a": map[string]any{}, "b": map[string]any{}},
+		"required":             []string{"a"},
+		"additionalProperties": false,
+	}
+	open := map[string]any{"type": "object", "properties": map[string]any{"a": map[string]any{}}}
+
+	tests := []struct {
+		name    string
+		decoded string
+		schema  any
+		wantErr bool
+	}{
+		{"required only", `{"a": 1}`, schema, false},
+		{"optional property", `{"a": 1, "b": 2}`, schema, false},
+		{"missing required", `{"b": 2}`, schema, true},
+		{"extra property", `{"a": 1, "c": 3}`, schema, true},
+		{"additional properties allowed", `{"a": 1, "c": 3}`, open, false},
+		{"not an object schema", `{"c": 3}`, map[string]any{"type": "string"}, false},
+		{"not an object", `["a"]`, schema, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAgainstSchema(tt.decoded, tt.schema); (err != nil) != tt.wantErr {
+				t.Errorf("validateAgainstSchema() = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
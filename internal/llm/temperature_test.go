@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemperatureByType(t *testing.T) {
+	defaultTemperature := 0.2
+
+	tests := []struct {
+		name  string
+		reply string
+		// want are the temperatures of the requests sent
+		want []float64
+	}{
+		{"matching type", "feat: add search", []float64{0.2, 0.9}},
+		{"type with the default temperature", "docs: fix typo", []float64{0.2}},
+		{"unconfigured type", "fix: handle nil", []float64{0.2}},
+		{"not a conventional commit", "Update things", []float64{0.2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.LLM.Temperature = &defaultTemperature
+			config.Commit.TemperatureByType = map[string]float64{"feat": 0.9, "docs": 0.2}
+
+			if _, err := GenerateCommitMessage(config, testDiff("x.go"), ""); err != nil {
+				t.Fatal(err)
+			}
+			var got []float64
+			for _, req := range server.Requests() {
+				got = append(got, req.Raw["temperature"].(float64))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("temperatures = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
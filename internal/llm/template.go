@@ -0,0 +1,96 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// PromptData is passed to commit.promptTemplate. Since templates are rendered
+// with text/template, conditionals such as
+//
+//	{{if .Breaking}}Mark the commit as breaking with `!`.{{end}}
+//
+// work out of the box. Available fields:
+//
+//   - Breaking: the diff looks like it removes exported Go declarations, or
+//     the user context mentions "BREAKING CHANGE"
+//   - FileCount: number of files in the diff
+//   - AddedLines, RemovedLines: number of added and removed lines
+//   - Types: allowed commit types
+//   - Scopes: allowed commit scopes
+//   - UserContext: the message passed with --message, if any
+//   - Diff: the staged diff
+type PromptData struct {
+	Breaking     bool
+	FileCount    int
+	AddedLines   int
+	RemovedLines int
+	Types        []string
+	Scopes       []string
+	UserContext  string
+	Diff         string
+}
+
+var exportedDeclRegex = regexp.MustCompile(`^[-+]\s*(?:func|type)\s+(?:\([^)]*\)\s*)?([A-Z]\w*)`)
+
+func newPromptData(config *utils.Config, diff, userContext string) PromptData {
+	files := utils.ParseDiff(diff)
+	data := PromptData{
+		FileCount:   len(files),
+		Types:       config.Commit.AllowedTypes(),
+		Scopes:      config.Commit.Scopes,
+		UserContext: userContext,
+		Diff:        diff,
+	}
+	for _, f := range files {
+		data.AddedLines += f.Additions()
+		data.RemovedLines += f.Deletions()
+	}
+	data.Breaking = strings.Contains(userContext, "BREAKING CHANGE") || removesExportedDecl(files)
+	return data
+}
+
+// removesExportedDecl reports whether an exported Go func or type is removed
+// without being added back elsewhere in the diff.
+func removesExportedDecl(files []utils.FileDiff) bool {
+	removed := make(map[string]bool)
+	added := make(map[string]bool)
+	for _, f := range files {
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				matches := exportedDeclRegex.FindStringSubmatch(line)
+				if matches == nil {
+					continue
+				}
+				if line[0] == '-' {
+					removed[matches[1]] = true
+				} else {
+					added[matches[1]] = true
+				}
+			}
+		}
+	}
+
+	for name := range removed {
+		if !added[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func renderPromptTemplate(tmpl string, data PromptData) (string, error) {
+	t, err := template.New("prompt").Parse(tmpl)
+	if err != nil {
+		return "", PromptTemplateError{Err: err}
+	}
+
+	var b strings.Builder
+	if err := t.Execute(&b, data); err != nil {
+		return "", PromptTemplateError{Err: err}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+const breakingDiff = `diff --git a/api.go b/api.go
+index 1111111..2222222 100644
+--- a/api.go
++++ b/api.go
+@@ -1,4 +1,2 @@
+ package api
+-func Fetch(url string) error {
+-}
++// Fetch was removed
+`
+
+func TestPromptTemplateConditionals(t *testing.T) {
+	const tmpl = `{{if .Breaking}}BREAKING{{else}}compatible{{end}} {{.FileCount}} +{{.AddedLines}}/-{{.RemovedLines}} {{range .Types}}{{.}},{{end}} {{.UserContext}}`
+
+	tests := []struct {
+		name        string
+		diff        string
+		userContext string
+		want        string
+	}{
+		{"removed exported func", breakingDiff, "", "BREAKING 1 +1/-2 feat,fix, "},
+		{"breaking change in user context", testDiff("main.go"), "BREAKING CHANGE: drop v1", "BREAKING 1 +1/-0 feat,fix, BREAKING CHANGE: drop v1"},
+		{"compatible change", testDiff("main.go", "util.go"), "", "compatible 2 +2/-0 feat,fix, "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{Types: []string{"feat", "fix"}, PromptTemplate: tmpl}}
+			got, err := commitPrompt(config, tt.diff, tt.userContext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("commitPrompt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPromptTemplateErrors(t *testing.T) {
+	for _, tmpl := range []string{"{{if .Breaking}}unterminated", "{{.NoSuchField}}"} {
+		t.Run(tmpl, func(t *testing.T) {
+			config := &utils.Config{Commit: utils.CommitConfig{PromptTemplate: tmpl}}
+			if _, err := commitPrompt(config, testDiff("main.go"), ""); !errors.Is(err, ErrInvalidPromptTemplate) {
+				t.Errorf("commitPrompt() error = %v, want ErrInvalidPromptTemplate", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package llm
+
+import "testing"
+
+func TestTicketRefsFooter(t *testing.T) {
+	diff := "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1,3 @@\n line\n+// TODO(JIRA-12): remove\n+// JIRA-12, see #4\n"
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"enabled", true, "fix: guard nil\n\n- Check it\n\nRefs: JIRA-12, #4\n"},
+		{"disabled", false, "fix: guard nil\n\n- Check it\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("fix: guard nil\n\n- Check it"))
+			config := server.Config()
+			config.Commit.TicketRefs = tt.enabled
+
+			result, err := GenerateCommitMessage(config, diff, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != tt.want {
+				t.Errorf("message = %q, want %q", result.Message, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package llm
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// unacceptedAddr returns the address of a listener whose accept queue is
+// full, so that further connections hang while dialling. Linux queues
+// backlog+1 connections, and net.Listen can't set the backlog.
+func unacceptedAddr(t *testing.T) string {
+	t.Helper()
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { syscall.Close(fd) })
+	if err := syscall.Bind(fd, &syscall.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Listen(fd, 0); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := syscall.Getsockname(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(sa.(*syscall.SockaddrInet4).Port))
+
+	// Fill the queue
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return addr
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// Only used for its environment, the requests go to the full listener
+	server := newMockOpenAI(t, replyWith("feat: add x"))
+	config := server.Config()
+	config.LLM.BaseURL = "http://" + unacceptedAddr(t) + "/"
+	config.LLM.ConnectTimeout = 50 * time.Millisecond
+	config.LLM.ResponseTimeout = time.Minute
+	config.LLM.TotalAttemptBudget = 1
+
+	start := time.Now()
+	_, err := GenerateCommitMessage(config, testDiff("main.go"), "")
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" || !opErr.Timeout() {
+		t.Fatalf("error = %v, want a dial timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("gave up after %v, want the connect timeout to apply", elapsed)
+	}
+}
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestResponseTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		delay   time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"slow generation times out", 500 * time.Millisecond, 50 * time.Millisecond, true},
+		{"generation within the timeout", 20 * time.Millisecond, 5 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				time.Sleep(tt.delay)
+				writeCompletion(w, "feat: add x")
+			})
+			config := server.Config()
+			config.LLM.ResponseTimeout = tt.timeout
+			config.LLM.TotalAttemptBudget = 1
+
+			_, err := GenerateCommitMessage(config, testDiff("main.go"), "")
+			if got := errors.Is(err, context.DeadlineExceeded); got != tt.wantErr {
+				t.Fatalf("error = %v, want a deadline error: %v", err, tt.wantErr)
+			}
+			// The connection was made, so the time ran out while generating
+			if got := len(server.Requests()); got == 0 {
+				t.Error("the request never reached the server")
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// Go's regexp has no lookahead, so trailing whitespace isn't split off the
+// way tiktoken does it, which only matters for runs of spaces.
+var preTokenizePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// bytesPerToken is the average piece length one token covers for model.
+// Older models use cl100k_base; the o200k_base vocabulary of the newer ones
+// merges longer pieces.
+func bytesPerToken(model openai.ChatModel) int {
+	if strings.HasPrefix(model, "gpt-3.5") || (strings.HasPrefix(model, "gpt-4") && !strings.HasPrefix(model, "gpt-4o")) {
+		return 4
+	}
+	return 5
+}
+
+// CountTokens estimates the number of tokens text takes up for model. It's a
+// heuristic, not a tokenizer: text is split with the BPE pre-tokenization
+// pattern and each piece counts as one token per few bytes, since no merge
+// ranks are bundled, so treat the count as an estimate.
+func CountTokens(model openai.ChatModel, text string) int {
+	perToken := bytesPerToken(model)
+	tokens := 0
+	for _, piece := range preTokenizePattern.FindAllString(text, -1) {
+		tokens += (len(piece) + perToken - 1) / perToken
+	}
+	return tokens
+}
@@ -0,0 +1,29 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestCountTokens(t *testing.T) {
+	tests := []struct {
+		model openai.ChatModel
+		text  string
+		want  int
+	}{
+		{"gpt-4o-mini", "", 0},
+		{"gpt-4o-mini", "hello world", 3},
+		{"gpt-4", "hello world", 4},
+		{"gpt-3.5-turbo", "it's 12345", 5},
+		{"o3-mini", "func main() {}\n", 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model+"/"+tt.text, func(t *testing.T) {
+			if got := CountTokens(tt.model, tt.text); got != tt.want {
+				t.Errorf("CountTokens(%q, %q) = %d, want %d", tt.model, tt.text, got, tt.want)
+			}
+		})
+	}
+}
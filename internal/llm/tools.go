@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+	"github.com/openai/openai-go"
+)
+
+// maxToolRounds bounds how many times the model may call tools before it has
+// to produce its final answer.
+const maxToolRounds = 5
+
+// ToolHandler runs a tool call. args holds the JSON arguments generated by
+// the model and the returned string is sent back as the tool's result.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
+
+// Tool is a function the model may call mid-generation when llm.enableTools
+// is set. Parameters is a JSON schema describing the arguments.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any
+	Handler     ToolHandler
+}
+
+var (
+	toolsMu sync.RWMutex
+	tools   = map[string]Tool{}
+)
+
+func init() {
+	RegisterTool(fileHistoryTool)
+}
+
+// RegisterTool makes a tool available to the model, replacing any tool with
+// the same name.
+func RegisterTool(tool Tool) {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	tools[tool.Name] = tool
+}
+
+// UnregisterTool removes a registered tool.
+func UnregisterTool(name string) {
+	toolsMu.Lock()
+	defer toolsMu.Unlock()
+	delete(tools, name)
+}
+
+func registeredTools() map[string]Tool {
+	toolsMu.RLock()
+	defer toolsMu.RUnlock()
+	registered := make(map[string]Tool, len(tools))
+	for name, tool := range tools {
+		registered[name] = tool
+	}
+	return registered
+}
+
+const fileHistoryLimit = 10
+
+var fileHistoryTool = Tool{
+	Name:        "get_file_history",
+	Description: "Returns the subjects of the most recent commits that touched a file, newest first.",
+	Parameters: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"path": map[string]any{
+				"type":        "string",
+				"description": "Path of the file relative to the repository root.",
+			},
+		},
+		"required":             []string{"path"},
+		"additionalProperties": false,
+	},
+	Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+		var params struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", err
+		}
+		if params.Path == "" {
+			return "", fmt.Errorf("path is required")
+		}
+		return utils.ExecGit("log", "-n", fmt.Sprint(fileHistoryLimit), "--format=%s", "--", params.Path)
+	},
+}
+
+// completeWithTools sends the request and, while the model answers with tool
+// calls, runs them and sends their results back until a final message
+// arrives. Without llm.enableTools it's a single request.
+func completeWithTools(ctx context.Context, client *openai.Client, llmConfig utils.LLMConfig, params openai.ChatCompletionNewParams) (ChatResult[string], error) {
+	available := registeredTools()
+	if llmConfig.EnableTools && len(available) > 0 {
+		toolParams := make([]openai.ChatCompletionToolParam, 0, len(available))
+		for _, tool := range available {
+			toolParams = append(toolParams, openai.ChatCompletionToolParam{
+				Type: openai.F(openai.ChatCompletionToolTypeFunction),
+				Function: openai.F(openai.FunctionDefinitionParam{
+					Name:        openai.F(tool.Name),
+					Description: openai.F(tool.Description),
+					Parameters:  openai.F(openai.FunctionParameters(tool.Parameters)),
+				}),
+			})
+		}
+		params.Tools = openai.F(toolParams)
+	}
+
+	var result ChatResult[string]
+	for round := 0; ; round++ {
+		start := time.Now()
+		resp, rateLimit, err := createCompletion(ctx, client, params)
+		if err != nil {
+			return result, &OpenAIRequestError{Err: err}
+		}
+		result.Cost += reportOpenAIUsage(ctx, llmConfig.Model, false, resp.Usage, rateLimit, start)
+
+		choice, err := firstChoice(resp)
+		if err != nil {
+			return result, err
+		}
+		message := choice.Message
+		if len(message.ToolCalls) == 0 || !params.Tools.Present {
+			result.Message = message.Content
+			result.Logprobs = choice.Logprobs.Content
+			return result, nil
+		}
+
+		messages := append(params.Messages.Value, message)
+		for _, call := range message.ToolCalls {
+			messages = append(messages, openai.ToolMessage(call.ID, runTool(ctx, available, call)))
+		}
+		params.Messages = openai.F(messages)
+		if round+1 == maxToolRounds {
+			// Force a final answer
+			params.ToolChoice = openai.F[openai.ChatCompletionToolChoiceOptionUnionParam](openai.ChatCompletionToolChoiceOptionAutoNone)
+		}
+	}
+}
+
+// runTool executes a tool call. Failures are reported back to the model as
+// the tool's result rather than aborting the generation.
+func runTool(ctx context.Context, available map[string]Tool, call openai.ChatCompletionMessageToolCall) string {
+	tool, ok := available[call.Function.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+	}
+	output, err := tool.Handler(ctx, json.RawMessage(call.Function.Arguments))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return strings.TrimSpace(output)
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func writeToolCall(w http.ResponseWriter, id, name, arguments string) {
+	body := completionBody("")
+	choice := body["choices"].([]map[string]any)[0]
+	choice["finish_reason"] = "tool_calls"
+	choice["message"] = map[string]any{
+		"role":    "assistant",
+		"content": nil,
+		"tool_calls": []map[string]any{{
+			"id":       id,
+			"type":     "function",
+			"function": map[string]any{"name": name, "arguments": arguments},
+		}},
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func TestToolRoundTrip(t *testing.T) {
+	var gotArgs []string
+	RegisterTool(Tool{
+		Name:        "lookup_ticket",
+		Description: "Returns the title of a ticket.",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{"id": map[string]any{"type": "string"}}},
+		Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+			gotArgs = append(gotArgs, string(args))
+			return "  Checkout times out for large carts\n", nil
+		},
+	})
+	t.Cleanup(func() { UnregisterTool("lookup_ticket") })
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		wantCalls int
+	}{
+		{"enabled", true, 2},
+		{"disabled", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotArgs = nil
+			server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+				if n == 0 && req.Raw["tools"] != nil {
+					writeToolCall(w, "call_1", "lookup_ticket", `{"id":"SHOP-42"}`)
+					return
+				}
+				writeCompletion(w, "fix(checkout): raise the cart timeout")
+			})
+			config := server.Config()
+			config.LLM.EnableTools = tt.enabled
+
+			result, err := GenerateCommitMessage(config, testDiff("checkout/cart.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != "fix(checkout): raise the cart timeout\n" {
+				t.Errorf("message = %q", result.Message)
+			}
+
+			requests := server.Requests()
+			if len(requests) != tt.wantCalls {
+				t.Fatalf("sent %d requests, want %d", len(requests), tt.wantCalls)
+			}
+			if got := requests[0].Raw["tools"] != nil; got != tt.enabled {
+				t.Errorf("tools sent: %v, want %v", got, tt.enabled)
+			}
+			if !tt.enabled {
+				if len(gotArgs) != 0 {
+					t.Errorf("tool ran %d times, want never", len(gotArgs))
+				}
+				return
+			}
+
+			if len(gotArgs) != 1 || gotArgs[0] != `{"id":"SHOP-42"}` {
+				t.Errorf("tool args = %q, want one call with the model's arguments", gotArgs)
+			}
+			messages := requests[1].Messages
+			if len(messages) < 2 {
+				t.Fatalf("follow-up has %d messages", len(messages))
+			}
+			call, output := messages[len(messages)-2], messages[len(messages)-1]
+			if call.Role != RoleAssistant {
+				t.Errorf("tool call message role = %q, want assistant", call.Role)
+			}
+			if output.Role != "tool" || output.Text() != "Checkout times out for large carts" {
+				t.Errorf("tool output message = %s %q, want the trimmed tool result", output.Role, output.Text())
+			}
+			if rawMessages, _ := requests[1].Raw["messages"].([]any); len(rawMessages) > 0 {
+				last, _ := rawMessages[len(rawMessages)-1].(map[string]any)
+				if last["tool_call_id"] != "call_1" {
+					t.Errorf("tool_call_id = %v, want call_1", last["tool_call_id"])
+				}
+			}
+		})
+	}
+}
+
+func TestRunTool(t *testing.T) {
+	available := map[string]Tool{
+		"fails": {Name: "fails", Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+			return "", errors.New("no such ticket")
+		}},
+	}
+
+	tests := []struct {
+		name string
+		tool string
+		want string
+	}{
+		{"handler error", "fails", "error: no such ticket"},
+		{"unknown tool", "missing", `error: unknown tool "missing"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := openai.ChatCompletionMessageToolCall{
+				ID:       "call_1",
+				Function: openai.ChatCompletionMessageToolCallFunction{Name: tt.tool, Arguments: "{}"},
+			}
+			if got := runTool(context.Background(), available, call); got != tt.want {
+				t.Errorf("runTool() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolsNoChoices(t *testing.T) {
+	server := newMockOpenAI(t, func(w http.ResponseWriter, n int, req chatRequest) {
+		body := completionBody("")
+		body["choices"] = []any{}
+		writeJSON(w, http.StatusOK, body)
+	})
+	llmConfig := server.LLMConfig()
+	llmConfig.EnableTools = true
+
+	_, err := chat(context.Background(), llmConfig, "prompt")
+	var reqErr *OpenAIRequestError
+	if !errors.As(err, &reqErr) || !strings.Contains(err.Error(), "no choices") {
+		t.Errorf("error = %v, want an OpenAIRequestError about the missing choices", err)
+	}
+}
@@ -0,0 +1,122 @@
+package llm
+
+import (
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// baseTransport unwraps the transport built by newHTTPClient.
+func baseTransport(t *testing.T, client *http.Client) *http.Transport {
+	t.Helper()
+	ua, ok := client.Transport.(userAgentTransport)
+	if !ok {
+		t.Fatalf("transport is %T, want userAgentTransport", client.Transport)
+	}
+	budget, ok := ua.base.(budgetTransport)
+	if !ok {
+		t.Fatalf("transport is %T, want budgetTransport", ua.base)
+	}
+	transport, ok := budget.base.(*http.Transport)
+	if !ok {
+		t.Fatalf("transport is %T, want *http.Transport", budget.base)
+	}
+	return transport
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	client, err := newHTTPClient(utils.LLMConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := baseTransport(t, client).Proxy
+	if proxy == nil || reflect.ValueOf(proxy).Pointer() != reflect.ValueOf(http.ProxyFromEnvironment).Pointer() {
+		t.Error("transport doesn't use the proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY")
+	}
+}
+
+func TestHTTPClientCACert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, pemBytes, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		caCertFile string
+		wantErr    error
+		wantTrust  bool
+	}{
+		{"custom CA trusted", bundle, nil, true},
+		{"system roots only", "", nil, false},
+		{"missing file", filepath.Join(dir, "missing.pem"), fs.ErrNotExist, false},
+		{"no certificates", notPEM, ErrInvalidCACert, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newHTTPClient(utils.LLMConfig{CACertFile: tt.caCertFile})
+			if tt.wantErr != nil {
+				var caErr CACertError
+				if !errors.Is(err, tt.wantErr) || !errors.As(err, &caErr) || caErr.Path != tt.caCertFile {
+					t.Fatalf("newHTTPClient() error = %v, want a CACertError for %s", err, tt.caCertFile)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Get(server.URL)
+			if err == nil {
+				resp.Body.Close()
+			}
+			if got := err == nil; got != tt.wantTrust {
+				t.Errorf("request to the TLS server succeeded: %v, want %v (error: %v)", got, tt.wantTrust, err)
+			}
+		})
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		want     string
+	}{
+		{"default", "", "kommit/" + Version},
+		{"override", "acme-gateway/1.0", "acme-gateway/1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.LLM.UserAgent = tt.override
+
+			if _, err := GenerateCommitMessage(config, testDiff("x.go"), ""); err != nil {
+				t.Fatal(err)
+			}
+			if got := server.Requests()[0].Header.Get("User-Agent"); got != tt.want {
+				t.Errorf("User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package llm
+
+import (
+	"slices"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+// validateCommitMessage rejects generated messages that break hard rules from
+// the config.
+func validateCommitMessage(commit utils.CommitConfig, message string) error {
+	subject, _ := utils.SplitCommitMessage(message)
+	header, ok := utils.ParseCommitHeader(subject)
+	if !ok && commit.HeaderOnlyOnSubject {
+		return MissingHeaderError{Subject: subject}
+	}
+	if !ok {
+		return nil
+	}
+
+	if slices.Contains(commit.DeniedTypes, header.Type) {
+		return DeniedTypeError{Type: header.Type}
+	}
+	if commit.StrictValidation && !slices.Contains(commit.AllowedTypes(), header.Type) {
+		return UnknownTypeError{Type: header.Type}
+	}
+	return nil
+}
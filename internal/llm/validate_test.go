@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/utils"
+)
+
+func TestValidateCommitMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		commit  utils.CommitConfig
+		message string
+		wantErr error
+	}{
+		{
+			name:    "denied type",
+			commit:  utils.CommitConfig{Types: []string{"feat", "perf"}, DeniedTypes: []string{"perf"}},
+			message: "perf: cache lookups\n",
+			wantErr: DeniedTypeError{Type: "perf"},
+		},
+		{
+			name:    "allowed type",
+			commit:  utils.CommitConfig{Types: []string{"feat", "perf"}, DeniedTypes: []string{"perf"}},
+			message: "feat: cache lookups\n",
+		},
+		{
+			name:    "not a conventional commit",
+			commit:  utils.CommitConfig{DeniedTypes: []string{"perf"}},
+			message: "Cache lookups\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCommitMessage(tt.commit, tt.message)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("validateCommitMessage() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) || err != tt.wantErr {
+				t.Errorf("validateCommitMessage() = %#v, want %#v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeniedTypesPrompt(t *testing.T) {
+	config := &utils.Config{Commit: utils.CommitConfig{Types: []string{"feat", "fix", "perf", "ci"}, DeniedTypes: []string{"perf", "ci"}}}
+	prompt, err := commitPrompt(config, testDiff("main.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"- **Allowed commit types**:\n  - `feat`, `fix`\n",
+		"- **Never use these commit types, even if they seem to fit**:\n  - `perf`, `ci`\n",
+	} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt doesn't contain %q", want)
+		}
+	}
+}
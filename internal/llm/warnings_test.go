@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cowboy-bebug/kommit/internal/models"
+)
+
+func TestModelWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		suppress bool
+		want     bool
+	}{
+		{"low-tier model", models.OpenAIModelGPT35Turbo, false, true},
+		{"recommended model", models.OpenAIModelGPT4oMini, false, false},
+		{"suppressed", models.OpenAIModelGPT35Turbo, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("fix: handle nil"))
+			config := server.Config()
+			config.LLM.Model = tt.model
+			config.LLM.SuppressModelWarnings = tt.suppress
+
+			result, err := GenerateCommitMessage(config, testDiff("main.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			warned := false
+			for _, warning := range result.Warnings {
+				warned = warned || strings.Contains(warning, "consider upgrading to "+models.OpenAIModelGPT4oMini)
+			}
+			if warned != tt.want {
+				t.Errorf("warnings = %q, want model warning: %v", result.Warnings, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscouragedTypes(t *testing.T) {
+	const guidance = "- **Avoid these commit types and only use them as a last resort, when no other type fits**:\n"
+
+	tests := []struct {
+		name        string
+		discouraged []string
+		forced      string
+		reply       string
+		wantWarning bool
+	}{
+		{"discouraged type chosen", []string{"wip"}, "", "wip: save progress", true},
+		{"other type chosen", []string{"wip"}, "", "feat: add x", false},
+		{"discouraged type forced", []string{"wip"}, "wip", "wip: save progress", false},
+		{"none configured", nil, "", "wip: save progress", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith(tt.reply))
+			config := server.Config()
+			config.Commit.Types = append(append([]string(nil), testTypes...), "wip")
+			config.Commit.DiscouragedTypes = tt.discouraged
+			config.Commit.ForcedType = tt.forced
+
+			result, err := GenerateCommitMessage(config, testDiff("main.go"), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			prompt := server.Requests()[0].LastUser()
+			if got, want := strings.Contains(prompt, guidance+"  - `wip`\n"), len(tt.discouraged) > 0; got != want {
+				t.Errorf("prompt has the discouraged guidance: %v, want %v", got, want)
+			}
+			warned := false
+			for _, warning := range result.Warnings {
+				warned = warned || warning == "wip is a discouraged commit type, consider whether another type fits"
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("warnings = %q, want discouraged warning: %v", result.Warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestWarnDiffBytes(t *testing.T) {
+	small := testDiff("main.go")
+	large := testDiff("main.go") + "+" + strings.Repeat("x", 2000) + "\n"
+
+	tests := []struct {
+		name  string
+		limit int
+		diff  string
+		want  bool
+	}{
+		{"large diff", 1000, large, true},
+		{"small diff", 1000, small, false},
+		{"disabled", 0, large, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newMockOpenAI(t, replyWith("feat: add x"))
+			config := server.Config()
+			config.Commit.WarnDiffBytes = tt.limit
+
+			result, err := GenerateCommitMessage(config, tt.diff, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Message != "feat: add x\n" {
+				t.Errorf("message = %q, the warning mustn't block generation", result.Message)
+			}
+			warned := false
+			for _, warning := range result.Warnings {
+				warned = warned || strings.Contains(warning, "more than commit.warnDiffBytes (1000)")
+			}
+			if warned != tt.want {
+				t.Errorf("warnings = %q, want diff size warning: %v", result.Warnings, tt.want)
+			}
+		})
+	}
+}
@@ -7,15 +7,25 @@ import (
 )
 
 const (
-	OpenAIModelGPT4oMini openai.ChatModel = openai.ChatModelGPT4oMini
-	OpenAIModelGPT4o     openai.ChatModel = openai.ChatModelGPT4o
-	OpenAIModelO3Mini    openai.ChatModel = openai.ChatModelO3Mini
+	OpenAIModelGPT4oMini  openai.ChatModel = openai.ChatModelGPT4oMini
+	OpenAIModelGPT4o      openai.ChatModel = openai.ChatModelGPT4o
+	OpenAIModelO3Mini     openai.ChatModel = openai.ChatModelO3Mini
+	OpenAIModelGPT35Turbo openai.ChatModel = openai.ChatModelGPT3_5Turbo
 )
 
 func IsSupportedModel(model openai.ChatModel) bool {
 	return slices.Contains(OpenAISupportedModels, model)
 }
 
+// Models that work but tend to produce poor commit messages
+var OpenAILowQualityModels = []openai.ChatModel{
+	OpenAIModelGPT35Turbo,
+}
+
+func IsLowQualityModel(model openai.ChatModel) bool {
+	return slices.Contains(OpenAILowQualityModels, model)
+}
+
 type Cost float64
 
 // https://openai.com/api/pricing/
@@ -32,6 +42,10 @@ const (
 	OpenAIModelO3MiniInputCostPerToken       Cost = 1.10 * 1e-6
 	OpenAIModelO3MiniCachedInputCostPerToken Cost = 0.55 * 1e-6
 	OpenAIModelO3MiniOutputCostPerToken      Cost = 4.40 * 1e-6
+	// GPT-3.5 Turbo
+	OpenAIModelGPT35TurboInputCostPerToken       Cost = 0.50 * 1e-6
+	OpenAIModelGPT35TurboCachedInputCostPerToken Cost = 0.50 * 1e-6
+	OpenAIModelGPT35TurboOutputCostPerToken      Cost = 1.50 * 1e-6
 )
 
 type CostPerToken struct {
@@ -56,12 +70,18 @@ var OpenAIModelCosts = map[openai.ChatModel]CostPerToken{
 		CachedInput: OpenAIModelO3MiniCachedInputCostPerToken,
 		Output:      OpenAIModelO3MiniOutputCostPerToken,
 	},
+	OpenAIModelGPT35Turbo: {
+		Input:       OpenAIModelGPT35TurboInputCostPerToken,
+		CachedInput: OpenAIModelGPT35TurboCachedInputCostPerToken,
+		Output:      OpenAIModelGPT35TurboOutputCostPerToken,
+	},
 }
 
 var OpenAISupportedModels = []openai.ChatModel{
 	OpenAIModelGPT4oMini,
 	OpenAIModelGPT4o,
 	OpenAIModelO3Mini,
+	OpenAIModelGPT35Turbo,
 }
 
 func EstimateCost(model openai.ChatModel, usage openai.CompletionUsage) Cost {
@@ -0,0 +1,91 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type MessageSelector struct {
+	choices  []string
+	cursor   int
+	selected string
+	quit     bool
+}
+
+func NewMessageSelector(messages []string) *MessageSelector {
+	return &MessageSelector{
+		choices: messages,
+		cursor:  0,
+		quit:    false,
+	}
+}
+
+func (m *MessageSelector) Init() tea.Cmd {
+	return nil
+}
+
+func (m *MessageSelector) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c", "q":
+			m.quit = true
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.choices)-1 {
+				m.cursor++
+			}
+		case "enter", " ":
+			m.selected = m.choices[m.cursor]
+			return m, tea.Quit
+		}
+	}
+	return m, nil
+}
+
+func (m *MessageSelector) View() string {
+	s := TitleStyle.Render("🤔 Your therapist has a few interpretations. Which one feels right?") + "\n"
+
+	for i, choice := range m.choices {
+		cursor := " "
+		style := ItemStyle
+
+		if i == m.cursor {
+			cursor = ">"
+			style = SelectedItemStyle
+		}
+
+		// Only the subject line fits in the list
+		subject, _, _ := strings.Cut(choice, "\n")
+		s += cursor + " " + style.Render(subject) + "\n"
+	}
+
+	return WrapWithKeyboardHelp(s, WithStandardNavigation())
+}
+
+func SelectMessage(messages []string) (string, error) {
+	model := NewMessageSelector(messages)
+	p := tea.NewProgram(model)
+
+	_, err := p.Run()
+	if err != nil {
+		return "", err
+	}
+
+	if model.quit {
+		return "", QuitError{}
+	}
+
+	// Clear the help lines
+	for range 3 {
+		fmt.Print("\033[1A\033[2K")
+	}
+
+	return model.selected, nil
+}
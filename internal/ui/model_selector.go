@@ -15,8 +15,15 @@ type ModelSelector struct {
 }
 
 func NewModelSelector() *ModelSelector {
+	var choices []string
+	for _, model := range models.OpenAISupportedModels {
+		if !models.IsLowQualityModel(model) {
+			choices = append(choices, model)
+		}
+	}
+
 	return &ModelSelector{
-		choices: models.OpenAISupportedModels,
+		choices: choices,
 		cursor:  0,
 		quit:    false,
 	}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+// AuditRecord is what gets stored for every generation when auditing is
+// enabled. RawResponse is the model's first answer before kommit formatted it
+// into Response, and Exchanges lists every request of the generation,
+// including re-prompts, the proofreading pass and alternatives.
+type AuditRecord struct {
+	Time             time.Time       `json:"time"`
+	Provider         string          `json:"provider"`
+	Model            string          `json:"model"`
+	DiffFingerprint  string          `json:"diffFingerprint"`
+	SystemPrompt     string          `json:"systemPrompt"`
+	Prompt           string          `json:"prompt"`
+	Response         string          `json:"response"`
+	RawResponse      string          `json:"rawResponse"`
+	Exchanges        []AuditExchange `json:"exchanges"`
+	PromptTokens     int64           `json:"promptTokens"`
+	CompletionTokens int64           `json:"completionTokens"`
+	Cost             float64         `json:"cost"`
+	Error            string          `json:"error,omitempty"`
+}
+
+// AuditExchange is one request sent to the model and its raw reply.
+type AuditExchange struct {
+	Messages []AuditMessage `json:"messages"`
+	Response string         `json:"response"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// AuditMessage is one turn of a request.
+type AuditMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+func auditDirpath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dir, "kommit", "audit")
+}
+
+// WriteAuditRecord stores the record as a timestamped JSON file in the
+// configured audit directory, masking the prompts and response with the
+// privacy redaction patterns first. It returns the path of the written file.
+func WriteAuditRecord(config *Config, record AuditRecord) (string, error) {
+	dir := config.Audit.Dir
+	if dir == "" {
+		dir = auditDirpath()
+	}
+	if dir == "" {
+		return "", fmt.Errorf("could not determine audit directory")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create audit directory: %w", err)
+	}
+
+	patterns := config.Privacy.RedactPatterns
+	record.SystemPrompt = Redact(record.SystemPrompt, patterns)
+	record.Prompt = Redact(record.Prompt, patterns)
+	record.Response = Redact(record.Response, patterns)
+	record.RawResponse = Redact(record.RawResponse, patterns)
+	exchanges := make([]AuditExchange, len(record.Exchanges))
+	for i, exchange := range record.Exchanges {
+		messages := make([]AuditMessage, len(exchange.Messages))
+		for j, message := range exchange.Messages {
+			messages[j] = AuditMessage{Role: message.Role, Content: Redact(message.Content, patterns)}
+		}
+		exchanges[i] = AuditExchange{Messages: messages, Response: Redact(exchange.Response, patterns), Error: exchange.Error}
+	}
+	record.Exchanges = exchanges
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	path := filepath.Join(dir, record.Time.UTC().Format("20060102T150405.000000000Z")+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return path, nil
+}
+
+// Redact replaces every match of the given regular expressions in text with
+// a placeholder. Invalid patterns are skipped; Validate reports them.
+func Redact(text string, patterns []string) string {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		text = re.ReplaceAllString(text, redactedPlaceholder)
+	}
+	return text
+}
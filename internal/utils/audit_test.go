@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteAuditRecord(t *testing.T) {
+	config := &Config{
+		Audit:   AuditConfig{Enabled: true, Dir: t.TempDir()},
+		Privacy: PrivacyConfig{RedactPatterns: []string{`sk-[a-z0-9]+`, `[`}},
+	}
+	record := AuditRecord{
+		Time:        time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		Provider:    ProviderOpenAI,
+		Model:       "gpt-4o-mini",
+		Prompt:      "+token = sk-abc123\n",
+		Response:    "fix: rotate sk-abc123",
+		RawResponse: "fix: rotate sk-abc123.",
+		Exchanges: []AuditExchange{
+			{Messages: []AuditMessage{{Role: "user", Content: "+token = sk-abc123\n"}}, Response: "fix: rotate sk-abc123."},
+			{Messages: []AuditMessage{{Role: "user", Content: "proofread sk-abc123"}}, Error: "request failed"},
+		},
+		PromptTokens:     10,
+		CompletionTokens: 5,
+	}
+
+	path, err := WriteAuditRecord(config, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, config.Audit.Dir) || !strings.HasSuffix(path, "20240501T123000.000000000Z.json") {
+		t.Errorf("path = %q", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got AuditRecord
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := record
+	want.Prompt = "+token = [REDACTED]\n"
+	want.Response = "fix: rotate [REDACTED]"
+	want.RawResponse = "fix: rotate [REDACTED]."
+	want.Exchanges = []AuditExchange{
+		{Messages: []AuditMessage{{Role: "user", Content: "+token = [REDACTED]\n"}}, Response: "fix: rotate [REDACTED]."},
+		{Messages: []AuditMessage{{Role: "user", Content: "proofread [REDACTED]"}}, Error: "request failed"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("record = %+v, want %+v", got, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		patterns []string
+		want     string
+	}{
+		{"match", "key=AKIA1234 and AKIA5678", []string{`AKIA[0-9]+`}, "key=[REDACTED] and [REDACTED]"},
+		{"no match", "nothing here", []string{`AKIA[0-9]+`}, "nothing here"},
+		{"invalid pattern skipped", "key=AKIA1234", []string{`(`, `AKIA[0-9]+`}, "key=[REDACTED]"},
+		{"no patterns", "key=AKIA1234", nil, "key=AKIA1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Redact(tt.text, tt.patterns); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
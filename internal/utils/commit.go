@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var commitHeaderRegex = regexp.MustCompile(`^(\w+)(?:\(([^()]*)\))?(!)?: (.+)$`)
+
+type CommitHeader struct {
+	Type        string
+	Scope       string
+	Breaking    bool
+	Description string
+}
+
+// ParseCommitHeader parses a Conventional Commit subject line such as
+// `feat(ui)!: add dark mode`. The second return value is false when the line
+// doesn't follow the format.
+func ParseCommitHeader(subject string) (CommitHeader, bool) {
+	matches := commitHeaderRegex.FindStringSubmatch(strings.TrimSpace(subject))
+	if matches == nil {
+		return CommitHeader{}, false
+	}
+
+	return CommitHeader{
+		Type:        matches[1],
+		Scope:       matches[2],
+		Breaking:    matches[3] == "!",
+		Description: matches[4],
+	}, true
+}
+
+// String renders the header back into a subject line, leaving out the
+// parentheses when there is no scope.
+func (h CommitHeader) String() string {
+	header := h.Type
+	if h.Scope != "" {
+		header += "(" + h.Scope + ")"
+	}
+	if h.Breaking {
+		header += "!"
+	}
+	return header + ": " + h.Description
+}
+
+// SplitCommitMessage separates the subject line from the rest of the message.
+func SplitCommitMessage(message string) (string, string) {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(subject), strings.TrimSpace(body)
+}
+
+const (
+	VersionBumpMajor = "major"
+	VersionBumpMinor = "minor"
+	VersionBumpPatch = "patch"
+)
+
+// RecommendVersionBump maps a set of Conventional Commits to the SemVer bump
+// they warrant: breaking changes bump major, feat bumps minor and fix bumps
+// patch, and the highest bump wins. It returns "" when no commit warrants a
+// release, e.g. only docs or chores. Messages that don't follow the format are
+// ignored, but an error is returned if none of them do.
+func RecommendVersionBump(commitMessages []string) (string, error) {
+	bump := ""
+	parsed := 0
+	for _, message := range commitMessages {
+		subject, body := SplitCommitMessage(message)
+		header, ok := ParseCommitHeader(subject)
+		if !ok {
+			continue
+		}
+		parsed++
+
+		switch {
+		case header.Breaking || hasBreakingChangeFooter(body):
+			return VersionBumpMajor, nil
+		case header.Type == "feat":
+			bump = VersionBumpMinor
+		case header.Type == "fix" && bump == "":
+			bump = VersionBumpPatch
+		}
+	}
+
+	if parsed == 0 && len(commitMessages) > 0 {
+		return "", fmt.Errorf("none of the %d commit messages follow the Conventional Commits format", len(commitMessages))
+	}
+	return bump, nil
+}
+
+func hasBreakingChangeFooter(body string) bool {
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			return true
+		}
+	}
+	return false
+}
+
+// NonConformingType is the key under which AnalyzeTypeDistribution tallies
+// subjects that don't follow the Conventional Commits format.
+const NonConformingType = ""
+
+// AnalyzeTypeDistribution counts how often each commit type is used in a
+// history of subject lines, e.g. to suggest the allowed types for a repo.
+func AnalyzeTypeDistribution(commitSubjects []string) map[string]int {
+	counts := make(map[string]int)
+	for _, subject := range commitSubjects {
+		if strings.TrimSpace(subject) == "" {
+			continue
+		}
+		if header, ok := ParseCommitHeader(subject); ok {
+			counts[header.Type]++
+		} else {
+			counts[NonConformingType]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecommendVersionBump(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		want     string
+		wantErr  bool
+	}{
+		{"patch", []string{"fix: handle nil config", "docs: update README"}, VersionBumpPatch, false},
+		{"minor", []string{"feat(api): add orders endpoint"}, VersionBumpMinor, false},
+		{"major from bang", []string{"refactor(api)!: drop v1 routes"}, VersionBumpMajor, false},
+		{"major from footer", []string{"feat: rework auth\n\nBREAKING CHANGE: tokens expire sooner"}, VersionBumpMajor, false},
+		{"major from hyphenated footer", []string{"fix: tighten parsing\n\nBREAKING-CHANGE: rejects tabs"}, VersionBumpMajor, false},
+		{"highest bump wins", []string{"fix: a", "feat: b", "fix: c", "chore: d"}, VersionBumpMinor, false},
+		{"breaking wins over everything", []string{"feat: a", "fix!: b", "fix: c"}, VersionBumpMajor, false},
+		{"no release", []string{"docs: a", "chore(deps): bump x", "ci: c"}, "", false},
+		{"non-conforming messages ignored", []string{"WIP", "fix: a"}, VersionBumpPatch, false},
+		{"no conforming messages", []string{"WIP", "fixed stuff"}, "", true},
+		{"no messages", nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RecommendVersionBump(tt.messages)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RecommendVersionBump() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("RecommendVersionBump() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeTypeDistribution(t *testing.T) {
+	tests := []struct {
+		name     string
+		subjects []string
+		want     map[string]int
+	}{
+		{
+			name: "mixed history",
+			subjects: []string{
+				"feat(api): add orders",
+				"fix: handle nil",
+				"feat!: drop v1",
+				"Merge branch 'main'",
+				"fix(ui): align buttons",
+				"update stuff",
+				"docs: fix typo",
+				"",
+			},
+			want: map[string]int{"feat": 2, "fix": 2, "docs": 1, NonConformingType: 2},
+		},
+		{
+			name:     "only non-conforming",
+			subjects: []string{"WIP", "oops"},
+			want:     map[string]int{NonConformingType: 2},
+		},
+		{
+			name:     "empty history",
+			subjects: nil,
+			want:     map[string]int{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AnalyzeTypeDistribution(tt.subjects); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AnalyzeTypeDistribution() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/cowboy-bebug/kommit/internal/models"
 	"github.com/spf13/viper"
@@ -21,6 +23,20 @@ func GetConfigPath() (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
+// GlobalConfigFilePath returns the user-wide config that repo configs are
+// layered on top of, or "" if the home directory is unknown.
+func GlobalConfigFilePath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(homeDir, ".config")
+	}
+	return filepath.Join(dir, "kommit", "config.yaml")
+}
+
 func GetConfigFilePath() (string, error) {
 	configPath, err := GetConfigPath()
 	if err != nil {
@@ -37,18 +53,303 @@ func unmarshalConfig(v *viper.Viper) (*Config, error) {
 	return &config, nil
 }
 
+const (
+	ProviderOpenAI  = "openai"
+	ProviderBedrock = "bedrock"
+)
+
 type LLMConfig struct {
-	Model string `mapstructure:"model"`
+	Provider string `mapstructure:"provider"`
+	Model    string `mapstructure:"model"`
+	Region   string `mapstructure:"region"`
+	BaseURL  string `mapstructure:"baseURL"`
+	// Endpoints are OpenAI-compatible base URLs to choose the fastest one
+	// from, see llm.SelectFastestEndpoint. BaseURL is used if none answers
+	Endpoints []string `mapstructure:"endpoints"`
+	// ModelAliases maps short names such as "fast" to real model names
+	ModelAliases map[string]string `mapstructure:"modelAliases"`
+	// CACertFile is a PEM bundle trusted in addition to the system roots,
+	// e.g. for a TLS-intercepting proxy
+	CACertFile      string   `mapstructure:"caCertFile"`
+	Temperature     *float64 `mapstructure:"temperature"`
+	MaxRequestBytes int      `mapstructure:"maxRequestBytes"`
+	// TotalAttemptBudget caps the API calls, retries included, made for one
+	// commit message
+	TotalAttemptBudget int    `mapstructure:"totalAttemptBudget"`
+	UserID             string `mapstructure:"userId"`
+	// ConnectTimeout bounds the connection setup (dial and TLS handshake),
+	// ResponseTimeout each request once sent, e.g. `2s` and `1m`; zero keeps
+	// the defaults
+	ConnectTimeout  time.Duration `mapstructure:"connectTimeout"`
+	ResponseTimeout time.Duration `mapstructure:"responseTimeout"`
+	// UserAgent replaces the default `kommit/<version>` User-Agent
+	UserAgent string `mapstructure:"userAgent"`
+	// OmitParams drops request parameters that some OpenAI-compatible
+	// servers reject, by their JSON name, e.g. `top_p` or `frequency_penalty`
+	OmitParams []string `mapstructure:"omitParams"`
+	// UseKeyring reads the API key from the OS keychain when it isn't set in
+	// the environment; service and account default to "kommit" and "openai"
+	UseKeyring     bool   `mapstructure:"useKeyring"`
+	KeyringService string `mapstructure:"keyringService"`
+	KeyringAccount string `mapstructure:"keyringAccount"`
+
+	SuppressModelWarnings bool `mapstructure:"suppressModelWarnings"`
+	// EnableTools lets the model call registered tools such as
+	// get_file_history while generating
+	EnableTools bool `mapstructure:"enableTools"`
+	// SchemaName and StrictSchema tune the JSON schema of structured calls;
+	// StrictSchema defaults to true
+	SchemaName      string `mapstructure:"schemaName"`
+	StrictSchema    *bool  `mapstructure:"strictSchema"`
+	IncludeLogprobs bool   `mapstructure:"includeLogprobs"`
+	TopLogprobs     int    `mapstructure:"topLogprobs"`
+}
+
+// ResolveModel returns the model an alias stands for. Names that aren't
+// aliases are returned unchanged.
+func (c LLMConfig) ResolveModel(model string) string {
+	if resolved, ok := c.ModelAliases[model]; ok && resolved != "" {
+		return resolved
+	}
+	return model
+}
+
+type BulletStyle string
+
+const (
+	BulletStyleDash     BulletStyle = "dash"
+	BulletStyleAsterisk BulletStyle = "asterisk"
+	BulletStyleNumbered BulletStyle = "numbered"
+)
+
+// FixupMode makes kommit write a git autosquash message for the previous
+// commit instead of generating one.
+type FixupMode string
+
+const (
+	FixupModeFixup  FixupMode = "fixup"
+	FixupModeSquash FixupMode = "squash"
+)
+
+// RenderProfile adapts the final message to where it will be read.
+type RenderProfile string
+
+const (
+	RenderProfileGitHub RenderProfile = "github"
+	RenderProfileGitLab RenderProfile = "gitlab"
+	RenderProfilePlain  RenderProfile = "plain"
+)
+
+type Verbosity string
+
+const (
+	VerbosityTerse   Verbosity = "terse"
+	VerbosityNormal  Verbosity = "normal"
+	VerbosityVerbose Verbosity = "verbose"
+)
+
+type ScopeDerivation string
+
+const (
+	// ScopeDerivationHint suggests the path-derived scope to the model
+	ScopeDerivationHint ScopeDerivation = "hint"
+	// ScopeDerivationAuthoritative overwrites the generated scope with it
+	ScopeDerivationAuthoritative ScopeDerivation = "authoritative"
+)
+
+type MergeStrategy string
+
+const (
+	MergeStrategyReplace MergeStrategy = "replace"
+	MergeStrategyUnion   MergeStrategy = "union"
+)
+
+// PathScopeRule maps files matching a glob to a scope. `**` matches across
+// directories, and a pattern matching a directory covers everything below it.
+type PathScopeRule struct {
+	Pattern string `mapstructure:"pattern"`
+	Scope   string `mapstructure:"scope"`
+}
+
+// CommitExample is a hand-picked commit message for a diff, shown to the
+// model as a few-shot example.
+type CommitExample struct {
+	Diff    string `mapstructure:"diff"`
+	Message string `mapstructure:"message"`
 }
 
 type CommitConfig struct {
-	Types  []string `mapstructure:"types"`
-	Scopes []string `mapstructure:"scopes"`
+	Types []string `mapstructure:"types"`
+	// TypesMergeStrategy decides whether repo types replace the global
+	// config's types (the default) or are added to them
+	TypesMergeStrategy MergeStrategy `mapstructure:"typesMergeStrategy"`
+	// TypeDescriptions teach the model what custom types mean, e.g.
+	// `spike: exploratory work`
+	TypeDescriptions map[string]string `mapstructure:"typeDescriptions"`
+	DeniedTypes      []string          `mapstructure:"deniedTypes"`
+	// StrictValidation rejects generated messages whose type isn't one of
+	// the allowed types, instead of only the denied ones
+	StrictValidation bool `mapstructure:"strictValidation"`
+	// DiscouragedTypes stay allowed but are only used as a last resort
+	DiscouragedTypes []string `mapstructure:"discouragedTypes"`
+	// ForcedType pins the commit type; the model only fills in the rest
+	ForcedType   string   `mapstructure:"forcedType"`
+	Scopes       []string `mapstructure:"scopes"`
+	RequireScope bool     `mapstructure:"requireScope"`
+	// DepsScope is the scope of the messages written for dependency-only
+	// diffs, see utils.IsDependencyOnly; defaults to deps
+	DepsScope string `mapstructure:"depsScope"`
+	// MaxInferredScopes caps the scopes `kommit init` suggests, keeping the
+	// ones most files map to; zero means unlimited. Since init runs before
+	// the repo has a config, it is read from the global config, and
+	// --max-scopes overrides it
+	MaxInferredScopes int `mapstructure:"maxInferredScopes"`
+	// SingleWordScope asks for one-word scopes and collapses longer ones to
+	// their first significant word
+	SingleWordScope bool `mapstructure:"singleWordScope"`
+	// DeriveScopeFromPath uses utils.ScopeFromPaths when all changed files
+	// share a scope, preferring utils.ScopeFromGoPackages for Go changes
+	DeriveScopeFromPath ScopeDerivation `mapstructure:"deriveScopeFromPath"`
+	// PathScopeRules are tried in order and the first matching rule wins.
+	// They take precedence over the model and DeriveScopeFromPath.
+	PathScopeRules []PathScopeRule `mapstructure:"pathScopeRules"`
+
+	// Generation
+	DetectReverts      bool               `mapstructure:"detectReverts"`
+	DetectFormatOnly   bool               `mapstructure:"detectFormatOnly"`
+	TemperatureByType  map[string]float64 `mapstructure:"temperatureByType"`
+	MaxRefinementTurns int                `mapstructure:"maxRefinementTurns"`
+	// Explain asks the model why it chose the type and scope, see
+	// llm.ChatResult.Rationale
+	Explain bool `mapstructure:"explain"`
+	// Fixup skips generation and writes `fixup! <previous subject>`, or
+	// `squash! <previous subject>`, for git rebase --autosquash
+	Fixup FixupMode `mapstructure:"fixup"`
+	// PrimeResponse starts the model's reply with the forced type, e.g.
+	// `feat(`, for models that struggle with the format
+	PrimeResponse bool `mapstructure:"primeResponse"`
+	// ProofreadPass spends an extra call fixing typos in the message,
+	// keeping its structure as is
+	ProofreadPass bool `mapstructure:"proofreadPass"`
+	// MinConfidence below which alternatives are generated, in [0, 1]
+	MinConfidence float64 `mapstructure:"minConfidence"`
+	// Examples are included in every prompt, oldest first. The oldest ones
+	// are dropped when they exceed ExampleTokenBudget (default 2000)
+	Examples           []CommitExample `mapstructure:"examples"`
+	ExampleTokenBudget int             `mapstructure:"exampleTokenBudget"`
+	// HistoryExamples adds that many recent commit subjects from git log as
+	// style examples. HistoryRecencyBias in [0, 1] (default 0.8) skews the
+	// selection towards recent commits; 0 samples evenly across history
+	HistoryExamples    int      `mapstructure:"historyExamples"`
+	HistoryRecencyBias *float64 `mapstructure:"historyRecencyBias"`
+	// PromptTemplate replaces the built-in prompt, see llm.PromptData
+	PromptTemplate string `mapstructure:"promptTemplate"`
+	// Glossary explains project terms and acronyms to the model, e.g.
+	// `pdp: product detail page`. Terms are read lowercased
+	Glossary map[string]string `mapstructure:"glossary"`
+
+	// Formatting
+	// Language the messages are written in, as a BCP 47 tag (default en).
+	// It selects the imperative mood check; other languages aren't checked
+	Language string `mapstructure:"language"`
+	// Verbosity controls how much detail goes into the body: terse drops it,
+	// verbose asks for rationale as well
+	Verbosity   Verbosity   `mapstructure:"verbosity"`
+	BulletStyle BulletStyle `mapstructure:"bulletStyle"`
+	// RenderProfile applies a final formatting pass for github (the
+	// default, left as is), gitlab or plain git
+	RenderProfile RenderProfile `mapstructure:"renderProfile"`
+	// IncludeRationale asks for a final "Why:" bullet with the motivation
+	IncludeRationale bool `mapstructure:"includeRationale"`
+	// MaxBodyBullets caps the number of body bullets; zero means unlimited
+	MaxBodyBullets int `mapstructure:"maxBodyBullets"`
+	// DropRedundantBody drops a single-bullet body that merely restates the
+	// subject, i.e. whose word overlap with it is at least
+	// RedundantBodyThreshold (default 0.8)
+	DropRedundantBody      bool    `mapstructure:"dropRedundantBody"`
+	RedundantBodyThreshold float64 `mapstructure:"redundantBodyThreshold"`
+	// MaxBodyLines caps the number of body lines, however many bullets they
+	// belong to; zero means unlimited
+	MaxBodyLines int `mapstructure:"maxBodyLines"`
+	// MaxTotalLength caps the whole message in bytes; zero means unlimited
+	MaxTotalLength int `mapstructure:"maxTotalLength"`
+	// NoSubjectPeriod strips a trailing period from the subject; defaults to
+	// true
+	NoSubjectPeriod *bool `mapstructure:"noSubjectPeriod"`
+	ListFilesInBody bool  `mapstructure:"listFilesInBody"`
+	// HeaderOnlyOnSubject requires the subject to be a Conventional Commits
+	// header and strips header-like prefixes such as `fix: ` from body lines,
+	// for parsers that would mistake them for another commit
+	HeaderOnlyOnSubject bool `mapstructure:"headerOnlyOnSubject"`
+	// TicketRefs appends a `Refs:` footer with the ticket references found
+	// in the added lines, see utils.ExtractTicketRefs
+	TicketRefs            bool     `mapstructure:"ticketRefs"`
+	TicketPatterns        []string `mapstructure:"ticketPatterns"`
+	PreserveRawFormatting bool     `mapstructure:"preserveRawFormatting"`
+
+	// Diff
+	// ContextLines is passed to `git diff -U`; zero keeps git's default
+	ContextLines int `mapstructure:"contextLines"`
+	// RecentFileLimit keeps only the most recently modified files in the
+	// diff sent to the model; zero keeps all of them
+	RecentFileLimit int `mapstructure:"recentFileLimit"`
+	// WarnDiffBytes adds a warning when the diff sent to the model is larger;
+	// zero disables it
+	WarnDiffBytes int `mapstructure:"warnDiffBytes"`
+	// IgnorePatterns exclude the contents of matching files from the diff
+	// sent to the model, together with the patterns in .kommitignore
+	IgnorePatterns []string `mapstructure:"ignorePatterns"`
+	// MaxLineLength caps each added or removed line in the diff sent to the
+	// model; zero means unlimited
+	MaxLineLength int `mapstructure:"maxLineLength"`
+	// AllowConflictMarkers lets diffs with leftover merge-conflict markers
+	// through instead of failing with llm.ConflictMarkersError
+	AllowConflictMarkers bool `mapstructure:"allowConflictMarkers"`
+}
+
+type PrivacyConfig struct {
+	SecretScanCommand string `mapstructure:"secretScanCommand"`
+	// RedactPatterns are regular expressions whose matches are masked in
+	// anything kommit stores, such as audit records
+	RedactPatterns []string `mapstructure:"redactPatterns"`
+}
+
+type AuditConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Dir defaults to $XDG_DATA_HOME/kommit/audit
+	Dir string `mapstructure:"dir"`
 }
 
 type Config struct {
-	LLM    LLMConfig    `mapstructure:"llm"`
-	Commit CommitConfig `mapstructure:"commit"`
+	LLM     LLMConfig     `mapstructure:"llm"`
+	Commit  CommitConfig  `mapstructure:"commit"`
+	Privacy PrivacyConfig `mapstructure:"privacy"`
+	Audit   AuditConfig   `mapstructure:"audit"`
+}
+
+// AllowedTypes returns the configured types minus the denied ones.
+func (c CommitConfig) AllowedTypes() []string {
+	var allowed []string
+	for _, t := range c.Types {
+		if !slices.Contains(c.DeniedTypes, t) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// DiffContextLines returns ContextLines for git.StagedDiff, which reads -1 as
+// git's default.
+func (c CommitConfig) DiffContextLines() int {
+	if c.ContextLines == 0 {
+		return -1
+	}
+	return c.ContextLines
+}
+
+// StripsSubjectPeriod reports whether NoSubjectPeriod is in effect.
+func (c CommitConfig) StripsSubjectPeriod() bool {
+	return c.NoSubjectPeriod == nil || *c.NoSubjectPeriod
 }
 
 func LoadConfig() (*Config, error) {
@@ -61,9 +362,21 @@ func LoadConfig() (*Config, error) {
 	v.SetConfigType("yaml")
 	v.AddConfigPath(".")
 	v.AutomaticEnv()
-	v.SetConfigFile(configFilePath)
 
-	if err := v.ReadInConfig(); err != nil {
+	// The global config is optional and the repo config is layered on top
+	var globalTypes []string
+	if globalPath := GlobalConfigFilePath(); globalPath != "" {
+		if _, err := os.Stat(globalPath); err == nil {
+			v.SetConfigFile(globalPath)
+			if err := v.ReadInConfig(); err != nil {
+				return nil, fmt.Errorf("error reading global config %s: %w", globalPath, err)
+			}
+			globalTypes = v.GetStringSlice("commit.types")
+		}
+	}
+
+	v.SetConfigFile(configFilePath)
+	if err := v.MergeInConfig(); err != nil {
 		return nil, err
 	}
 
@@ -71,14 +384,54 @@ func LoadConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if config.Commit.TypesMergeStrategy == MergeStrategyUnion {
+		config.Commit.Types = unionTypes(globalTypes, config.Commit.Types)
+	}
 
-	if !models.IsSupportedModel(config.LLM.Model) {
+	// Bedrock model IDs are validated by AWS itself
+	if config.LLM.Provider != ProviderBedrock && !models.IsSupportedModel(config.LLM.ResolveModel(config.LLM.Model)) {
 		return nil, UnsupportedModelError{Model: config.LLM.Model}
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return config, nil
 }
 
+// unionTypes appends the types of each list in order, skipping duplicates.
+func unionTypes(lists ...[]string) []string {
+	var union []string
+	for _, list := range lists {
+		for _, t := range list {
+			if !slices.Contains(union, t) {
+				union = append(union, t)
+			}
+		}
+	}
+	return union
+}
+
+// LoadGlobalConfig reads the user-wide config on its own, without a repo
+// config on top. It returns nil when there is no global config.
+func LoadGlobalConfig() (*Config, error) {
+	globalPath := GlobalConfigFilePath()
+	if globalPath == "" {
+		return nil, nil
+	}
+	if _, err := os.Stat(globalPath); err != nil {
+		return nil, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(globalPath)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading global config %s: %w", globalPath, err)
+	}
+	return unmarshalConfig(v)
+}
+
 func GetDefaultConfig() (*Config, error) {
 	v := viper.New()
 	v.SetDefault("llm", map[string]any{
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAllowedTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		types  []string
+		denied []string
+		want   []string
+	}{
+		{"denied types stripped", []string{"feat", "fix", "perf", "ci"}, []string{"perf", "ci"}, []string{"feat", "fix"}},
+		{"nothing denied", []string{"feat", "fix"}, nil, []string{"feat", "fix"}},
+		{"denied type not configured", []string{"feat"}, []string{"perf"}, []string{"feat"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			commit := CommitConfig{Types: tt.types, DeniedTypes: tt.denied}
+			if got := commit.AllowedTypes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllowedTypes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffContextLines(t *testing.T) {
+	tests := []struct {
+		contextLines int
+		want         int
+	}{
+		{0, -1},
+		{1, 1},
+		{10, 10},
+	}
+
+	for _, tt := range tests {
+		commit := CommitConfig{ContextLines: tt.contextLines}
+		if got := commit.DiffContextLines(); got != tt.want {
+			t.Errorf("DiffContextLines() with contextLines %d = %d, want %d", tt.contextLines, got, tt.want)
+		}
+	}
+}
+
+func TestTypesMergeStrategy(t *testing.T) {
+	const global = "commit:\n  types: [feat, fix, chore, docs]\n"
+
+	tests := []struct {
+		name     string
+		strategy string
+		repo     string
+		want     []string
+	}{
+		{"replace", "replace", "[experiment, fix]", []string{"experiment", "fix"}},
+		{"default replaces", "", "[experiment, fix]", []string{"experiment", "fix"}},
+		{"union", "union", "[experiment, fix, feat]", []string{"feat", "fix", "chore", "docs", "experiment"}},
+		{"union without repo types", "union", "[]", []string{"feat", "fix", "chore", "docs"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t)
+			configHome := t.TempDir()
+			t.Setenv("XDG_CONFIG_HOME", configHome)
+			writeFile(t, filepath.Join(configHome, "kommit", "config.yaml"), global)
+
+			repo := "llm:\n  model: gpt-4o-mini\ncommit:\n  types: " + tt.repo + "\n"
+			if tt.strategy != "" {
+				repo += "  typesMergeStrategy: " + tt.strategy + "\n"
+			}
+			writeFile(t, configFilename, repo)
+
+			config, err := LoadConfig()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(config.Commit.Types, tt.want) {
+				t.Errorf("types = %q, want %q", config.Commit.Types, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnionTypes(t *testing.T) {
+	got := unionTypes([]string{"feat", "fix", "feat"}, nil, []string{"fix", "experiment", "docs"})
+	if want := []string{"feat", "fix", "experiment", "docs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unionTypes() = %q, want %q", got, want)
+	}
+}
+
+func TestResolveModel(t *testing.T) {
+	llm := LLMConfig{ModelAliases: map[string]string{"fast": "gpt-4o-mini", "smart": "gpt-4o-2024-08-06", "broken": ""}}
+
+	tests := []struct {
+		model string
+		want  string
+	}{
+		{"fast", "gpt-4o-mini"},
+		{"smart", "gpt-4o-2024-08-06"},
+		{"gpt-4o", "gpt-4o"},
+		{"broken", "broken"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := llm.ResolveModel(tt.model); got != tt.want {
+				t.Errorf("ResolveModel(%q) = %q, want %q", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigStrictWithoutTypes(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		wantErr bool
+	}{
+		{"strict", true, true},
+		{"not strict", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t)
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			writeFile(t, configFilename, fmt.Sprintf("llm:\n  model: gpt-4o-mini\ncommit:\n  types: []\n  strictValidation: %v\n", tt.strict))
+
+			_, err := LoadConfig()
+			if got := errors.Is(err, ConfigFieldError{}) && strings.Contains(err.Error(), "commit.types: "); got != tt.wantErr {
+				t.Errorf("LoadConfig() = %v, want a commit.types error: %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("LoadConfig() = %v, want nil", err)
+			}
+		})
+	}
+}
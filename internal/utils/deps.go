@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DependencyUpdate is a dependency whose version changed in a manifest. From
+// is empty for added dependencies and To for removed ones.
+type DependencyUpdate struct {
+	Name string
+	From string
+	To   string
+}
+
+// Manifests and lockfiles whose changes are dependency updates
+var dependencyFiles = []string{
+	"go.mod", "go.sum",
+	"package.json", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+}
+
+var (
+	// `require example.com/mod v1.2.3` or an indented line of a require block
+	goRequireRegex = regexp.MustCompile(`^[-+]\s*(?:require\s+)?([^\s()]+\.[^\s()]+)\s+(v[^\s]+)`)
+	// `"name": "^1.2.3",` in package.json
+	npmDependencyRegex = regexp.MustCompile(`^[-+]\s*"([^"]+)":\s*"([~^<>=]*\d[^"]*)",?\s*$`)
+
+	// Lines that only open or close a list of dependencies
+	goRequireBlockRegex   = regexp.MustCompile(`^[-+]\s*(?:require\s*\(|\))?\s*$`)
+	npmDependencyMapRegex = regexp.MustCompile(`^[-+]\s*(?:"(?:dependencies|devDependencies|peerDependencies|optionalDependencies)":\s*\{|\},?)?\s*$`)
+)
+
+// IsDependencyOnly reports whether the diff only changes dependencies: every
+// file is a dependency manifest or lockfile, such as go.mod or package.json,
+// and every changed line of a manifest is a dependency. Other manifest
+// edits, such as a go directive bump or a package.json script, don't count.
+func IsDependencyOnly(files []FileDiff) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if !slices.Contains(dependencyFiles, filepath.Base(f.Path())) {
+			return false
+		}
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				if isChangedLine(line) && !isDependencyLine(filepath.Base(f.Path()), line) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func isChangedLine(line string) bool {
+	return strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")
+}
+
+// isDependencyLine reports whether a changed line of the named dependency
+// file adds or removes a dependency. Any change to a lockfile does.
+func isDependencyLine(name, line string) bool {
+	re, structure := dependencyRegexes(name)
+	if re == nil {
+		return true
+	}
+	if matches := re.FindStringSubmatch(line); matches != nil {
+		return matches[1] != "version"
+	}
+	return structure.MatchString(line)
+}
+
+// dependencyRegexes returns the regexp matching a dependency line of the
+// named manifest and the one matching the lines around a list of
+// dependencies, or nils for lockfiles.
+func dependencyRegexes(name string) (dependency, structure *regexp.Regexp) {
+	switch name {
+	case "go.mod":
+		return goRequireRegex, goRequireBlockRegex
+	case "package.json":
+		return npmDependencyRegex, npmDependencyMapRegex
+	}
+	return nil, nil
+}
+
+// DependencyUpdates extracts the changed dependency versions from go.mod and
+// package.json, sorted by name. Lockfiles are ignored since they also list
+// transitive dependencies.
+func DependencyUpdates(files []FileDiff) []DependencyUpdate {
+	updates := make(map[string]*DependencyUpdate)
+	for _, f := range files {
+		re, _ := dependencyRegexes(filepath.Base(f.Path()))
+		if re == nil {
+			continue
+		}
+
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				matches := re.FindStringSubmatch(line)
+				// The package's own version isn't a dependency
+				if matches == nil || matches[1] == "version" {
+					continue
+				}
+				update, ok := updates[matches[1]]
+				if !ok {
+					update = &DependencyUpdate{Name: matches[1]}
+					updates[matches[1]] = update
+				}
+				if line[0] == '-' {
+					update.From = matches[2]
+				} else {
+					update.To = matches[2]
+				}
+			}
+		}
+	}
+
+	var result []DependencyUpdate
+	for _, update := range updates {
+		// Lines that were only moved around aren't updates
+		if update.From != update.To {
+			result = append(result, *update)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// String describes the update as a body bullet, e.g. `bump x from v1 to v2`.
+func (u DependencyUpdate) String() string {
+	switch {
+	case u.From == "":
+		return "add " + u.Name + " " + u.To
+	case u.To == "":
+		return "remove " + u.Name
+	default:
+		return "bump " + u.Name + " from " + u.From + " to " + u.To
+	}
+}
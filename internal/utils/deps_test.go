@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// manifestDiff builds a diff of path with the given hunk lines.
+func manifestDiff(path string, lines ...string) string {
+	return "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path +
+		"\n@@ -1,3 +1,3 @@\n" + strings.Join(lines, "\n") + "\n"
+}
+
+var goModBump = manifestDiff("go.mod",
+	" require (",
+	"-\tgolang.org/x/text v0.14.0",
+	"+\tgolang.org/x/text v0.15.0",
+	" )",
+)
+
+func TestIsDependencyOnly(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want bool
+	}{
+		{"go.mod bump", goModBump, true},
+		{"go.mod and go.sum", goModBump + manifestDiff("go.sum", "-golang.org/x/text v0.14.0 h1:abc=", "+golang.org/x/text v0.15.0 h1:def="), true},
+		{"new require block", manifestDiff("go.mod", "+require (", "+\tgithub.com/spf13/cobra v1.8.0 // indirect", "+)", "+"), true},
+		{"single-line require", manifestDiff("svc/go.mod", "+require github.com/spf13/cobra v1.8.0"), true},
+		{"go directive bump", manifestDiff("go.mod", "-go 1.22", "+go 1.24"), false},
+		{"toolchain with a dependency", goModBump + manifestDiff("go.mod", "+toolchain go1.24.1"), false},
+		{"package.json dependency", manifestDiff("package.json", ` "dependencies": {`, `-  "react": "^18.2.0",`, `+  "react": "^18.3.1",`, ` }`), true},
+		{"new dependency map", manifestDiff("package.json", `+  "devDependencies": {`, `+    "vitest": "^1.6.0"`, `+  },`), true},
+		{"package.json script", manifestDiff("package.json", ` "scripts": {`, `-  "build": "tsc",`, `+  "build": "tsc -p .",`, ` }`), false},
+		{"package version", manifestDiff("package.json", `-  "version": "1.2.0",`, `+  "version": "1.3.0",`), false},
+		{"lockfile", manifestDiff("yarn.lock", "-lodash@4.17.20", "+lodash@4.17.21"), true},
+		{"source file too", goModBump + manifestDiff("main.go", "+var x = 1"), false},
+		{"no files", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsDependencyOnly(ParseDiff(tt.diff)); got != tt.want {
+				t.Errorf("IsDependencyOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyUpdates(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want []DependencyUpdate
+	}{
+		{"go.mod bump", goModBump, []DependencyUpdate{{Name: "golang.org/x/text", From: "v0.14.0", To: "v0.15.0"}}},
+		{
+			name: "added and removed",
+			diff: manifestDiff("go.mod", "+require github.com/spf13/cobra v1.8.0", "-require github.com/urfave/cli v1.22.0"),
+			want: []DependencyUpdate{{Name: "github.com/spf13/cobra", To: "v1.8.0"}, {Name: "github.com/urfave/cli", From: "v1.22.0"}},
+		},
+		{
+			name: "package.json sorted by name",
+			diff: manifestDiff("package.json", `-  "react": "^18.2.0",`, `+  "react": "^18.3.1",`, `+  "axios": "1.7.2"`, `-  "version": "1.0.0",`),
+			want: []DependencyUpdate{{Name: "axios", To: "1.7.2"}, {Name: "react", From: "^18.2.0", To: "^18.3.1"}},
+		},
+		{"moved line", manifestDiff("go.mod", "-\tgolang.org/x/text v0.15.0", "+\tgolang.org/x/text v0.15.0"), nil},
+		{"lockfiles ignored", manifestDiff("go.sum", "+golang.org/x/text v0.15.0 h1:def="), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DependencyUpdates(ParseDiff(tt.diff)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DependencyUpdates() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDependencyUpdateString(t *testing.T) {
+	tests := []struct {
+		update DependencyUpdate
+		want   string
+	}{
+		{DependencyUpdate{Name: "x", From: "v1", To: "v2"}, "bump x from v1 to v2"},
+		{DependencyUpdate{Name: "x", To: "v2"}, "add x v2"},
+		{DependencyUpdate{Name: "x", From: "v1"}, "remove x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.update.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
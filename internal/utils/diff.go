@@ -0,0 +1,449 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type FileStatus string
+
+const (
+	FileStatusAdded    FileStatus = "added"
+	FileStatusDeleted  FileStatus = "deleted"
+	FileStatusModified FileStatus = "modified"
+	FileStatusRenamed  FileStatus = "renamed"
+	FileStatusCopied   FileStatus = "copied"
+)
+
+type Hunk struct {
+	Header   string
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []string
+}
+
+type FileDiff struct {
+	OldPath    string
+	NewPath    string
+	Status     FileStatus
+	Binary     bool
+	Similarity int
+	Header     []string
+	Hunks      []Hunk
+}
+
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Path returns the path of the file after the change, or the old path for
+// deleted files.
+func (f FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+func (f FileDiff) Additions() int {
+	return f.countLines('+')
+}
+
+func (f FileDiff) Deletions() int {
+	return f.countLines('-')
+}
+
+func (f FileDiff) countLines(prefix byte) int {
+	count := 0
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if len(line) > 0 && line[0] == prefix {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// DiffStats summarizes a diff the way `git diff --shortstat` does, with the
+// files broken down by status.
+type DiffStats struct {
+	Files      int
+	Insertions int
+	Deletions  int
+	Binary     int
+	ByStatus   map[FileStatus]int
+}
+
+// ComputeDiffStats adds up the changes in files. Binary files count towards
+// Files and Binary but have no line changes.
+func ComputeDiffStats(files []FileDiff) DiffStats {
+	stats := DiffStats{Files: len(files), ByStatus: map[FileStatus]int{}}
+	for _, f := range files {
+		stats.Insertions += f.Additions()
+		stats.Deletions += f.Deletions()
+		stats.ByStatus[f.Status]++
+		if f.Binary {
+			stats.Binary++
+		}
+	}
+	return stats
+}
+
+// String renders the stats as e.g. "5 files, +120 −30".
+func (s DiffStats) String() string {
+	files := "files"
+	if s.Files == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s, +%d −%d", s.Files, files, s.Insertions, s.Deletions)
+}
+
+// FilesWithConflictMarkers returns the paths of the files whose added lines
+// still contain `<<<<<<<` or `>>>>>>>` merge-conflict markers. A lone
+// `=======` isn't enough, since it is also a heading underline.
+func FilesWithConflictMarkers(files []FileDiff) []string {
+	var paths []string
+	for _, f := range files {
+	hunks:
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				if isConflictMarker(line, "+<<<<<<<") || isConflictMarker(line, "+>>>>>>>") {
+					paths = append(paths, f.Path())
+					break hunks
+				}
+			}
+		}
+	}
+	return paths
+}
+
+func isConflictMarker(line, marker string) bool {
+	rest, ok := strings.CutPrefix(line, marker)
+	return ok && (rest == "" || rest[0] == ' ')
+}
+
+// IsPureRename reports whether the file was moved without any content change.
+func (f FileDiff) IsPureRename() bool {
+	return f.Status == FileStatusRenamed && len(f.Hunks) == 0
+}
+
+// String renders the file diff back into unified diff format.
+func (f FileDiff) String() string {
+	var b strings.Builder
+	for _, line := range f.Header {
+		b.WriteString(line + "\n")
+	}
+	for _, hunk := range f.Hunks {
+		b.WriteString(hunk.Header + "\n")
+		for _, line := range hunk.Lines {
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// ParseDiff splits the output of `git diff` into per-file diffs.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+			hunk = nil
+		}
+		files = append(files, *current)
+		current = nil
+	}
+
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			oldPath, newPath := parseDiffGitLine(line)
+			current = &FileDiff{
+				OldPath: oldPath,
+				NewPath: newPath,
+				Status:  FileStatusModified,
+				Header:  []string{line},
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		if matches := hunkHeaderRegex.FindStringSubmatch(line); matches != nil {
+			if hunk != nil {
+				current.Hunks = append(current.Hunks, *hunk)
+			}
+			hunk = &Hunk{
+				Header:   line,
+				OldStart: atoiOr(matches[1], 0),
+				OldLines: atoiOr(matches[2], 1),
+				NewStart: atoiOr(matches[3], 0),
+				NewLines: atoiOr(matches[4], 1),
+			}
+			continue
+		}
+
+		if hunk != nil {
+			hunk.Lines = append(hunk.Lines, line)
+			continue
+		}
+
+		current.Header = append(current.Header, line)
+		switch {
+		case strings.HasPrefix(line, "new file mode"):
+			current.Status = FileStatusAdded
+			current.OldPath = ""
+		case strings.HasPrefix(line, "deleted file mode"):
+			current.Status = FileStatusDeleted
+			current.NewPath = ""
+		case strings.HasPrefix(line, "rename from "):
+			current.Status = FileStatusRenamed
+			current.OldPath = strings.TrimPrefix(line, "rename from ")
+		case strings.HasPrefix(line, "rename to "):
+			current.Status = FileStatusRenamed
+			current.NewPath = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "copy from "):
+			current.Status = FileStatusCopied
+			current.OldPath = strings.TrimPrefix(line, "copy from ")
+		case strings.HasPrefix(line, "copy to "):
+			current.Status = FileStatusCopied
+			current.NewPath = strings.TrimPrefix(line, "copy to ")
+		case strings.HasPrefix(line, "similarity index "):
+			current.Similarity = atoiOr(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"), 0)
+		case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+			current.Binary = true
+		}
+	}
+	flush()
+
+	return files
+}
+
+// HasChanges reports whether diff changes anything at all. Empty or
+// whitespace-only input and file headers without hunks don't count, unless the
+// header itself records a change such as a rename, a mode change or a binary
+// file.
+func HasChanges(diff string) bool {
+	if strings.TrimSpace(diff) == "" {
+		return false
+	}
+	for _, f := range ParseDiff(diff) {
+		if len(f.Hunks) > 0 || f.Binary || f.Status != FileStatusModified {
+			return true
+		}
+		for _, line := range f.Header {
+			if strings.HasPrefix(line, "old mode ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+const truncatedLineMarker = "…(truncated)"
+
+// TruncateLongLines caps every added or removed line of the diff at maxLength
+// characters, marking the cut, so a minified file or a long data line can't
+// use up the token budget on its own. File headers, everything before a
+// file's first hunk as in ParseDiff, are left intact.
+func TruncateLongLines(diff string, maxLength int) string {
+	if maxLength <= 0 {
+		return diff
+	}
+
+	lines := strings.Split(diff, "\n")
+	inHunk := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			inHunk = false
+			continue
+		case hunkHeaderRegex.MatchString(line):
+			inHunk = true
+			continue
+		}
+		if !inHunk || (!strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-")) {
+			continue
+		}
+		// The +/- marker doesn't count towards the length
+		if runes := []rune(line[1:]); len(runes) > maxLength {
+			lines[i] = line[:1] + string(runes[:maxLength]) + truncatedLineMarker
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Renames returns a human-readable "old → new" line for every renamed file.
+func Renames(files []FileDiff) []string {
+	var renames []string
+	for _, f := range files {
+		if f.Status == FileStatusRenamed {
+			renames = append(renames, fmt.Sprintf("%s → %s", f.OldPath, f.NewPath))
+		}
+	}
+	return renames
+}
+
+// IsRenameOnly reports whether every file in the diff is a pure rename.
+func IsRenameOnly(files []FileDiff) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if !f.IsPureRename() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFormatOnly reports whether the diff only changes whitespace or line
+// wrapping, e.g. after running a formatter: per file, the removed and added
+// lines must be identical once all whitespace is stripped.
+func IsFormatOnly(files []FileDiff) bool {
+	changed := false
+	for _, f := range files {
+		if f.Binary || f.Status == FileStatusAdded || f.Status == FileStatusDeleted {
+			return false
+		}
+
+		var removed, added strings.Builder
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				if line == "" {
+					continue
+				}
+				switch line[0] {
+				case '-':
+					removed.WriteString(stripWhitespace(line[1:]))
+				case '+':
+					added.WriteString(stripWhitespace(line[1:]))
+				}
+			}
+		}
+		if removed.String() != added.String() {
+			return false
+		}
+		changed = changed || len(f.Hunks) > 0
+	}
+	return changed
+}
+
+func stripWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// DiffFingerprint returns a stable hash of what the diff changes, for caching
+// and correlating generations. Hunk positions, index hashes and trailing
+// whitespace are ignored, so equivalent diffs taken at different points in
+// time map to the same fingerprint.
+func DiffFingerprint(diff string) string {
+	h := sha256.New()
+	for _, f := range ParseDiff(diff) {
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00%t\n", f.OldPath, f.NewPath, f.Status, f.Binary)
+		for _, hunk := range f.Hunks {
+			h.Write([]byte("@@\n"))
+			for _, line := range hunk.Lines {
+				h.Write([]byte(strings.TrimRight(line, " \t\r") + "\n"))
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// PartiallyStagedFiles returns the staged files whose staged hunks only cover
+// some of their changes, i.e. files staged hunk by hunk with `git add -p`.
+// staged is the index diff and worktree `git diff HEAD`; a file is partial
+// when one of its working tree hunks overlaps none of its staged hunks.
+func PartiallyStagedFiles(staged, worktree []FileDiff) []string {
+	worktreeHunks := make(map[string][]Hunk)
+	for _, f := range worktree {
+		worktreeHunks[f.Path()] = f.Hunks
+	}
+
+	var partial []string
+	for _, f := range staged {
+		if len(f.Hunks) == 0 || f.Status == FileStatusDeleted {
+			continue
+		}
+		for _, hunk := range worktreeHunks[f.Path()] {
+			if !overlapsAny(hunk, f.Hunks) {
+				partial = append(partial, f.Path())
+				break
+			}
+		}
+	}
+	return partial
+}
+
+// overlapsAny reports whether hunk shares lines of the old file with any of
+// hunks. Context lines count, so adjacent changes overlap.
+func overlapsAny(hunk Hunk, hunks []Hunk) bool {
+	for _, other := range hunks {
+		if hunk.OldStart <= other.OldStart+other.OldLines && other.OldStart <= hunk.OldStart+hunk.OldLines {
+			return true
+		}
+	}
+	return false
+}
+
+const defaultContextLines = 3
+
+// RecommendContextLines suggests a `git diff -U` value that keeps the diff
+// within budget. diffSize is the size of the diff produced with git's default
+// context, and both values use the same unit (e.g. tokens). The more headroom
+// the budget leaves, the more context is recommended. The suggestion is
+// advisory, for callers that can re-run git to pick commit.contextLines, and
+// is never 0, which commit.contextLines reads as git's default.
+func RecommendContextLines(diffSize, budget int) int {
+	if diffSize <= 0 || budget <= 0 {
+		return defaultContextLines
+	}
+
+	ratio := float64(budget) / float64(diffSize)
+	switch {
+	case ratio >= 4:
+		return 10
+	case ratio >= 2:
+		return 6
+	case ratio >= 1:
+		return defaultContextLines
+	default:
+		return 1
+	}
+}
+
+func parseDiffGitLine(line string) (string, string) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.Index(rest, " b/"); i >= 0 && strings.HasPrefix(rest, "a/") {
+		return rest[2:i], rest[i+3:]
+	}
+	parts := strings.SplitN(rest, " ", 2)
+	if len(parts) != 2 {
+		return rest, rest
+	}
+	return parts[0], parts[1]
+}
+
+func atoiOr(s string, fallback int) int {
+	if s == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const renameDiff = `diff --git a/pkg/old.go b/pkg/new.go
+similarity index 100%
+rename from pkg/old.go
+rename to pkg/new.go
+diff --git a/docs/a.md b/guide/a.md
+similarity index 100%
+rename from docs/a.md
+rename to guide/a.md
+`
+
+const modifyDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,2 +1,2 @@
+ package main
+-var x = 1
++var x = 2
+`
+
+func TestRenames(t *testing.T) {
+	tests := []struct {
+		name           string
+		diff           string
+		wantRenames    []string
+		wantRenameOnly bool
+	}{
+		{
+			name:           "rename only",
+			diff:           renameDiff,
+			wantRenames:    []string{"pkg/old.go → pkg/new.go", "docs/a.md → guide/a.md"},
+			wantRenameOnly: true,
+		},
+		{
+			name:           "rename and modification",
+			diff:           renameDiff + modifyDiff,
+			wantRenames:    []string{"pkg/old.go → pkg/new.go", "docs/a.md → guide/a.md"},
+			wantRenameOnly: false,
+		},
+		{
+			name:           "no renames",
+			diff:           modifyDiff,
+			wantRenames:    nil,
+			wantRenameOnly: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := ParseDiff(tt.diff)
+			if got := Renames(files); !reflect.DeepEqual(got, tt.wantRenames) {
+				t.Errorf("Renames() = %q, want %q", got, tt.wantRenames)
+			}
+			if got := IsRenameOnly(files); got != tt.wantRenameOnly {
+				t.Errorf("IsRenameOnly() = %v, want %v", got, tt.wantRenameOnly)
+			}
+		})
+	}
+}
+
+func TestRecommendContextLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		diffSize int
+		budget   int
+		want     int
+	}{
+		{"plenty of headroom", 1000, 8000, 10},
+		{"some headroom", 1000, 2500, 6},
+		{"just fits", 1000, 1000, 3},
+		{"slightly over budget", 1000, 800, 1},
+		{"far over budget keeps one line", 1000, 200, 1},
+		{"unknown size", 0, 1000, 3},
+		{"no budget", 1000, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecommendContextLines(tt.diffSize, tt.budget); got != tt.want {
+				t.Errorf("RecommendContextLines(%d, %d) = %d, want %d", tt.diffSize, tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartiallyStagedFiles(t *testing.T) {
+	lines := func(changed ...int) string {
+		content := ""
+		for i := 1; i <= 30; i++ {
+			line := "line"
+			for _, c := range changed {
+				if c == i {
+					line = "changed"
+				}
+			}
+			content += line + "\n"
+		}
+		return content
+	}
+
+	tests := []struct {
+		name   string
+		staged string
+		// worktree is the file content after staging
+		worktree string
+		want     []string
+	}{
+		{"full file staged", lines(2, 28), lines(2, 28), nil},
+		{"single hunk staged", lines(2), lines(2, 28), []string{"file.txt"}},
+		{"later edit next to the staged hunk", lines(2), lines(2, 3), nil},
+		{"changes reverted after staging", lines(2, 28), lines(), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t)
+			commitFile(t, "file.txt", lines(), "chore: initial commit")
+			writeFile(t, "file.txt", tt.staged)
+			writeFile(t, "new.txt", "new\n")
+			runGit(t, "add", "file.txt", "new.txt")
+			writeFile(t, "file.txt", tt.worktree)
+
+			staged := ParseDiff(runGit(t, "diff", "--cached"))
+			worktree := ParseDiff(runGit(t, "diff", "HEAD"))
+			if got := PartiallyStagedFiles(staged, worktree); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PartiallyStagedFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsFormatOnly(t *testing.T) {
+	const (
+		reindented = "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n" +
+			"@@ -1,3 +1,3 @@\n func main() {\n-  run()\n+\trun()\n }\n"
+		rewrapped = "diff --git a/call.go b/call.go\nindex 1111111..2222222 100644\n--- a/call.go\n+++ b/call.go\n" +
+			"@@ -1,1 +1,2 @@\n-call(a, b)\n+call(\n+\ta, b)\n"
+		changed = "diff --git a/lib.go b/lib.go\nindex 1111111..2222222 100644\n--- a/lib.go\n+++ b/lib.go\n" +
+			"@@ -1,1 +1,1 @@\n-return a\n+return b\n"
+		added = "diff --git a/new.go b/new.go\nnew file mode 100644\nindex 0000000..2222222\n--- /dev/null\n+++ b/new.go\n" +
+			"@@ -0,0 +1,1 @@\n+package new\n"
+	)
+
+	tests := []struct {
+		name string
+		diff string
+		want bool
+	}{
+		{"whitespace only", reindented, true},
+		{"rewrapped", rewrapped, true},
+		{"whitespace only across files", reindented + strings.ReplaceAll(reindented, "main.go", "other.go"), true},
+		{"behavior change", changed, false},
+		{"mixed", reindented + changed, false},
+		{"added file", added, false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFormatOnly(ParseDiff(tt.diff)); got != tt.want {
+				t.Errorf("IsFormatOnly() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffFingerprint(t *testing.T) {
+	const base = "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n" +
+		"@@ -10,3 +10,3 @@ func main() {\n \tsetup()\n-\trun()\n+\trunAll()\n \tteardown()\n"
+	fingerprint := DiffFingerprint(base)
+
+	tests := []struct {
+		name string
+		diff string
+		same bool
+	}{
+		{"identical", base, true},
+		{"hunk line numbers", strings.Replace(base, "@@ -10,3 +10,3 @@ func main() {", "@@ -42,3 +40,3 @@", 1), true},
+		{"index hashes", strings.Replace(base, "1111111..2222222", "abcdef0..1234567", 1), true},
+		{"trailing whitespace", strings.Replace(base, "+\trunAll()\n", "+\trunAll()  \t\n", 1), true},
+		{"content change", strings.Replace(base, "runAll", "runSome", 1), false},
+		{"renamed file", strings.ReplaceAll(base, "main.go", "app.go"), false},
+		{"line moved into context", strings.Replace(base, "-\trun()\n+\trunAll()\n", " \trun()\n+\trunAll()\n", 1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DiffFingerprint(tt.diff) == fingerprint; got != tt.same {
+				t.Errorf("same fingerprint = %v, want %v", got, tt.same)
+			}
+		})
+	}
+	if len(fingerprint) != 64 {
+		t.Errorf("fingerprint %q is not a hex SHA-256", fingerprint)
+	}
+}
+
+func TestHasChanges(t *testing.T) {
+	const header = "diff --git a/main.go b/main.go\nindex 1111111..2222222 100644\n--- a/main.go\n+++ b/main.go\n"
+
+	tests := []struct {
+		name string
+		diff string
+		want bool
+	}{
+		{"empty", "", false},
+		{"whitespace only", " \n\t\n", false},
+		{"header only", header, false},
+		{"hunk", header + "@@ -1 +1 @@\n-a\n+b\n", true},
+		{"mode change", "diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n", true},
+		{"binary", "diff --git a/logo.png b/logo.png\nindex 1111111..2222222 100644\nBinary files a/logo.png and b/logo.png differ\n", true},
+		{"empty new file", "diff --git a/empty b/empty\nnew file mode 100644\nindex 0000000..e69de29\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasChanges(tt.diff); got != tt.want {
+				t.Errorf("HasChanges() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateLongLines(t *testing.T) {
+	long := strings.Repeat("a", 10000)
+	header := "diff --git a/app.min.js b/app.min.js\n--- a/app.min.js\n+++ b/app.min.js\n@@ -1 +1 @@\n"
+
+	tests := []struct {
+		name      string
+		diff      string
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "10k-character line capped",
+			diff:      header + "-" + long + "\n+" + long + "\n",
+			maxLength: 100,
+			want:      header + "-" + long[:100] + truncatedLineMarker + "\n+" + long[:100] + truncatedLineMarker + "\n",
+		},
+		{
+			name:      "short lines kept",
+			diff:      header + "-var a=1\n+var a=2\n",
+			maxLength: 100,
+			want:      header + "-var a=1\n+var a=2\n",
+		},
+		{
+			name:      "context lines and headers kept",
+			diff:      "diff --git a/" + long + " b/x\n--- a/" + long + "\n+++ b/x\n " + long + "\n",
+			maxLength: 10,
+			want:      "diff --git a/" + long + " b/x\n--- a/" + long + "\n+++ b/x\n " + long + "\n",
+		},
+		{
+			name:      "content lines that look like headers capped",
+			diff:      header + "--- " + long + "\n+++ " + long + "\ndiff --git a/b b/b\n--- a/" + long + "\n+++ b/b\n",
+			maxLength: 10,
+			want:      header + "--- " + long[:7] + truncatedLineMarker + "\n+++ " + long[:7] + truncatedLineMarker + "\ndiff --git a/b b/b\n--- a/" + long + "\n+++ b/b\n",
+		},
+		{
+			name:      "cut on characters, not bytes",
+			diff:      header + "+" + strings.Repeat("é", 20) + "\n",
+			maxLength: 5,
+			want:      header + "+ééééé" + truncatedLineMarker + "\n",
+		},
+		{
+			name:      "disabled",
+			diff:      header + "+" + long + "\n",
+			maxLength: 0,
+			want:      header + "+" + long + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TruncateLongLines(tt.diff, tt.maxLength); got != tt.want {
+				t.Errorf("TruncateLongLines() = %.200q, want %.200q", got, tt.want)
+			}
+		})
+	}
+}
+
+const statsFixtureDiff = `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+-import "fmt"
++import (
++	"fmt"
++)
+diff --git a/pkg/old.go b/pkg/new.go
+similarity index 90%
+rename from pkg/old.go
+rename to pkg/new.go
+index 3333333..4444444 100644
+--- a/pkg/old.go
++++ b/pkg/new.go
+@@ -1 +1 @@
+-package old
++package new
+diff --git a/docs/a.md b/guide/a.md
+similarity index 100%
+rename from docs/a.md
+rename to guide/a.md
+diff --git a/logo.png b/logo.png
+new file mode 100644
+index 0000000..5555555
+Binary files /dev/null and b/logo.png differ
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+index 6666666..0000000
+--- a/gone.txt
++++ /dev/null
+@@ -1,2 +0,0 @@
+-one
+-two
+`
+
+func TestComputeDiffStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		diff       string
+		want       DiffStats
+		wantString string
+	}{
+		{
+			name: "renames and binaries",
+			diff: statsFixtureDiff,
+			want: DiffStats{
+				Files:      5,
+				Insertions: 4,
+				Deletions:  4,
+				Binary:     1,
+				ByStatus: map[FileStatus]int{
+					FileStatusModified: 1,
+					FileStatusRenamed:  2,
+					FileStatusAdded:    1,
+					FileStatusDeleted:  1,
+				},
+			},
+			wantString: "5 files, +4 −4",
+		},
+		{
+			name:       "single file",
+			diff:       "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1,2 @@\n x\n+y\n",
+			want:       DiffStats{Files: 1, Insertions: 1, ByStatus: map[FileStatus]int{FileStatusModified: 1}},
+			wantString: "1 file, +1 −0",
+		},
+		{
+			name:       "empty",
+			diff:       "",
+			want:       DiffStats{ByStatus: map[FileStatus]int{}},
+			wantString: "0 files, +0 −0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeDiffStats(ParseDiff(tt.diff))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputeDiffStats() = %+v, want %+v", got, tt.want)
+			}
+			if got.String() != tt.wantString {
+				t.Errorf("String() = %q, want %q", got.String(), tt.wantString)
+			}
+		})
+	}
+}
+
+func TestFilesWithConflictMarkers(t *testing.T) {
+	fileDiff := func(path string, lines ...string) string {
+		return "diff --git a/" + path + " b/" + path + "\n--- a/" + path + "\n+++ b/" + path +
+			"\n@@ -1,1 +1,5 @@\n line\n" + strings.Join(lines, "\n") + "\n"
+	}
+
+	tests := []struct {
+		name string
+		diff string
+		want []string
+	}{
+		{"clean", modifyDiff, nil},
+		{
+			name: "full conflict",
+			diff: fileDiff("main.go", "+<<<<<<< HEAD", "+var x = 1", "+=======", "+var x = 2", "+>>>>>>> feature"),
+			want: []string{"main.go"},
+		},
+		{"leftover closing marker", fileDiff("a.go", "+>>>>>>>"), []string{"a.go"}},
+		{"only the affected files", modifyDiff + fileDiff("b.go", "+<<<<<<< ours"), []string{"b.go"}},
+		{"heading underline", fileDiff("README.md", "+Title", "+======="), nil},
+		{"removed markers", fileDiff("a.go", "-<<<<<<< HEAD", "->>>>>>> feature"), nil},
+		{"context lines", fileDiff("a.go", " <<<<<<< HEAD", "+x"), nil},
+		{"longer run of brackets", fileDiff("a.go", "+<<<<<<<<<<"), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilesWithConflictMarkers(ParseDiff(tt.diff)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FilesWithConflictMarkers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,8 +13,11 @@ func (e UnsupportedModelError) Error() string {
 }
 
 func (e UnsupportedModelError) Is(target error) bool {
-	_, ok := target.(UnsupportedModelError)
-	return ok
+	switch target.(type) {
+	case UnsupportedModelError, *UnsupportedModelError:
+		return true
+	}
+	return false
 }
 
 type CostFileNotFoundError struct{}
@@ -22,3 +25,20 @@ type CostFileNotFoundError struct{}
 func (e CostFileNotFoundError) Error() string {
 	return "Cost file not found"
 }
+
+type ConfigFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e ConfigFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+func (e ConfigFieldError) Is(target error) bool {
+	switch target.(type) {
+	case ConfigFieldError, *ConfigFieldError:
+		return true
+	}
+	return false
+}
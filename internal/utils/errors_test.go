@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		targets []error
+		others  []error
+	}{
+		{
+			name:    "UnsupportedModelError",
+			err:     UnsupportedModelError{Model: "gpt-0"},
+			targets: []error{UnsupportedModelError{}, &UnsupportedModelError{}},
+			others:  []error{ConfigFieldError{}, &ConfigFieldError{}},
+		},
+		{
+			name:    "ConfigFieldError",
+			err:     ConfigFieldError{Field: "commit.types", Message: "must not be empty"},
+			targets: []error{ConfigFieldError{}, &ConfigFieldError{}},
+			others:  []error{UnsupportedModelError{}, &UnsupportedModelError{}},
+		},
+		{
+			name:    "*ConfigFieldError",
+			err:     &ConfigFieldError{Field: "commit.types", Message: "must not be empty"},
+			targets: []error{ConfigFieldError{}, &ConfigFieldError{}},
+			others:  []error{UnsupportedModelError{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped := fmt.Errorf("loading config: %w", tt.err)
+			for _, target := range tt.targets {
+				if !errors.Is(wrapped, target) {
+					t.Errorf("errors.Is(err, %#v) = false", target)
+				}
+			}
+			for _, target := range tt.others {
+				if errors.Is(wrapped, target) {
+					t.Errorf("errors.Is(err, %#v) = true", target)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigFieldErrorAs(t *testing.T) {
+	err := fmt.Errorf("loading config: %w", errors.Join(
+		ConfigFieldError{Field: "commit.types", Message: "must not be empty"},
+		ConfigFieldError{Field: "llm.topLogprobs", Message: "must be at most 20"},
+	))
+
+	var fieldErr ConfigFieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "commit.types" {
+		t.Errorf("errors.As() = %+v, want the first field error", fieldErr)
+	}
+	var modelErr UnsupportedModelError
+	if errors.As(err, &modelErr) {
+		t.Error("errors.As() found an UnsupportedModelError")
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HistoryCommit is a commit subject from `git log` with its commit time.
+type HistoryCommit struct {
+	Subject string
+	Time    time.Time
+}
+
+// GetCommitHistory returns the subjects of the last limit commits, newest
+// first.
+func GetCommitHistory(limit int) ([]HistoryCommit, error) {
+	output, err := ExecGit("log", "-n", strconv.Itoa(limit), "--format=%ct %s")
+	if err != nil {
+		return nil, err
+	}
+
+	var history []HistoryCommit
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		timestamp, subject, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			continue
+		}
+		history = append(history, HistoryCommit{Subject: subject, Time: time.Unix(seconds, 0)})
+	}
+	return history, nil
+}
+
+// SelectStyleExamples picks up to n Conventional Commits from history to show
+// the model as style examples. recencyBias in [0, 1] decides how they are
+// spread: 1 picks the n most recent, 0 samples evenly across the whole
+// history for diversity, and values in between skew towards recent commits.
+// The result is ordered newest first, whatever the order of history.
+func SelectStyleExamples(history []HistoryCommit, n int, recencyBias float64) []HistoryCommit {
+	var conforming []HistoryCommit
+	for _, commit := range history {
+		if _, ok := ParseCommitHeader(commit.Subject); ok {
+			conforming = append(conforming, commit)
+		}
+	}
+	sort.SliceStable(conforming, func(i, j int) bool {
+		return conforming[i].Time.After(conforming[j].Time)
+	})
+	if n <= 0 {
+		return nil
+	}
+	if len(conforming) <= n {
+		return conforming
+	}
+
+	// Quantiles raised to a power > 1 crowd towards the newest commits
+	recencyBias = min(max(recencyBias, 0), 1)
+	exponent := 1 / math.Max(1-recencyBias, 0.01)
+	selected := make([]HistoryCommit, 0, n)
+	next := 0
+	for k := range n {
+		i := int(float64(len(conforming)) * math.Pow(float64(k)/float64(n), exponent))
+		// Keep indices distinct while leaving room for the remaining picks
+		i = min(max(i, next), len(conforming)-(n-k))
+		selected = append(selected, conforming[i])
+		next = i + 1
+	}
+	return selected
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectStyleExamples(t *testing.T) {
+	// 100 hourly commits, oldest first, with a few that don't conform
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var history []HistoryCommit
+	for i := range 100 {
+		history = append(history, HistoryCommit{Subject: fmt.Sprintf("feat: change %d", i), Time: start.Add(time.Duration(i) * time.Hour)})
+		if i%10 == 5 {
+			history = append(history, HistoryCommit{Subject: fmt.Sprintf("Merge %d", i), Time: start.Add(time.Duration(i) * time.Hour)})
+		}
+	}
+
+	tests := []struct {
+		name string
+		n    int
+		bias float64
+		want []int
+	}{
+		{"high bias picks the newest", 5, 1, []int{99, 98, 97, 96, 95}},
+		{"low bias spreads out", 5, 0, []int{99, 79, 59, 39, 19}},
+		{"medium bias skews recent", 5, 0.5, []int{99, 95, 83, 63, 35}},
+		{"bias clamped", 3, 7, []int{99, 98, 97}},
+		{"fewer commits than asked", 200, 1, nil},
+		{"none asked", 0, 1, []int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := SelectStyleExamples(history, tt.n, tt.bias)
+			if tt.want == nil {
+				if len(selected) != 100 || selected[0].Subject != "feat: change 99" {
+					t.Errorf("got %d examples, want all 100 conforming, newest first", len(selected))
+				}
+				return
+			}
+
+			got := make([]int, len(selected))
+			for i, commit := range selected {
+				fmt.Sscanf(commit.Subject, "feat: change %d", &got[i])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selected changes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetCommitHistory(t *testing.T) {
+	newTestRepo(t)
+	for i, subject := range []string{"feat: first", "fix: second", "docs: third subject"} {
+		t.Setenv("GIT_COMMITTER_DATE", fmt.Sprintf("2024-01-0%dT00:00:00Z", i+1))
+		commitFile(t, "file.txt", subject, subject)
+	}
+
+	history, err := GetCommitHistory(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryCommit{
+		{Subject: "docs: third subject", Time: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{Subject: "fix: second", Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("GetCommitHistory() = %v, want %v", history, want)
+	}
+	for i := range want {
+		if history[i].Subject != want[i].Subject || !history[i].Time.Equal(want[i].Time) {
+			t.Errorf("history[%d] = %v, want %v", i, history[i], want[i])
+		}
+	}
+}
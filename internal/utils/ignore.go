@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const ignoreFilename = ".kommitignore"
+
+// IgnorePattern is one line of a .kommitignore file.
+type IgnorePattern struct {
+	Pattern string
+	// Negate re-includes files an earlier pattern excluded
+	Negate bool
+}
+
+// ParseIgnoreFile reads .gitignore-style patterns, one per line. Blank lines
+// and lines starting with `#` are skipped, and a leading `!` negates the
+// pattern, re-including files an earlier pattern excluded.
+func ParseIgnoreFile(content string) []IgnorePattern {
+	var patterns []IgnorePattern
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, parseIgnorePattern(line))
+	}
+	return patterns
+}
+
+// parseIgnorePattern reads a single pattern. `\#` and `\!` escape patterns
+// that start with these characters, which then match literally.
+func parseIgnorePattern(line string) IgnorePattern {
+	if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		return IgnorePattern{Pattern: line[1:]}
+	}
+	if pattern, ok := strings.CutPrefix(line, "!"); ok {
+		return IgnorePattern{Pattern: pattern, Negate: true}
+	}
+	return IgnorePattern{Pattern: line}
+}
+
+// IgnorePatterns returns commit.ignorePatterns followed by the patterns of the
+// .kommitignore file in the repo root, if there is one.
+func IgnorePatterns(config *Config) ([]IgnorePattern, error) {
+	var patterns []IgnorePattern
+	for _, pattern := range config.Commit.IgnorePatterns {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, parseIgnorePattern(pattern))
+		}
+	}
+
+	root, err := GetConfigPath()
+	if err != nil {
+		return patterns, nil
+	}
+	content, err := os.ReadFile(filepath.Join(root, ignoreFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return patterns, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(patterns, ParseIgnoreFile(string(content))...), nil
+}
+
+// IsIgnored reports whether path is excluded by patterns. As with .gitignore,
+// the last matching pattern wins, patterns without a `/` match at any depth,
+// and a leading `/` anchors a pattern to the repo root.
+func IsIgnored(path string, patterns []IgnorePattern) bool {
+	path = filepath.ToSlash(path)
+	ignored := false
+	for _, p := range patterns {
+		pattern := strings.TrimSuffix(p.Pattern, "/")
+		if pattern == "" {
+			continue
+		}
+		if matchIgnorePattern(pattern, path) {
+			ignored = !p.Negate
+		}
+	}
+	return ignored
+}
+
+func matchIgnorePattern(pattern, path string) bool {
+	if strings.HasPrefix(pattern, "/") || strings.Contains(pattern, "/") {
+		return MatchPathGlob(strings.TrimPrefix(pattern, "/"), path)
+	}
+	for _, component := range strings.Split(path, "/") {
+		if MatchPathGlob(pattern, component) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterIgnoredFiles drops the contents of ignored files from the diff. Their
+// headers are kept, so the model still knows the files changed.
+func FilterIgnoredFiles(diff string, patterns []IgnorePattern) string {
+	if len(patterns) == 0 {
+		return diff
+	}
+
+	var b strings.Builder
+	filtered := false
+	for _, f := range ParseDiff(diff) {
+		if IsIgnored(f.Path(), patterns) || (f.OldPath != "" && IsIgnored(f.OldPath, patterns)) {
+			f.Hunks = nil
+			filtered = true
+		}
+		b.WriteString(f.String())
+	}
+	if !filtered {
+		return diff
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []IgnorePattern
+	}{
+		{
+			name:    "comments and blank lines",
+			content: "# generated\n\n  \n*.pb.go\n  # indented comment\nvendor/\n",
+			want:    []IgnorePattern{{Pattern: "*.pb.go"}, {Pattern: "vendor/"}},
+		},
+		{
+			name:    "negation",
+			content: "*.lock\n!go.sum\n",
+			want:    []IgnorePattern{{Pattern: "*.lock"}, {Pattern: "go.sum", Negate: true}},
+		},
+		{
+			name:    "escaped negation stays literal",
+			content: `\!important.txt`,
+			want:    []IgnorePattern{{Pattern: "!important.txt"}},
+		},
+		{
+			name:    "escaped comment stays literal",
+			content: `\#notes.md`,
+			want:    []IgnorePattern{{Pattern: "#notes.md"}},
+		},
+		{
+			name:    "empty",
+			content: "",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseIgnoreFile(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseIgnoreFile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	patterns := ParseIgnoreFile(strings.Join([]string{
+		"*.lock",
+		"!keep.lock",
+		"/build",
+		"docs/generated/",
+		`\!literal`,
+	}, "\n"))
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"yarn.lock", true},
+		{"web/yarn.lock", true},
+		{"keep.lock", false},
+		{"build/out.js", true},
+		{"cmd/build/main.go", false},
+		{"docs/generated/api.md", true},
+		{"docs/guide.md", false},
+		{"!literal", true},
+		{"literal", false},
+		{"main.go", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := IsIgnored(tt.path, patterns); got != tt.want {
+				t.Errorf("IsIgnored(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterIgnoredFiles(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+index 1111111..2222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package old
++package main
+diff --git a/yarn.lock b/yarn.lock
+index 3333333..4444444 100644
+--- a/yarn.lock
++++ b/yarn.lock
+@@ -1 +1 @@
+-lodash@4.17.20
++lodash@4.17.21
+`
+
+	tests := []struct {
+		name        string
+		patterns    []IgnorePattern
+		wantKept    []string
+		wantDropped []string
+	}{
+		{
+			name:        "ignored contents are dropped",
+			patterns:    ParseIgnoreFile("*.lock"),
+			wantKept:    []string{"+package main", "diff --git a/yarn.lock b/yarn.lock"},
+			wantDropped: []string{"lodash"},
+		},
+		{
+			name:     "negated pattern keeps the file",
+			patterns: ParseIgnoreFile("*.lock\n!yarn.lock"),
+			wantKept: []string{"+package main", "+lodash@4.17.21"},
+		},
+		{
+			name:     "no patterns",
+			patterns: nil,
+			wantKept: []string{"+package main", "+lodash@4.17.21"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterIgnoredFiles(diff, tt.patterns)
+			for _, s := range tt.wantKept {
+				if !strings.Contains(got, s) {
+					t.Errorf("FilterIgnoredFiles() dropped %q:\n%s", s, got)
+				}
+			}
+			for _, s := range tt.wantDropped {
+				if strings.Contains(got, s) {
+					t.Errorf("FilterIgnoredFiles() kept %q:\n%s", s, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIgnorePatternsFromConfig(t *testing.T) {
+	config := &Config{Commit: CommitConfig{IgnorePatterns: []string{"*.snap", "!keep.snap", `\!bang`, " "}}}
+	patterns, err := IgnorePatterns(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []IgnorePattern{{Pattern: "*.snap"}, {Pattern: "keep.snap", Negate: true}, {Pattern: "!bang"}}
+	if len(patterns) < len(want) || !reflect.DeepEqual(patterns[:len(want)], want) {
+		t.Errorf("IgnorePatterns() = %#v, want prefix %#v", patterns, want)
+	}
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RecentFiles keeps the limit most recently modified files of the diff, in
+// their original order. Files missing from mtimes, such as deleted files, are
+// treated as the oldest.
+func RecentFiles(files []FileDiff, mtimes map[string]time.Time, limit int) []FileDiff {
+	if limit <= 0 || len(files) <= limit {
+		return files
+	}
+
+	order := make([]int, len(files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return mtimes[files[order[a]].Path()].After(mtimes[files[order[b]].Path()])
+	})
+
+	keep := make(map[int]bool, limit)
+	for _, i := range order[:limit] {
+		keep[i] = true
+	}
+
+	recent := make([]FileDiff, 0, limit)
+	for i, f := range files {
+		if keep[i] {
+			recent = append(recent, f)
+		}
+	}
+	return recent
+}
+
+// FileModTimes stats the given repo-relative paths in the working tree.
+// Paths that can't be stat'ed are left out.
+func FileModTimes(paths []string) (map[string]time.Time, error) {
+	root, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	mtimes := make(map[string]time.Time, len(paths))
+	for _, path := range paths {
+		if info, err := os.Stat(filepath.Join(root, path)); err == nil {
+			mtimes[path] = info.ModTime()
+		}
+	}
+	return mtimes, nil
+}
+
+// TrimToRecentFiles reduces the diff to the hunks of its limit most recently
+// modified files.
+func TrimToRecentFiles(diff string, limit int) (string, error) {
+	files := ParseDiff(diff)
+	if limit <= 0 || len(files) <= limit {
+		return diff, nil
+	}
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path()
+	}
+	mtimes, err := FileModTimes(paths)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, f := range RecentFiles(files, mtimes, limit) {
+		b.WriteString(f.String())
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const recencyDiff = `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -1 +1 @@
+-a
++A
+diff --git a/b.go b/b.go
+index 1111111..2222222 100644
+--- a/b.go
++++ b/b.go
+@@ -1 +1 @@
+-b
++B
+diff --git a/c.go b/c.go
+index 1111111..2222222 100644
+--- a/c.go
++++ b/c.go
+@@ -1 +1 @@
+-c
++C
+diff --git a/gone.go b/gone.go
+deleted file mode 100644
+index 1111111..0000000
+--- a/gone.go
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+`
+
+func TestRecentFiles(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mtimes := map[string]time.Time{
+		"a.go": base.Add(1 * time.Hour),
+		"b.go": base.Add(3 * time.Hour),
+		"c.go": base.Add(2 * time.Hour),
+	}
+
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"most recent", 1, []string{"b.go"}},
+		{"original order kept", 2, []string{"b.go", "c.go"}},
+		{"files without mtime are oldest", 3, []string{"a.go", "b.go", "c.go"}},
+		{"limit covers every file", 4, []string{"a.go", "b.go", "c.go", "gone.go"}},
+		{"no limit", 0, []string{"a.go", "b.go", "c.go", "gone.go"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range RecentFiles(ParseDiff(recencyDiff), mtimes, tt.limit) {
+				got = append(got, f.Path())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RecentFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrimToRecentFiles(t *testing.T) {
+	newTestRepo(t)
+	base := time.Now().Add(-time.Hour)
+	for i, path := range []string{"c.go", "a.go", "b.go"} {
+		writeFile(t, path, "content\n")
+		mtime := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trimmed, err := TrimToRecentFiles(recencyDiff, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range ParseDiff(trimmed) {
+		got = append(got, f.Path())
+	}
+	if want := []string{"a.go", "b.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TrimToRecentFiles() kept %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const revertSearchDepth = 20
+
+type RevertedCommit struct {
+	Hash    string
+	Subject string
+}
+
+// FindRevertedCommit returns the commit the staged diff reverts, or nil if it
+// doesn't look like a revert. An in-progress `git revert --no-commit` is
+// trusted first; otherwise the diff is compared against the inverse of the
+// most recent commits.
+func FindRevertedCommit(diff string) (*RevertedCommit, error) {
+	if hash, err := ExecGit("rev-parse", "-q", "--verify", "REVERT_HEAD"); err == nil {
+		return getRevertedCommit(strings.TrimSpace(hash))
+	}
+
+	staged := diffSignature(ParseDiff(diff), true)
+	if staged == "" {
+		return nil, nil
+	}
+
+	output, err := ExecGit("log", "-n", strconv.Itoa(revertSearchDepth), "--format=%H")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range strings.Fields(output) {
+		commitDiff, err := ExecGit("show", "--format=", "--no-color", hash)
+		if err != nil {
+			continue
+		}
+		if diffSignature(ParseDiff(commitDiff), false) == staged {
+			return getRevertedCommit(hash)
+		}
+	}
+
+	return nil, nil
+}
+
+func getRevertedCommit(hash string) (*RevertedCommit, error) {
+	subject, err := ExecGit("log", "-1", "--format=%s", hash)
+	if err != nil {
+		return nil, err
+	}
+	return &RevertedCommit{Hash: hash, Subject: strings.TrimSpace(subject)}, nil
+}
+
+// diffSignature reduces a diff to its changed lines per file, ignoring hunk
+// positions and line order, so two diffs can be compared. When inverse is set
+// the signature describes the diff that would undo the given one.
+func diffSignature(files []FileDiff, inverse bool) string {
+	entries := make([]string, 0, len(files))
+	for _, f := range files {
+		oldPath, newPath := f.OldPath, f.NewPath
+		if inverse {
+			oldPath, newPath = newPath, oldPath
+		}
+
+		var lines []string
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				if line == "" || (line[0] != '+' && line[0] != '-') {
+					continue
+				}
+				if inverse {
+					line = invertLine(line)
+				}
+				lines = append(lines, line)
+			}
+		}
+		sort.Strings(lines)
+		entries = append(entries, oldPath+"\x00"+newPath+"\n"+strings.Join(lines, "\n"))
+	}
+
+	sort.Strings(entries)
+	return strings.Join(entries, "\n")
+}
+
+func invertLine(line string) string {
+	if line[0] == '+' {
+		return "-" + line[1:]
+	}
+	return "+" + line[1:]
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindRevertedCommit(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		wantSubject string
+	}{
+		{
+			name:        "inverse of the last commit",
+			content:     "one\n",
+			wantSubject: "feat: add a second line",
+		},
+		{
+			name:    "normal change",
+			content: "one\ntwo\nthree\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestRepo(t)
+			commitFile(t, "file.txt", "one\n", "chore: initial commit")
+			commitFile(t, "file.txt", "one\ntwo\n", "feat: add a second line")
+			head := strings.TrimSpace(runGit(t, "rev-parse", "HEAD"))
+
+			writeFile(t, "file.txt", tt.content)
+			runGit(t, "add", "file.txt")
+			diff := runGit(t, "diff", "--cached")
+
+			reverted, err := FindRevertedCommit(diff)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantSubject == "" {
+				if reverted != nil {
+					t.Errorf("FindRevertedCommit() = %+v, want nil", reverted)
+				}
+				return
+			}
+			if reverted == nil {
+				t.Fatal("FindRevertedCommit() = nil, want the last commit")
+			}
+			if reverted.Subject != tt.wantSubject || reverted.Hash != head {
+				t.Errorf("FindRevertedCommit() = %+v, want %s %q", reverted, head, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestFindRevertedCommitRevertHead(t *testing.T) {
+	newTestRepo(t)
+	commitFile(t, "a.txt", "a\n", "chore: initial commit")
+	commitFile(t, "b.txt", "b\n", "feat: add b")
+	head := strings.TrimSpace(runGit(t, "rev-parse", "HEAD"))
+
+	runGit(t, "revert", "--no-commit", "HEAD")
+	reverted, err := FindRevertedCommit(runGit(t, "diff", "--cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted == nil || reverted.Hash != head || reverted.Subject != "feat: add b" {
+		t.Errorf("FindRevertedCommit() = %+v, want %s \"feat: add b\"", reverted, head)
+	}
+}
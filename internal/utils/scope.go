@@ -42,6 +42,171 @@ func GetScopesFromHistory() ([]string, error) {
 	return scopes, nil
 }
 
+// ScopeFromPaths derives a scope from file paths alone. Each path maps to the
+// deepest directory whose name is one of allowedScopes (so
+// `internal/llm/openai.go` maps to `llm`), or to its top-level directory when
+// no scopes are configured. The scope is returned only when every path maps
+// to the same one; otherwise the result is empty.
+func ScopeFromPaths(paths []string, allowedScopes []string) string {
+	allowed := make(map[string]string, len(allowedScopes))
+	for _, scope := range allowedScopes {
+		allowed[strings.ToLower(scope)] = scope
+	}
+
+	scope := ""
+	for i, path := range paths {
+		dirs := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+		if dirs[0] == "." {
+			return ""
+		}
+
+		pathScope := ""
+		if len(allowed) == 0 {
+			pathScope = dirs[0]
+		}
+		for j := len(dirs) - 1; j >= 0 && len(allowed) > 0; j-- {
+			if s, ok := allowed[strings.ToLower(dirs[j])]; ok {
+				pathScope = s
+				break
+			}
+		}
+
+		if pathScope == "" || (i > 0 && pathScope != scope) {
+			return ""
+		}
+		scope = pathScope
+	}
+	return scope
+}
+
+// ValidateScopesAgainstPaths reports the scopes that no longer name any
+// directory or file (ignoring extensions) in repoFiles, compared
+// case-insensitively. Stale scopes are returned in their configured order.
+func ValidateScopesAgainstPaths(scopes []string, repoFiles []string) (stale []string) {
+	names := make(map[string]bool)
+	for _, file := range repoFiles {
+		for _, part := range strings.Split(filepath.ToSlash(file), "/") {
+			names[strings.ToLower(part)] = true
+			names[strings.ToLower(strings.TrimSuffix(part, filepath.Ext(part)))] = true
+		}
+	}
+
+	for _, scope := range scopes {
+		if !names[strings.ToLower(scope)] {
+			stale = append(stale, scope)
+		}
+	}
+	return stale
+}
+
+var goPackageRegex = regexp.MustCompile(`^[+ ]package\s+(\w+)`)
+
+// ScopeFromGoPackages returns the package most of the changed Go files
+// belong to, read from the `package` clauses in their added or context lines.
+// It only applies to Go changes: the result is empty when the diff touches
+// other files (go.mod and go.sum aside), when no package clause is visible,
+// when packages tie, or for package main. Test packages count as the package
+// under test.
+func ScopeFromGoPackages(diff string) string {
+	counts := make(map[string]int)
+	for _, f := range ParseDiff(diff) {
+		switch base := filepath.Base(f.Path()); {
+		case base == "go.mod" || base == "go.sum":
+			continue
+		case filepath.Ext(base) != ".go":
+			return ""
+		}
+
+	lines:
+		for _, hunk := range f.Hunks {
+			for _, line := range hunk.Lines {
+				if matches := goPackageRegex.FindStringSubmatch(line); matches != nil {
+					counts[strings.TrimSuffix(matches[1], "_test")]++
+					break lines
+				}
+			}
+		}
+	}
+
+	dominant, best, tied := "", 0, false
+	for pkg, count := range counts {
+		switch {
+		case count > best:
+			dominant, best, tied = pkg, count, false
+		case count == best:
+			tied = true
+		}
+	}
+	if tied || dominant == "main" {
+		return ""
+	}
+	return dominant
+}
+
+// ScopeFromRules maps every path to the scope of the first rule it matches.
+// The scope is returned only when all paths match a rule and agree on the
+// scope; otherwise the result is empty.
+func ScopeFromRules(paths []string, rules []PathScopeRule) string {
+	scope := ""
+	for i, path := range paths {
+		pathScope := ""
+		for _, rule := range rules {
+			if MatchPathGlob(rule.Pattern, path) {
+				pathScope = rule.Scope
+				break
+			}
+		}
+		if pathScope == "" || (i > 0 && pathScope != scope) {
+			return ""
+		}
+		scope = pathScope
+	}
+	return scope
+}
+
+// MatchPathGlob reports whether path, or one of its parent directories,
+// matches pattern. `*` and `?` don't match `/`, while `**` does.
+func MatchPathGlob(pattern, path string) bool {
+	re, err := globRegexp(pattern)
+	if err != nil {
+		return false
+	}
+
+	path = filepath.ToSlash(path)
+	for {
+		if re.MatchString(path) {
+			return true
+		}
+		i := strings.LastIndex(path, "/")
+		if i < 0 {
+			return false
+		}
+		path = path[:i]
+	}
+}
+
+func globRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
 func GetFilesFromDirectory(maxDepth int) ([]string, error) {
 	path, err := GetConfigPath()
 	if err != nil {
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestScopeFromPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   []string
+		allowed []string
+		want    string
+	}{
+		{"single dir", []string{"internal/llm/openai.go", "internal/llm/format.go"}, []string{"llm", "utils"}, "llm"},
+		{"deepest allowed dir wins", []string{"internal/llm/models/gpt.go"}, []string{"llm", "models"}, "models"},
+		{"case-insensitive match keeps configured case", []string{"web/API/handler.go"}, []string{"api"}, "api"},
+		{"multi dir", []string{"internal/llm/openai.go", "internal/utils/git.go"}, []string{"llm", "utils"}, ""},
+		{"no match", []string{"docs/guide.md"}, []string{"llm", "utils"}, ""},
+		{"one path without a match", []string{"internal/llm/openai.go", "docs/llm.md"}, []string{"llm"}, ""},
+		{"root file", []string{"main.go"}, nil, ""},
+		{"top-level dir without scopes", []string{"cmd/root.go", "cmd/init.go"}, nil, "cmd"},
+		{"different top-level dirs without scopes", []string{"cmd/root.go", "internal/llm/openai.go"}, nil, ""},
+		{"no paths", nil, []string{"llm"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeFromPaths(tt.paths, tt.allowed); got != tt.want {
+				t.Errorf("ScopeFromPaths(%q, %q) = %q, want %q", tt.paths, tt.allowed, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeFromRules(t *testing.T) {
+	rules := []PathScopeRule{
+		{Pattern: "services/payments/**", Scope: "payments"},
+		{Pattern: "services/*/api", Scope: "api"},
+		{Pattern: "services", Scope: "services"},
+		{Pattern: "*.md", Scope: "docs"},
+	}
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  string
+	}{
+		{"matching rule", []string{"services/payments/charge.go", "services/payments/refund/refund.go"}, "payments"},
+		{"first rule wins", []string{"services/payments/api/handler.go"}, "payments"},
+		{"later rule", []string{"services/billing/api/handler.go"}, "api"},
+		{"parent directory matches", []string{"services/billing/invoice.go"}, "services"},
+		{"star doesn't cross directories", []string{"docs/guide.md"}, ""},
+		{"rules disagree", []string{"services/payments/charge.go", "README.md"}, ""},
+		{"no match", []string{"web/app.tsx"}, ""},
+		{"one path without a match", []string{"services/payments/charge.go", "web/app.tsx"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeFromRules(tt.paths, rules); got != tt.want {
+				t.Errorf("ScopeFromRules(%q) = %q, want %q", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScopesAgainstPaths(t *testing.T) {
+	files := []string{
+		"cmd/root.go",
+		"internal/llm/openai.go",
+		"internal/utils/config.go",
+		"web/src/Checkout.tsx",
+		"README.md",
+	}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   []string
+	}{
+		{"all current", []string{"cmd", "llm", "utils", "web"}, nil},
+		{"stale scopes in order", []string{"payments", "llm", "auth", "cmd"}, []string{"payments", "auth"}},
+		{"case-insensitive", []string{"LLM", "Web"}, nil},
+		{"files without extensions", []string{"checkout", "readme"}, nil},
+		{"no scopes", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ValidateScopesAgainstPaths(tt.scopes, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateScopesAgainstPaths() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// goFileDiff builds a diff for a Go file whose hunk shows its package clause.
+func goFileDiff(path, pkg string) string {
+	return fmt.Sprintf("diff --git a/%[1]s b/%[1]s\n--- a/%[1]s\n+++ b/%[1]s\n@@ -1,2 +1,3 @@\n package %[2]s\n \n+var x = 1\n", path, pkg)
+}
+
+func TestScopeFromGoPackages(t *testing.T) {
+	tests := []struct {
+		name string
+		diff string
+		want string
+	}{
+		{"single package", goFileDiff("a.go", "orders") + goFileDiff("b.go", "orders"), "orders"},
+		{"flat layout", goFileDiff("orders.go", "shop") + goFileDiff("cart.go", "shop"), "shop"},
+		{"dominant package", goFileDiff("a.go", "orders") + goFileDiff("b.go", "orders") + goFileDiff("c.go", "billing"), "orders"},
+		{"tied packages", goFileDiff("a.go", "orders") + goFileDiff("b.go", "billing"), ""},
+		{"test package counts as the package", goFileDiff("a.go", "orders") + goFileDiff("a_test.go", "orders_test"), "orders"},
+		{"added package clause", "diff --git a/new.go b/new.go\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package cache\n", "cache"},
+		{"go.mod ignored", goFileDiff("a.go", "orders") + "diff --git a/go.mod b/go.mod\n--- a/go.mod\n+++ b/go.mod\n@@ -1 +1 @@\n-go 1.22\n+go 1.23\n", "orders"},
+		{"other languages", goFileDiff("a.go", "orders") + "diff --git a/a.ts b/a.ts\n--- a/a.ts\n+++ b/a.ts\n@@ -1 +1 @@\n-x\n+y\n", ""},
+		{"package main", goFileDiff("main.go", "main"), ""},
+		{"no package clause visible", "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -10 +10 @@\n-x\n+y\n", ""},
+		{"removed package clause ignored", "diff --git a/a.go b/a.go\n--- a/a.go\n+++ b/a.go\n@@ -1 +1 @@\n-package old\n+package orders\n", "orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScopeFromGoPackages(tt.diff); got != tt.want {
+				t.Errorf("ScopeFromGoPackages() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
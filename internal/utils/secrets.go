@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ScanForSecrets pipes the diff into the configured scanner command (e.g.
+// `gitleaks stdin --no-banner`). The command is split on whitespace and run
+// without a shell. A zero exit code means no findings; any other exit code is
+// treated as findings, reported one per non-empty output line.
+func ScanForSecrets(command, diff string) ([]string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, nil
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(diff)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	err := cmd.Run()
+	if err == nil {
+		return nil, nil
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return nil, fmt.Errorf("failed to run secret scanner %q: %w", args[0], err)
+	}
+
+	var findings []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			findings = append(findings, line)
+		}
+	}
+	if len(findings) == 0 {
+		findings = []string{fmt.Sprintf("%s exited with code %d", args[0], exitErr.ExitCode())}
+	}
+	return findings, nil
+}
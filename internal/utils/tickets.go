@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultTicketPatterns match Jira keys and GitHub-style issue numbers. An
+// issue number has to start a word, so `a#1` or `&#39;` isn't one.
+var DefaultTicketPatterns = []string{`JIRA-\d+`, `(?:^|[\s(\[,])(#\d+)\b`}
+
+var (
+	hexColorRegex = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+	// cssPropertyRegex matches the text before a value assigned to a CSS
+	// property or variable, e.g. `color: ` or `$brand: `
+	cssPropertyRegex = regexp.MustCompile(`(?:^|[\s{;])(?:\$|--)?[a-z][a-z0-9-]*\s*:\s*$`)
+)
+
+// ExtractTicketRefs returns the ticket references found in the lines the diff
+// adds, in order of first appearance and without duplicates. patterns are
+// regular expressions whose first capturing group, if any, is the reference;
+// invalid ones are skipped, and DefaultTicketPatterns are used when none are
+// given. References that look like CSS hex colors, such as `#333;`, are
+// skipped.
+func ExtractTicketRefs(diff string, patterns []string) []string {
+	if len(patterns) == 0 {
+		patterns = DefaultTicketPatterns
+	}
+	var regexes []*regexp.Regexp
+	for _, pattern := range patterns {
+		if re, err := regexp.Compile(pattern); err == nil {
+			regexes = append(regexes, re)
+		}
+	}
+
+	var refs []string
+	for _, line := range strings.Split(diff, "\n") {
+		if !strings.HasPrefix(line, "+") || strings.HasPrefix(line, "+++") {
+			continue
+		}
+		line = line[1:]
+		for _, re := range regexes {
+			for _, match := range re.FindAllStringSubmatchIndex(line, -1) {
+				start, end := match[0], match[1]
+				if len(match) > 2 && match[2] >= 0 {
+					start, end = match[2], match[3]
+				}
+				ref := line[start:end]
+				if isHexColor(line, start, end) || slices.Contains(refs, ref) {
+					continue
+				}
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// isHexColor reports whether line[start:end] reads as a CSS color value
+// rather than an issue number: a hex-looking token assigned to a property or
+// ending a declaration.
+func isHexColor(line string, start, end int) bool {
+	if !hexColorRegex.MatchString(line[start:end]) {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeft(line[end:], " \t"), ";") || cssPropertyRegex.MatchString(line[:start])
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractTicketRefs(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		patterns []string
+		want     []string
+	}{
+		{
+			name: "default patterns",
+			diff: "+++ b/main.go\n+// TODO(JIRA-123): drop this\n+// see #42 for details\n",
+			want: []string{"JIRA-123", "#42"},
+		},
+		{
+			name: "repeated references de-duplicated",
+			diff: "+// JIRA-7\n+// JIRA-7 again, and #3\n+// #3\n",
+			want: []string{"JIRA-7", "#3"},
+		},
+		{
+			name: "removed and context lines ignored",
+			diff: "-// JIRA-1\n // JIRA-2\n+// JIRA-3\n",
+			want: []string{"JIRA-3"},
+		},
+		{
+			name: "CSS hex colors skipped",
+			diff: "+++ b/theme.css\n+.title { color: #333; }\n+  background: #0000ff;\n+$accent: #123456\n+  border: 1px solid #000;\n",
+			want: nil,
+		},
+		{
+			name: "issue numbers starting a word",
+			diff: "+// fixes #333 (see #12), not a#1 or &#39;\n+#7 is next\n",
+			want: []string{"#333", "#12", "#7"},
+		},
+		{
+			name:     "capturing group is the reference",
+			diff:     "+// ticket: [PROJ-9]\n",
+			patterns: []string{`\[(PROJ-\d+)\]`},
+			want:     []string{"PROJ-9"},
+		},
+		{
+			name:     "custom patterns",
+			diff:     "+// PROJ-9 and JIRA-1\n",
+			patterns: []string{`PROJ-\d+`},
+			want:     []string{"PROJ-9"},
+		},
+		{
+			name:     "invalid pattern skipped",
+			diff:     "+// PROJ-9\n",
+			patterns: []string{`(`, `PROJ-\d+`},
+			want:     []string{"PROJ-9"},
+		},
+		{
+			name: "no references",
+			diff: "+x := 1\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractTicketRefs(tt.diff, tt.patterns); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractTicketRefs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+const (
+	minTemperature = 0.0
+	maxTemperature = 2.0
+	maxTopLogprobs = 20
+)
+
+var knownProviders = []string{"", ProviderOpenAI, ProviderBedrock}
+
+// OmittableParams are the request parameters llm.omitParams can drop.
+var OmittableParams = []string{
+	"temperature", "top_p", "presence_penalty", "frequency_penalty", "user", "logprobs", "top_logprobs", "response_format",
+}
+
+var knownVerbosities = []Verbosity{"", VerbosityTerse, VerbosityNormal, VerbosityVerbose}
+
+var knownScopeDerivations = []ScopeDerivation{"", ScopeDerivationHint, ScopeDerivationAuthoritative}
+
+var knownMergeStrategies = []MergeStrategy{"", MergeStrategyReplace, MergeStrategyUnion}
+
+var knownBulletStyles = []BulletStyle{"", BulletStyleDash, BulletStyleAsterisk, BulletStyleNumbered}
+
+var knownFixupModes = []FixupMode{"", FixupModeFixup, FixupModeSquash}
+
+var knownRenderProfiles = []RenderProfile{"", RenderProfileGitHub, RenderProfileGitLab, RenderProfilePlain}
+
+// Validate checks the config for mistakes that would otherwise only surface
+// in the middle of a generation call. All problems are reported at once, each
+// labeled with the offending field.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, ConfigFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// llm
+	if !slices.Contains(knownProviders, c.LLM.Provider) {
+		fail("llm.provider", "unknown provider %q", c.LLM.Provider)
+	}
+	if c.LLM.Provider == ProviderBedrock && c.LLM.Model == "" {
+		fail("llm.model", "a Bedrock model ID is required")
+	}
+	if t := c.LLM.Temperature; t != nil && (*t < minTemperature || *t > maxTemperature) {
+		fail("llm.temperature", "%v is out of range [%v, %v]", *t, minTemperature, maxTemperature)
+	}
+	if c.LLM.MaxRequestBytes < 0 {
+		fail("llm.maxRequestBytes", "must not be negative")
+	}
+	for i, param := range c.LLM.OmitParams {
+		if !slices.Contains(OmittableParams, param) {
+			fail(fmt.Sprintf("llm.omitParams[%d]", i), "%q can't be omitted (one of %s)", param, strings.Join(OmittableParams, ", "))
+		}
+	}
+	if c.LLM.ConnectTimeout < 0 {
+		fail("llm.connectTimeout", "must not be negative")
+	}
+	if c.LLM.ResponseTimeout < 0 {
+		fail("llm.responseTimeout", "must not be negative")
+	}
+	if c.LLM.TotalAttemptBudget < 0 {
+		fail("llm.totalAttemptBudget", "must not be negative")
+	}
+
+	if c.LLM.TopLogprobs < 0 || c.LLM.TopLogprobs > maxTopLogprobs {
+		fail("llm.topLogprobs", "%d is out of range [0, %d]", c.LLM.TopLogprobs, maxTopLogprobs)
+	}
+
+	// commit
+	if !slices.Contains(knownMergeStrategies, c.Commit.TypesMergeStrategy) {
+		fail("commit.typesMergeStrategy", "unknown strategy %q", c.Commit.TypesMergeStrategy)
+	}
+	if !slices.Contains(knownScopeDerivations, c.Commit.DeriveScopeFromPath) {
+		fail("commit.deriveScopeFromPath", "unknown mode %q", c.Commit.DeriveScopeFromPath)
+	}
+	if !slices.Contains(knownVerbosities, c.Commit.Verbosity) {
+		fail("commit.verbosity", "unknown verbosity %q", c.Commit.Verbosity)
+	}
+	if !slices.Contains(knownBulletStyles, c.Commit.BulletStyle) {
+		fail("commit.bulletStyle", "unknown bullet style %q", c.Commit.BulletStyle)
+	}
+	if !slices.Contains(knownFixupModes, c.Commit.Fixup) {
+		fail("commit.fixup", "unknown mode %q", c.Commit.Fixup)
+	}
+	if !slices.Contains(knownRenderProfiles, c.Commit.RenderProfile) {
+		fail("commit.renderProfile", "unknown render profile %q", c.Commit.RenderProfile)
+	}
+	if c.Commit.PreserveRawFormatting && c.Commit.BulletStyle != "" {
+		fail("commit.bulletStyle", "cannot be combined with commit.preserveRawFormatting")
+	}
+	if c.Commit.StrictValidation && len(c.Commit.AllowedTypes()) == 0 {
+		fail("commit.types", "no type is allowed, so every message would fail commit.strictValidation")
+	}
+	if c.Commit.ForcedType != "" && !slices.Contains(c.Commit.AllowedTypes(), c.Commit.ForcedType) {
+		fail("commit.forcedType", "%q is not an allowed type", c.Commit.ForcedType)
+	}
+	if c.Commit.RequireScope && len(c.Commit.Scopes) == 0 {
+		fail("commit.requireScope", "cannot be satisfied without any commit.scopes")
+	}
+	if c.Commit.PromptTemplate != "" {
+		if _, err := template.New("prompt").Parse(c.Commit.PromptTemplate); err != nil {
+			fail("commit.promptTemplate", "%v", err)
+		}
+	}
+	if c.Commit.MinConfidence < 0 || c.Commit.MinConfidence > 1 {
+		fail("commit.minConfidence", "%v is out of range [0, 1]", c.Commit.MinConfidence)
+	}
+	if c.Commit.MaxInferredScopes < 0 {
+		fail("commit.maxInferredScopes", "must not be negative")
+	}
+	if c.Commit.MaxBodyBullets < 0 {
+		fail("commit.maxBodyBullets", "must not be negative")
+	}
+	if c.Commit.HistoryExamples < 0 {
+		fail("commit.historyExamples", "must not be negative")
+	}
+	if b := c.Commit.HistoryRecencyBias; b != nil && (*b < 0 || *b > 1) {
+		fail("commit.historyRecencyBias", "%v is out of range [0, 1]", *b)
+	}
+	if c.Commit.ExampleTokenBudget < 0 {
+		fail("commit.exampleTokenBudget", "must not be negative")
+	}
+	for i, example := range c.Commit.Examples {
+		if strings.TrimSpace(example.Message) == "" {
+			fail(fmt.Sprintf("commit.examples[%d].message", i), "must not be empty")
+		}
+	}
+	if t := c.Commit.RedundantBodyThreshold; t < 0 || t > 1 {
+		fail("commit.redundantBodyThreshold", "%v is out of range [0, 1]", t)
+	}
+	if c.Commit.MaxBodyLines < 0 {
+		fail("commit.maxBodyLines", "must not be negative")
+	}
+	if c.Commit.MaxTotalLength < 0 {
+		fail("commit.maxTotalLength", "must not be negative")
+	}
+	if c.Commit.ContextLines < 0 {
+		fail("commit.contextLines", "must not be negative")
+	}
+	if c.Commit.RecentFileLimit < 0 {
+		fail("commit.recentFileLimit", "must not be negative")
+	}
+	if c.Commit.WarnDiffBytes < 0 {
+		fail("commit.warnDiffBytes", "must not be negative")
+	}
+	if c.Commit.MaxLineLength < 0 {
+		fail("commit.maxLineLength", "must not be negative")
+	}
+	for i, pattern := range c.Commit.TicketPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			fail(fmt.Sprintf("commit.ticketPatterns[%d]", i), "%v", err)
+		}
+	}
+	for i, rule := range c.Commit.PathScopeRules {
+		field := fmt.Sprintf("commit.pathScopeRules[%d]", i)
+		if rule.Pattern == "" {
+			fail(field+".pattern", "must not be empty")
+		}
+		if rule.Scope == "" {
+			fail(field+".scope", "must not be empty")
+		}
+	}
+	// Map keys are sorted so the errors come out in the same order every time
+	for _, commitType := range slices.Sorted(maps.Keys(c.Commit.TypeDescriptions)) {
+		description := c.Commit.TypeDescriptions[commitType]
+		field := "commit.typeDescriptions." + commitType
+		if !slices.Contains(c.Commit.Types, commitType) {
+			fail(field, "%q is not one of commit.types", commitType)
+		}
+		if strings.TrimSpace(description) == "" {
+			fail(field, "must not be empty")
+		}
+	}
+	for _, term := range slices.Sorted(maps.Keys(c.Commit.Glossary)) {
+		if strings.TrimSpace(c.Commit.Glossary[term]) == "" {
+			fail("commit.glossary."+term, "must not be empty")
+		}
+	}
+	for _, commitType := range slices.Sorted(maps.Keys(c.Commit.TemperatureByType)) {
+		t := c.Commit.TemperatureByType[commitType]
+		field := "commit.temperatureByType." + commitType
+		if !slices.Contains(c.Commit.Types, commitType) {
+			fail(field, "%q is not one of commit.types", commitType)
+		}
+		if t < minTemperature || t > maxTemperature {
+			fail(field, "%v is out of range [%v, %v]", t, minTemperature, maxTemperature)
+		}
+	}
+
+	// privacy
+	for i, pattern := range c.Privacy.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			fail(fmt.Sprintf("privacy.redactPatterns[%d]", i), "%v", err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	temperature := func(t float64) *float64 { return &t }
+	defaultTypes := []string{"feat", "fix", "docs", "chore"}
+
+	tests := []struct {
+		name       string
+		config     Config
+		wantFields []string
+	}{
+		{
+			name: "valid",
+			config: Config{
+				LLM: LLMConfig{Provider: ProviderOpenAI, Model: "gpt-4o-mini", Temperature: temperature(0.7)},
+				Commit: CommitConfig{
+					Types:             defaultTypes,
+					Scopes:            []string{"api"},
+					RequireScope:      true,
+					TemperatureByType: map[string]float64{"feat": 0.5},
+					PathScopeRules:    []PathScopeRule{{Pattern: "api/**", Scope: "api"}},
+				},
+			},
+		},
+		{
+			name:       "unknown provider",
+			config:     Config{LLM: LLMConfig{Provider: "azure"}, Commit: CommitConfig{Types: defaultTypes}},
+			wantFields: []string{"llm.provider"},
+		},
+		{
+			name:       "out-of-range temperatures",
+			config:     Config{LLM: LLMConfig{Temperature: temperature(3)}, Commit: CommitConfig{Types: defaultTypes, TemperatureByType: map[string]float64{"feat": -1}}},
+			wantFields: []string{"llm.temperature", "commit.temperatureByType.feat"},
+		},
+		{
+			name:       "unknown type in temperatureByType",
+			config:     Config{Commit: CommitConfig{Types: defaultTypes, TemperatureByType: map[string]float64{"spike": 0.5}}},
+			wantFields: []string{"commit.temperatureByType.spike"},
+		},
+		{
+			name:       "mutually exclusive formatting flags",
+			config:     Config{Commit: CommitConfig{Types: defaultTypes, PreserveRawFormatting: true, BulletStyle: BulletStyleAsterisk}},
+			wantFields: []string{"commit.bulletStyle"},
+		},
+		{
+			name:       "unsatisfiable scope requirement",
+			config:     Config{Commit: CommitConfig{Types: defaultTypes, RequireScope: true}},
+			wantFields: []string{"commit.requireScope"},
+		},
+		{
+			name:       "negative scope cap",
+			config:     Config{Commit: CommitConfig{Types: defaultTypes, MaxInferredScopes: -1}},
+			wantFields: []string{"commit.maxInferredScopes"},
+		},
+		{
+			name:       "strict validation without types",
+			config:     Config{Commit: CommitConfig{StrictValidation: true}},
+			wantFields: []string{"commit.types"},
+		},
+		{
+			name:       "strict validation with every type denied",
+			config:     Config{Commit: CommitConfig{Types: []string{"feat"}, DeniedTypes: []string{"feat"}, StrictValidation: true}},
+			wantFields: []string{"commit.types"},
+		},
+		{
+			name:   "no types without strict validation",
+			config: Config{Commit: CommitConfig{}},
+		},
+		{
+			name: "several problems at once",
+			config: Config{
+				LLM:    LLMConfig{Provider: ProviderBedrock, MaxRequestBytes: -1},
+				Commit: CommitConfig{Types: defaultTypes, ForcedType: "spike", TicketPatterns: []string{"("}},
+			},
+			wantFields: []string{"llm.model", "llm.maxRequestBytes", "commit.forcedType", "commit.ticketPatterns[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ConfigFieldError{}) {
+				t.Fatalf("Validate() = %v, want ConfigFieldErrors", err)
+			}
+			for _, field := range tt.wantFields {
+				if !strings.Contains(err.Error(), field+": ") {
+					t.Errorf("Validate() = %v, want an error for %s", err, field)
+				}
+			}
+			if got := len(strings.Split(err.Error(), "\n")); got != len(tt.wantFields) {
+				t.Errorf("Validate() reported %d problems, want %d:\n%v", got, len(tt.wantFields), err)
+			}
+		})
+	}
+}
+
+func TestConfigValidateMapOrder(t *testing.T) {
+	config := Config{
+		LLM: LLMConfig{Model: "gpt-4o-mini"},
+		Commit: CommitConfig{
+			Types:             []string{"feat"},
+			TypeDescriptions:  map[string]string{"perf": "x", "ci": "x", "docs": "x"},
+			Glossary:          map[string]string{"zeta": " ", "alpha": "", "mu": ""},
+			TemperatureByType: map[string]float64{"test": 1, "build": 1, "chore": 1},
+		},
+	}
+
+	want := config.Validate().Error()
+	for _, field := range []string{"typeDescriptions.ci", "typeDescriptions.perf", "glossary.alpha", "glossary.zeta", "temperatureByType.build", "temperatureByType.test"} {
+		if !strings.Contains(want, field) {
+			t.Fatalf("Validate() = %v, want an error for commit.%s", want, field)
+		}
+	}
+	if strings.Index(want, "glossary.alpha") > strings.Index(want, "glossary.mu") {
+		t.Errorf("Validate() = %v, want the glossary terms sorted", want)
+	}
+	for range 20 {
+		if got := config.Validate().Error(); got != want {
+			t.Fatalf("Validate() = %v, want the same order as %v", got, want)
+		}
+	}
+}